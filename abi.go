@@ -0,0 +1,93 @@
+package goexmars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ABIVersion is the exmars library ABI these bindings are written for. It
+// matches GOEXMARS_ABI_VERSION in exmars/goexmars_api.h.
+const ABIVersion = 2
+
+// Capabilities is the feature bitmask a library reports.
+type Capabilities uint32
+
+// Capability bits, see GOEXMARS_CAP_* in exmars/goexmars_api.h.
+const (
+	// CapDiagnostics reports structured assembler diagnostics.
+	CapDiagnostics Capabilities = 1 << iota
+	// CapFightInsns fights encoded instructions (FightParsed).
+	CapFightInsns
+	// CapTrace records executed instructions (TraceParsed).
+	CapTrace
+	// CapFixedPosition seeds the warrior positions from FixPos.
+	CapFixedPosition
+	// CapReadWriteLimits honours read/write limits. exmars does not
+	// implement them.
+	CapReadWriteLimits
+	// CapEvalExpr evaluates Redcode expressions (EvalExpression).
+	CapEvalExpr
+	// CapDiagnose loads warriors without fighting to collect their
+	// diagnostics.
+	CapDiagnose
+)
+
+// requiredCapabilities are the features the package relies on.
+const requiredCapabilities = CapDiagnostics | CapFightInsns | CapTrace | CapFixedPosition | CapEvalExpr | CapDiagnose
+
+var capabilityNames = []string{"diagnostics", "fight-insns", "trace", "fixed-position", "rw-limits", "eval-expr", "diagnose"}
+
+// Has reports whether all capabilities in x are set.
+func (c Capabilities) Has(x Capabilities) bool {
+	return c&x == x
+}
+
+// String lists the set capabilities separated by '|'.
+func (c Capabilities) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+			c &^= 1 << i
+		}
+	}
+	if c != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(c)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// LibInfo describes a loaded library.
+type LibInfo struct {
+	Path         string
+	ABIVersion   int
+	Capabilities Capabilities
+}
+
+// LibraryInfo loads the default library if needed and describes it.
+func LibraryInfo() (LibInfo, error) {
+	lib, err := DefaultLibrary()
+	if err != nil {
+		return LibInfo{}, err
+	}
+	return lib.Info(), nil
+}
+
+// Info describes l.
+func (l *Library) Info() LibInfo {
+	return LibInfo{Path: l.path, ABIVersion: l.abiVersion, Capabilities: l.capabilities}
+}
+
+// checkABI verifies the version and capabilities a library reported.
+func checkABI(version int, caps Capabilities) error {
+	if version != ABIVersion {
+		return fmt.Errorf("ABI version %d, want %d", version, ABIVersion)
+	}
+	if missing := requiredCapabilities &^ caps; missing != 0 {
+		return fmt.Errorf("missing capabilities %s", missing)
+	}
+	return nil
+}
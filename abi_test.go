@@ -0,0 +1,66 @@
+package goexmars
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestLibraryInfo(t *testing.T) {
+	path := copyTestLibrary(t, t.TempDir())
+	lib, err := LoadLibrary(path)
+	if err != nil {
+		t.Fatalf("LoadLibrary returned error: %v", err)
+	}
+
+	info := lib.Info()
+	if info.Path != path || info.ABIVersion != ABIVersion {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if !info.Capabilities.Has(requiredCapabilities) {
+		t.Fatalf("missing required capabilities: %s", info.Capabilities)
+	}
+	if info.Capabilities.Has(CapReadWriteLimits) {
+		t.Fatalf("exmars does not implement read/write limits")
+	}
+
+	testlib.Configure(t)
+	def, err := LibraryInfo()
+	if err != nil {
+		t.Fatalf("LibraryInfo returned error: %v", err)
+	}
+	if filepath.Base(def.Path) != exmarsLibraryName() || def.ABIVersion != ABIVersion {
+		t.Fatalf("unexpected default library info %+v", def)
+	}
+}
+
+func TestCheckABI(t *testing.T) {
+	if err := checkABI(ABIVersion, requiredCapabilities|CapReadWriteLimits); err != nil {
+		t.Fatalf("checkABI returned error: %v", err)
+	}
+	if err := checkABI(ABIVersion+1, requiredCapabilities); err == nil || !strings.Contains(err.Error(), "ABI version") {
+		t.Fatalf("expected version mismatch, got %v", err)
+	}
+	err := checkABI(ABIVersion, CapDiagnostics|CapFightInsns)
+	if err == nil || !strings.Contains(err.Error(), "trace|fixed-position") {
+		t.Fatalf("expected missing trace and fixed-position, got %v", err)
+	}
+}
+
+func TestCapabilitiesString(t *testing.T) {
+	tests := []struct {
+		caps Capabilities
+		want string
+	}{
+		{0, "none"},
+		{CapDiagnostics | CapTrace, "diagnostics|trace"},
+		{CapReadWriteLimits | 1<<10, "rw-limits|0x400"},
+	}
+	for _, tt := range tests {
+		if got := tt.caps.String(); got != tt.want {
+			t.Fatalf("Capabilities(%d).String() = %q, want %q", uint32(tt.caps), got, tt.want)
+		}
+	}
+}
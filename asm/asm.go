@@ -0,0 +1,129 @@
+// Package asm is a pure-Go port of the pMARS assembler used by exmars.
+//
+// It accepts the same Redcode as goexmars.Assemble (labels, EQU, FOR/ROF with
+// '&' concatenation, expressions with registers and predefined constants,
+// ORG/END, PIN and ;assert) and produces the same output, so warriors can be
+// validated and parsed without loading the shared library.
+package asm
+
+import "github.com/BigJk/goexmars"
+
+// Program is the full result of assembling a warrior.
+type Program struct {
+	// Warrior is identical to the result of goexmars.AssembleParsed,
+	// including its metadata.
+	Warrior goexmars.ParsedWarrior
+	// PIN is the p-space identifier set by the PIN pseudo-opcode.
+	PIN    int
+	HasPIN bool
+	// Diagnostics contains the warnings reported during assembly.
+	Diagnostics []goexmars.Diagnostic
+	// Text is the warnings text as printed by exmars.
+	Text string
+}
+
+// Assemble returns normalized assembled Redcode in the same format as goexmars.Assemble.
+func Assemble(warrior string, cfg goexmars.FightConfig) (string, error) {
+	p, err := AssembleProgram(warrior, cfg)
+	if err != nil {
+		return "", err
+	}
+	return p.Warrior.Assembled, nil
+}
+
+// AssembleParsed assembles a warrior into the same ParsedWarrior as goexmars.AssembleParsed.
+func AssembleParsed(warrior string, cfg goexmars.FightConfig) (goexmars.ParsedWarrior, error) {
+	p, err := AssembleProgram(warrior, cfg)
+	if err != nil {
+		return goexmars.ParsedWarrior{}, err
+	}
+	return p.Warrior, nil
+}
+
+// Validate reports whether a warrior assembles under cfg.
+func Validate(warrior string, cfg goexmars.FightConfig) error {
+	_, err := AssembleProgram(warrior, cfg)
+	return err
+}
+
+// AssembleProgram assembles a warrior and returns it together with its PIN
+// and warnings.
+//
+// Assembly failures are returned as *goexmars.AssembleError with the same
+// diagnostics text and codes exmars reports.
+func AssembleProgram(warrior string, cfg goexmars.FightConfig) (Program, error) {
+	cfg.Rounds = 1
+	return AssembleForFight(warrior, 1, cfg)
+}
+
+// AssembleForFight assembles a warrior the way exmars does at the start of a
+// fight between warriors warriors, so WARRIORS and ROUNDS take the fight's
+// values instead of 1.
+func AssembleForFight(warrior string, warriors int, cfg goexmars.FightConfig) (Program, error) {
+	if err := cfg.Validate(); err != nil {
+		return Program{}, err
+	}
+
+	a := newAssembler(cfg, warriors)
+	cells, ok := a.run(warrior)
+	if !ok {
+		return Program{}, &goexmars.AssembleError{Diagnostics: a.diags, Text: a.text.String()}
+	}
+
+	assembled := a.listing(cells)
+	cmds, err := goexmars.ParseAssembledCommands(assembled)
+	if err != nil {
+		return Program{}, err
+	}
+	w := goexmars.ParseMetadata(warrior)
+	w.End = a.offset
+	w.Commands = cmds
+	w.Assembled = assembled
+	return Program{
+		Warrior:     w,
+		PIN:         a.pin,
+		HasPIN:      a.hasPIN,
+		Diagnostics: a.diags,
+		Text:        a.text.String(),
+	}, nil
+}
+
+// run performs both passes and reports whether the warrior assembled without errors.
+func (a *assembler) run(src string) (cells []cell, ok bool) {
+	defer catchTooMany(&ok)
+
+	if !a.firstPass(src) {
+		return nil, false
+	}
+	cells = a.encode()
+	if a.errNum > 0 {
+		return nil, false
+	}
+	return cells, true
+}
+
+// firstPass reads and expands src. It reports false if there is no source.
+func (a *assembler) firstPass(src string) bool {
+	if src == "" {
+		a.report(goexmars.DiagFileOpen, nil, "")
+		return false
+	}
+
+	a.read(src)
+	a.expand()
+	if a.noAssert {
+		a.report(goexmars.DiagMissingAssert, nil, "")
+	}
+	return true
+}
+
+// catchTooMany turns the abort after too many diagnostics into a failed
+// result.
+func catchTooMany(ok *bool) {
+	if r := recover(); r != nil {
+		if _, tooMany := r.(errTooMany); !tooMany {
+			panic(r)
+		}
+		*ok = false
+	}
+}
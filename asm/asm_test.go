@@ -0,0 +1,293 @@
+package asm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+var crossCheckWarriors = map[string]string{
+	"imp": `
+;redcode-94
+;name Imp
+;author A. K. Dewdney
+;assert 1
+MOV 0, 1
+END
+`,
+	"dwarf": `
+;redcode-94
+;name Dwarf
+;strategy Bombs every fourth cell.
+;assert CORESIZE % 4 == 0
+        ORG start
+step    EQU 4
+start   ADD.AB #step, bomb
+        MOV.I  bomb, @bomb
+        JMP    start
+bomb    DAT    #0, #0
+        END
+`,
+	"defaults": `
+;redcode-94
+;assert 1
+	DAT 5
+	DAT #1, <2
+	MOV #1, 2
+	MOV 1, #2
+	MOV 1, 2
+	ADD #1, 2
+	ADD 1, #2
+	MUL 1, 2
+	SLT #1, 2
+	SLT 1, 2
+	LDP 1, 2
+	STP #1, 2
+	JMP 3
+	SPL 0
+	NOP
+	CMP @1, }2
+	SNE *1, {2
+	DJN -1, >3
+	END
+`,
+	"forrof": `
+;redcode-94
+;assert CORESIZE == 8000
+i       FOR 3
+x&i     DAT #i, i*2
+        ROF
+        FOR 0
+        DAT 9
+        ROF
+        JMP x01
+        JMP x03
+        END 3
+`,
+	"multiline-equ": `
+;redcode-94
+;assert 1
+pair    EQU MOV 0, 1
+        EQU MOV 1, 2
+start   pair
+        JMP start
+        END start
+`,
+	"expressions": `
+;redcode-94
+;assert MAXLENGTH >= 10 && MINDISTANCE > 0 || 0
+        DAT #(1+2)*3, #-7 % 3
+        DAT #a=5, #a*a
+        DAT #CORESIZE/2+1, #MAXPROCESSES
+        DAT #1 < 2, #3 >= 4
+        DAT #!0, #2 != 2
+        DAT #CURLINE, #ROUNDS + WARRIORS
+        DAT #VERSION, #PSPACESIZE
+        DAT #-1, #CORESIZE + 5
+        END
+`,
+	"forward-labels": `
+;redcode-94
+;assert 1
+top     JMP later
+        SPL top, later
+later:  DAT 1, 2
+        ORG later
+`,
+	"pin": `
+;redcode-94
+;name Pinned
+;assert 1
+        PIN 17
+        STP.AB #1, #0
+        DAT 0, 0
+        END
+`,
+	"preamble": `
+This text sits before the first redcode line and is ignored.
+;redcode-94
+;name Preamble
+;assert 1
+MOV 0, 1
+;redcode
+anything after the second marker is ignored
+`,
+	"continuation": `
+;redcode-94
+;assert 1
+MOV 0, \
+    1
+ADD.F #1, \
+ 2
+END
+`,
+	"undefined": `
+;redcode-94
+;assert 1
+JMP nowhere
+END
+`,
+	"bad-modifier": `
+;redcode-94
+MOV.Z 0, 1
+END
+`,
+	"incomplete": `
+;redcode-94
+;assert 1
+MOV 0
+END
+`,
+	"too-long": `
+;redcode-94
+;assert 1
+i FOR 120
+DAT i
+ROF
+END
+`,
+	"div-zero": `
+;redcode-94
+;assert 1
+DAT #1/0, 0
+END
+`,
+	"failed-assert": `
+;redcode-94
+;assert CORESIZE == 55440
+MOV 0, 1
+END
+`,
+	"missing-assert": `
+;redcode-94
+MOV 0, 1
+END
+`,
+	"org-outside": `
+;redcode-94
+;assert 1
+ORG 10
+MOV 0, 1
+`,
+	"unclosed-for": `
+;redcode-94
+;assert 1
+FOR 2
+DAT 1
+`,
+	"unopened-rof": `
+;redcode-94
+;assert 1
+DAT 1
+ROF
+END
+`,
+	"dangling-label": `
+;redcode-94
+;assert 1
+DAT 1
+tail
+`,
+	"undefined-in-label": `
+;redcode-94
+;assert 1
+label mov lab, 1
+END
+`,
+	"undefined-twice": `
+;redcode-94
+;assert 1
+mov lab, lab
+END
+`,
+}
+
+func TestAssembleMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	for name, src := range crossCheckWarriors {
+		for _, cfg := range []goexmars.FightConfig{goexmars.DefaultConfig, goexmars.DefaultConfig.SetCoreSize(800).SetMinSep(80).SetMaxWarriorLen(80)} {
+			want, wantErr := goexmars.AssembleParsed(src, cfg)
+			got, gotErr := AssembleParsed(src, cfg)
+
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Fatalf("%s: error mismatch: exmars=%v go=%v", name, wantErr, gotErr)
+			}
+			if wantErr != nil {
+				var wantAsm, gotAsm *goexmars.AssembleError
+				if !errors.As(wantErr, &wantAsm) || !errors.As(gotErr, &gotAsm) {
+					t.Fatalf("%s: expected *AssembleError, got %T and %T", name, wantErr, gotErr)
+				}
+				if wantAsm.Text != gotAsm.Text {
+					t.Fatalf("%s: diagnostics text mismatch:\nexmars:\n%s\ngo:\n%s", name, wantAsm.Text, gotAsm.Text)
+				}
+				if !reflect.DeepEqual(wantAsm.Diagnostics, gotAsm.Diagnostics) {
+					t.Fatalf("%s: diagnostics mismatch:\nexmars: %+v\ngo:     %+v", name, wantAsm.Diagnostics, gotAsm.Diagnostics)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("%s: parsed warrior mismatch:\nexmars:\n%s\ngo:\n%s", name, want.Assembled, got.Assembled)
+			}
+		}
+	}
+}
+
+func TestDiagnosticColumn(t *testing.T) {
+	for name, want := range map[string]int{"undefined": 5, "undefined-in-label": 11, "undefined-twice": 0} {
+		_, err := AssembleParsed(crossCheckWarriors[name], goexmars.DefaultConfig)
+		var asmErr *goexmars.AssembleError
+		if !errors.As(err, &asmErr) || len(asmErr.Diagnostics) == 0 {
+			t.Fatalf("%s: expected diagnostics, got %v", name, err)
+		}
+		d := asmErr.Diagnostics[0]
+		if d.Code != goexmars.DiagUndefinedSymbol || d.Column != want {
+			t.Fatalf("%s: got %+v, want an undefined symbol at column %d", name, d, want)
+		}
+	}
+}
+
+func TestAssembleProgramMetadata(t *testing.T) {
+	p, err := AssembleProgram(crossCheckWarriors["pin"], goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if !p.HasPIN || p.PIN != 17 {
+		t.Fatalf("expected PIN 17, got %d (set=%v)", p.PIN, p.HasPIN)
+	}
+	if p.Warrior.Name != "Pinned" {
+		t.Fatalf("unexpected name %q", p.Warrior.Name)
+	}
+
+	p, err = AssembleProgram(crossCheckWarriors["dwarf"], goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if p.Warrior.Strategy != "Bombs every fourth cell." {
+		t.Fatalf("unexpected strategy %q", p.Warrior.Strategy)
+	}
+	if len(p.Warrior.Commands) != 4 {
+		t.Fatalf("expected 4 commands, got %d", len(p.Warrior.Commands))
+	}
+
+	p, err = AssembleProgram(crossCheckWarriors["forward-labels"], goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if p.Warrior.End != 2 {
+		t.Fatalf("expected ORG to start at 2, got %d", p.Warrior.End)
+	}
+}
+
+func TestAssembleProgramWarnings(t *testing.T) {
+	p, err := AssembleProgram(crossCheckWarriors["missing-assert"], goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if len(p.Diagnostics) != 1 || p.Diagnostics[0].Code != goexmars.DiagMissingAssert {
+		t.Fatalf("expected a missing-assert warning, got %+v", p.Diagnostics)
+	}
+}
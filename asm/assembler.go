@@ -0,0 +1,1090 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BigJk/goexmars"
+)
+
+// opNames lists the pMARS opcodes in pMARS order, followed by the pseudo-opcodes.
+var opNames = [...]string{
+	"MOV", "ADD", "SUB", "MUL", "DIV", "MOD", "JMZ",
+	"JMN", "DJN", "CMP", "SLT", "SPL", "DAT", "JMP",
+	"SEQ", "SNE", "NOP", "LDP", "STP",
+	"ORG", "END", "PIN",
+}
+
+const (
+	opMOV = iota
+	opADD
+	opSUB
+	opMUL
+	opDIV
+	opMOD
+	opJMZ
+	opJMN
+	opDJN
+	opCMP
+	opSLT
+	opSPL
+	opDAT
+	opJMP
+	opSEQ
+	opSNE
+	opNOP
+	opLDP
+	opSTP
+	opORG
+	opEND
+	opPIN
+	opEQU
+)
+
+// numOps is the number of real (non pseudo) opcodes.
+const numOps = opORG
+
+var modNames = [...]string{"A", "B", "AB", "BA", "F", "X", "I"}
+
+const (
+	modA = iota
+	modB
+	modAB
+	modBA
+	modF
+	modX
+	modI
+	numMods
+)
+
+// maxLabelsPerLine is the number of labels pMARS accepts in front of one statement (GRPMAX).
+const maxLabelsPerLine = 7
+
+func opcodeIndex(upper string) int {
+	for i, name := range opNames {
+		if name == upper {
+			return i
+		}
+	}
+	return opEQU
+}
+
+func modifierIndex(upper string) int {
+	for i, name := range modNames {
+		if name == upper {
+			return i
+		}
+	}
+	return numMods
+}
+
+// Results of expanding one line in the first pass.
+const (
+	declError = iota - 1
+	declNil
+	declLabel
+	declValue
+	declCommand
+	declPseudo
+	declFor
+	declROF
+)
+
+// States of the operand parser used in the second pass.
+const (
+	stOp = iota
+	stModAddrExp
+	stModf
+	stAddrExpA
+	stExpFS
+	stAddrExpB
+	stExpr
+)
+
+type source struct {
+	text string
+	loc  int
+}
+
+type line struct {
+	text string
+	src  *source
+	next *line
+	// labels are the labels declared for an expanded instruction line.
+	labels []string
+}
+
+type lineList struct {
+	head, tail *line
+}
+
+func (l *lineList) add(text string, src *source) {
+	n := &line{text: text, src: src}
+	if l.tail == nil {
+		l.head = n
+	} else {
+		l.tail.next = n
+	}
+	l.tail = n
+}
+
+type refKind int
+
+const (
+	refText refKind = iota
+	refLabel
+	refStack
+)
+
+// ref is a symbol table entry: EQU text, a label value or a FOR counter.
+type ref struct {
+	names []string
+	lines *line
+	value int
+	visit bool
+	kind  refKind
+}
+
+type cell struct {
+	op, mod      int
+	aMode, bMode byte
+	a, b         int
+}
+
+// assembler holds the state of a single assembly. It follows the structure of
+// assemble_warrior2 in pmars.c: the first pass expands EQU, FOR/ROF and labels
+// into plain instruction text, the second pass parses and evaluates it.
+type assembler struct {
+	evaluator
+
+	cfg goexmars.FightConfig
+	buf scratch
+
+	refs   []*ref
+	labels []string
+	src    lineList
+	out    lineList
+	cur    *line
+	line   int
+	skip   int
+	more   bool
+	opcode int
+
+	modifier     int
+	lastState    int
+	aExpr, bExpr string
+	fine         bool
+	failed       bool
+
+	noAssert bool
+	offset   int
+	pin      int
+	hasPIN   bool
+
+	diags  []goexmars.Diagnostic
+	text   strings.Builder
+	errNum int
+	kept   []keptError
+
+	// declared holds the labels of the instruction trav just expanded until
+	// it is emitted.
+	declared []string
+	// forLabels are the labels declared in front of a FOR, which belong to
+	// the next instruction.
+	forLabels []string
+	// keepLabels leaves label references in operands as names instead of
+	// replacing them by offsets (PreprocessOptions.KeepLabels).
+	keepLabels bool
+	// operands is set while trav expands the operands of an instruction or
+	// pseudo-opcode.
+	operands bool
+}
+
+func newAssembler(cfg goexmars.FightConfig, warriors int) *assembler {
+	a := &assembler{cfg: cfg, noAssert: true}
+
+	pspace := cfg.PSpaceSize
+	if pspace <= 0 {
+		pspace = cfg.CoreSize / 16
+		if pspace <= 0 {
+			pspace = 1
+		}
+	}
+	predefs := []struct {
+		name  string
+		value int
+	}{
+		{"CORESIZE", cfg.CoreSize},
+		{"MAXPROCESSES", cfg.MaxProcess},
+		{"MAXCYCLES", cfg.Cycles},
+		{"MAXLENGTH", cfg.MaxWarriorLen},
+		{"MINDISTANCE", cfg.MinSep},
+		{"VERSION", pmarsVersion},
+		{"WARRIORS", warriors},
+		{"ROUNDS", cfg.Rounds},
+		{"PSPACESIZE", pspace},
+	}
+	for _, p := range predefs {
+		text := strconv.FormatUint(uint64(uint32(p.value)), 10)
+		a.refs = append(a.refs, &ref{names: []string{p.name}, kind: refText, lines: &line{text: text}})
+	}
+	return a
+}
+
+// pmarsVersion is the value of the predefined VERSION constant.
+const pmarsVersion = 92
+
+func (a *assembler) lookup(name string) *ref {
+	for i := len(a.refs) - 1; i >= 0; i-- {
+		for _, n := range a.refs[i].names {
+			if n == name {
+				return a.refs[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (a *assembler) normalize(v int64) int {
+	size := int64(a.cfg.CoreSize)
+	v %= size
+	if v < 0 {
+		v += size
+	}
+	return int(v)
+}
+
+func (a *assembler) denormalize(v int) int {
+	if v > a.cfg.CoreSize/2 {
+		return v - a.cfg.CoreSize
+	}
+	return v
+}
+
+// read splits the source into lines, handles ';redcode' and ';assert' and
+// drops comments.
+func (a *assembler) read(src string) {
+	lines := 0
+	redcode := 0
+	for cont := true; cont; {
+		a.buf[0] = 0
+		i := 0
+		for {
+			chunk, ok := readChunk(&src, maxLineChars-i)
+			if !ok {
+				cont = false
+				break
+			}
+			n := copy(a.buf[i:maxLineChars-1], chunk)
+			a.buf[i+n] = 0
+			for a.buf[i] != 0 && a.buf[i] != '\n' && a.buf[i] != '\r' {
+				i++
+			}
+			a.buf[i] = 0
+			if i == 0 || a.buf[i-1] != '\\' {
+				break
+			}
+			// The line continues on the next one.
+			i--
+			a.buf[i] = 0
+		}
+		lines++
+
+		text := a.buf.String()
+		i = 0
+		switch typ, _ := nextToken(text, &i); typ {
+		case tokComment:
+			if !a.globalSwitch(text, i, lines) {
+				break
+			}
+			switch redcode {
+			case 0:
+				// Everything before the first ';redcode' line is ignored.
+				a.src = lineList{}
+				redcode++
+			default:
+				cont = false
+			}
+		case tokNone:
+		default:
+			text = stripComment(text)
+			a.src.add(text, &source{text: text, loc: lines})
+		}
+	}
+}
+
+// readChunk reads up to n-1 bytes of s, stopping after a newline.
+func readChunk(s *string, n int) (string, bool) {
+	if n <= 1 || *s == "" {
+		return "", false
+	}
+	end := n - 1
+	if end > len(*s) {
+		end = len(*s)
+	}
+	if i := strings.IndexByte((*s)[:end], '\n'); i >= 0 {
+		end = i + 1
+	}
+	chunk := (*s)[:end]
+	*s = (*s)[end:]
+	return chunk, true
+}
+
+// globalSwitch handles a comment line. It reports whether the line is a
+// ';redcode' marker; ';assert' lines are kept for the first pass.
+func (a *assembler) globalSwitch(s string, idx, loc int) bool {
+	i := idx
+	_, tok := nextToken(s, &i)
+	tok = strings.ToUpper(tok)
+	if tok == "REDCODE" && i == idx+7 {
+		return true
+	}
+	i = skipSpace(s, i)
+	if tok == "ASSERT" {
+		text := s[:i] + stripComment(s[i:])
+		a.src.add(text, &source{text: text, loc: loc})
+	}
+	return false
+}
+
+// expand runs the first pass over the source lines.
+func (a *assembler) expand() {
+	a.more = true
+	a.skip = 0
+	for a.cur = a.src.head; a.cur != nil && a.more; a.cur = a.cur.next {
+		dest := ""
+		switch a.trav(a.cur.text, &dest, declNil) {
+		case declCommand:
+			a.emit(dest)
+		case declROF:
+			a.report(goexmars.DiagUnopenedFOR, a.cur, "")
+		}
+	}
+
+	if len(a.labels) > 0 {
+		names := make([]string, len(a.labels))
+		for i, n := range a.labels {
+			names[len(names)-1-i] = n
+		}
+		a.report(goexmars.DiagDiscardedLabels, nil, strings.Join(names, " "))
+		a.labels = nil
+	}
+}
+
+// emit appends an expanded instruction line from the current source line.
+func (a *assembler) emit(text string) {
+	a.out.add(text, a.cur.src)
+	a.out.tail.labels = a.declared
+	a.declared = nil
+}
+
+// trav expands the rest of a line into dest. wdecl tells what has been seen so
+// far on the line and the result tells what the line turned out to be.
+func (a *assembler) trav(buf string, dest *string, wdecl int) int {
+	idx := 0
+	typ, tok := nextToken(buf, &idx)
+	rest := buf[idx:]
+	nextIsSpace := idx < len(buf) && isSpace(buf[idx])
+
+	switch typ {
+	case tokNone:
+		return wdecl
+
+	case tokComment:
+		if wdecl == declNil && a.skip == 0 {
+			j := idx
+			if _, kw := nextToken(buf, &j); strings.ToUpper(kw) == "ASSERT" {
+				a.trav(buf[j:], dest, declValue)
+				v, st := a.evalExpr(*dest)
+				if st < evalOK {
+					a.report(goexmars.DiagInvalidAssert, a.cur, "")
+				} else {
+					if st == evalOverflow {
+						a.report(goexmars.DiagOverflow, a.cur, "")
+					}
+					a.noAssert = false
+					if v == 0 {
+						a.report(goexmars.DiagAssertionFailed, a.cur, "")
+					}
+				}
+			}
+		}
+		return wdecl
+
+	case tokChar:
+		upper := strings.ToUpper(tok)
+		a.buf.set(upper)
+		switch {
+		case upper == "ROF":
+			if a.skip > 0 {
+				a.skip--
+			} else if wdecl <= declLabel {
+				return declROF
+			} else {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+			}
+
+		case upper == "FOR":
+			if a.skip > 0 {
+				a.skip++
+			} else if wdecl <= declLabel {
+				return a.blockFor(rest, dest)
+			} else {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+			}
+
+		case tok == "CURLINE":
+			if a.skip > 0 {
+				return declNil
+			} else if wdecl > declLabel {
+				s := strconv.Itoa(a.line)
+				if nextIsSpace {
+					s += " "
+				}
+				a.buf.set(s)
+				if concat(dest, s) {
+					return a.trav(rest, dest, wdecl)
+				}
+				a.report(goexmars.DiagBufferOverflow, a.cur, "")
+			} else {
+				a.report(goexmars.DiagMisc, a.cur, "CURLINE is a reserved keyword")
+			}
+
+		case upper == "EQU":
+			if a.skip > 0 {
+				return declNil
+			} else if wdecl <= declLabel {
+				return a.equTable(rest)
+			} else {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+			}
+
+		default:
+			a.opcode = opcodeIndex(upper)
+			if a.opcode < opEQU {
+				if a.skip > 0 {
+					return declNil
+				}
+				if wdecl > declLabel {
+					a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+					break
+				}
+				// The opcode keeps everything up to the next blank, e.g. its
+				// modifier. Like pMARS this only terminates the work buffer
+				// when a blank follows.
+				j := len(upper)
+				for idx < len(buf) && !isSpace(buf[idx]) {
+					if j < maxLineChars-1 {
+						a.buf[j] = buf[idx]
+						j++
+					}
+					idx++
+				}
+				if idx < len(buf) && isSpace(buf[idx]) && j < maxLineChars-2 {
+					a.buf[j] = ' '
+					a.buf[j+1] = 0
+				}
+				if !concat(dest, a.buf.String()) {
+					a.report(goexmars.DiagBufferOverflow, a.cur, "")
+					break
+				}
+				op := a.opcode
+				next := declValue
+				if op >= numOps {
+					next = declPseudo
+				}
+				a.operands = true
+				r := a.trav(buf[idx:], dest, next)
+				a.operands = false
+				if r == declError {
+					return declError
+				}
+				if len(a.labels) > 0 {
+					a.refs = append(a.refs, &ref{names: a.labels, kind: refLabel, value: a.line})
+				}
+				a.declared = a.labels
+				a.labels = nil
+				if op < numOps {
+					if len(a.forLabels) > 0 {
+						a.declared = append(a.forLabels, a.declared...)
+						a.forLabels = nil
+					}
+					a.line++
+				} else if op == opEND {
+					a.more = false
+				}
+				return declCommand
+			}
+
+			if a.skip > 0 {
+				return a.trav(rest, dest, declNil)
+			}
+			return a.symbol(buf, tok, idx, dest, wdecl)
+		}
+
+	default:
+		if a.skip > 0 {
+			return declNil
+		}
+		if wdecl <= declLabel {
+			a.report(goexmars.DiagUnknownToken, a.cur, tok)
+		} else if concat(dest, tok) {
+			return a.trav(rest, dest, wdecl)
+		} else {
+			a.report(goexmars.DiagBufferOverflow, a.cur, "")
+		}
+	}
+	return declError
+}
+
+// symbol handles an identifier in the first pass: '&' concatenation, EQU
+// substitution, label values and new label declarations.
+func (a *assembler) symbol(buf, tok string, idx int, dest *string, wdecl int) int {
+	ok := true
+	for idx+1 < len(buf) && buf[idx] == '&' && isLetter(buf[idx+1]) {
+		idx++
+		typ, name := nextToken(buf, &idx)
+		a.buf.set(name)
+		if typ != tokChar || !ok {
+			break
+		}
+		if r := a.lookup(name); r != nil && r.kind == refStack {
+			a.buf.set(fmt.Sprintf("%02d", r.value))
+			if !concat(&tok, a.buf.String()) {
+				a.report(goexmars.DiagBufferOverflow, a.cur, "")
+			}
+		} else {
+			a.report(goexmars.DiagConcat, a.cur, name)
+			ok = false
+		}
+	}
+	rest := buf[idx:]
+	nextIsSpace := idx < len(buf) && isSpace(buf[idx])
+
+	r := a.lookup(tok)
+	switch {
+	case r != nil && r.kind == refText:
+		if r.visit {
+			a.report(goexmars.DiagRecursiveReference, a.cur, tok)
+			return declError
+		}
+		return a.equSub(rest, dest, wdecl, r)
+
+	case r != nil && wdecl > declLabel:
+		var s string
+		switch {
+		case r.kind == refStack:
+			s = fmt.Sprintf("%02d", r.value)
+		case r.kind == refLabel && a.keepLabels && a.operands:
+			s = tok
+		case wdecl == declPseudo:
+			s = strconv.Itoa(r.value)
+		default:
+			s = strconv.Itoa(r.value - a.line)
+		}
+		if nextIsSpace {
+			s += " "
+		}
+		a.buf.set(s)
+		if concat(dest, s) {
+			return a.trav(rest, dest, wdecl)
+		}
+		a.report(goexmars.DiagBufferOverflow, a.cur, "")
+
+	case r != nil:
+		a.report(goexmars.DiagRedefinition, a.cur, tok)
+
+	case wdecl <= declLabel:
+		if len(a.labels) < maxLabelsPerLine {
+			a.labels = append(a.labels, tok)
+		} else {
+			a.report(goexmars.DiagTooManyLabels, a.cur, tok)
+		}
+		// A colon directly after a label is ignored.
+		if strings.HasPrefix(rest, ":") {
+			rest = rest[1:]
+		}
+		return a.trav(rest, dest, declLabel)
+
+	default:
+		if nextIsSpace {
+			tok += " "
+		}
+		if concat(dest, tok) {
+			return a.trav(rest, dest, wdecl)
+		}
+		a.report(goexmars.DiagBufferOverflow, a.cur, "")
+	}
+	return declError
+}
+
+// blockFor expands a FOR/ROF block. The last label in front of FOR becomes the
+// loop counter; any others are ordinary labels.
+func (a *assembler) blockFor(expr string, dest *string) int {
+	var counter []string
+	if n := len(a.labels); n > 0 {
+		counter = []string{a.labels[n-1]}
+		if n > 1 {
+			a.refs = append(a.refs, &ref{names: a.labels[:n-1], kind: refLabel, value: a.line})
+			a.forLabels = append(a.forLabels, a.labels[:n-1]...)
+		}
+		a.labels = nil
+	}
+
+	*dest = ""
+	a.trav(expr, dest, declValue)
+
+	count, st := a.evalExpr(*dest)
+	switch {
+	case st < evalOK:
+		if st == evalDivZero {
+			a.report(goexmars.DiagDivisionByZero, a.cur, "")
+		} else {
+			a.report(goexmars.DiagBadExpression, a.cur, "")
+		}
+	case count <= 0:
+		if st == evalOverflow {
+			a.report(goexmars.DiagOverflow, a.cur, "")
+		}
+		// Skip the block up to the matching ROF.
+		a.skip++
+	default:
+		if st == evalOverflow {
+			a.report(goexmars.DiagOverflow, a.cur, "")
+		}
+
+		stack := &ref{names: counter, kind: refStack, visit: true}
+		a.refs = append(a.refs, stack)
+
+		start := a.cur
+		for stack.value = 1; a.more && stack.value <= int(uint16(count)); stack.value++ {
+			for a.cur = start; a.more; {
+				if a.cur.next == nil {
+					a.report(goexmars.DiagUnclosedROF, nil, "")
+					a.more = false
+					break
+				}
+				a.cur = a.cur.next
+				*dest = ""
+				r := a.trav(a.cur.text, dest, declNil)
+				if r == declROF {
+					break
+				}
+				if r == declCommand {
+					a.emit(*dest)
+				}
+			}
+		}
+
+		for i := len(a.refs) - 1; i >= 0; i-- {
+			if a.refs[i].kind == refStack {
+				a.refs = append(a.refs[:i], a.refs[i+1:]...)
+				break
+			}
+		}
+	}
+	return declFor
+}
+
+// equTable records an EQU definition. Following lines that start with EQU
+// continue a multi-line definition.
+func (a *assembler) equTable(expr string) int {
+	if len(a.labels) == 0 {
+		a.report(goexmars.DiagIllegalAppend, a.cur, "")
+		return declValue
+	}
+
+	r := &ref{names: a.labels, kind: refText}
+	a.refs = append(a.refs, r)
+	a.labels = nil
+
+	r.lines = &line{text: expr, src: a.cur.src}
+	last := r.lines
+	for a.cur.next != nil {
+		i := 0
+		if _, tok := nextToken(a.cur.next.text, &i); strings.ToUpper(tok) != "EQU" {
+			break
+		}
+		a.cur = a.cur.next
+		last.next = &line{text: a.cur.text[i:], src: a.cur.src}
+		last = last.next
+	}
+	return declValue
+}
+
+// equSub substitutes the text of an EQU definition and continues with the
+// rest of the line.
+func (a *assembler) equSub(expr string, dest *string, wdecl int, r *ref) int {
+	r.visit = true
+	saved := a.cur
+	a.cur = r.lines
+	wdecl = a.trav(a.cur.text, dest, wdecl)
+	for a.cur.next != nil && a.more {
+		if a.skip == 0 && wdecl == declCommand {
+			a.emit(*dest)
+		}
+		a.cur = a.cur.next
+		*dest = ""
+		wdecl = a.trav(a.cur.text, dest, declNil)
+	}
+	a.cur = saved
+	if expr != "" && isSpace(expr[0]) {
+		concat(dest, " ")
+	}
+	r.visit = false
+	return a.trav(expr, dest, wdecl)
+}
+
+// encode runs the second pass and returns the assembled instructions.
+func (a *assembler) encode() []cell {
+	if a.line > a.cfg.MaxWarriorLen {
+		a.report(goexmars.DiagTooManyInstructions, nil, strconv.Itoa(a.line-a.cfg.MaxWarriorLen))
+	}
+	instLen := a.line
+	if instLen == 0 {
+		a.report(goexmars.DiagNoInstructions, nil, "")
+		return nil
+	}
+
+	cells := make([]cell, 0, instLen)
+	a.line = 0
+	for a.cur = a.out.head; a.cur != nil; a.cur = a.cur.next {
+		c := a.parseInstruction(a.cur.text)
+		if a.errNum != 0 {
+			continue
+		}
+		if a.aExpr == "" {
+			a.aExpr = "0"
+		}
+		if a.bExpr == "" {
+			a.bExpr = "0"
+		}
+
+		if a.opcode >= numOps {
+			v, st := a.evalExpr(a.bExpr)
+			if !a.checkEval(st) {
+				continue
+			}
+			switch a.opcode {
+			case opORG:
+				a.offset = a.normalize(v)
+			case opPIN:
+				a.pin = int(v)
+				a.hasPIN = true
+			default:
+				// END 0 is ignored; END after ORG is ignored with a warning.
+				if v != 0 {
+					if a.offset != 0 {
+						a.report(goexmars.DiagIgnoredEnd, a.cur, "")
+					} else {
+						a.offset = a.normalize(v)
+					}
+				}
+			}
+			continue
+		}
+
+		av, ast := a.evalExpr(a.aExpr)
+		if ast < evalOK {
+			a.checkEval(ast)
+			continue
+		}
+		bv, bst := a.evalExpr(a.bExpr)
+		if bst < evalOK {
+			a.checkEval(bst)
+			continue
+		}
+		if ast == evalOverflow || bst == evalOverflow {
+			a.report(goexmars.DiagOverflow, a.cur, "")
+		}
+		c.a = a.normalize(av)
+		c.b = a.normalize(bv)
+		cells = append(cells, c)
+		a.line++
+	}
+
+	if a.offset < 0 || a.offset >= instLen {
+		a.report(goexmars.DiagBadOffset, nil, "")
+	}
+	return cells
+}
+
+// checkEval reports evaluation problems and returns whether a value is usable.
+func (a *assembler) checkEval(st int) bool {
+	switch st {
+	case evalDivZero:
+		a.report(goexmars.DiagDivisionByZero, a.cur, "")
+		return false
+	case evalBad:
+		a.report(goexmars.DiagBadExpression, a.cur, "")
+		return false
+	case evalOverflow:
+		a.report(goexmars.DiagOverflow, a.cur, "")
+	}
+	return true
+}
+
+// parseInstruction parses one expanded line and applies the ICWS'94 rules for
+// missing operands and default modifiers.
+func (a *assembler) parseInstruction(text string) cell {
+	c := cell{aMode: '$', bMode: '$'}
+	a.modifier = numMods
+	a.aExpr, a.bExpr = "", ""
+	a.failed = false
+	a.automaton(text, stOp, &c)
+
+	if a.opcode >= numOps {
+		return c
+	}
+
+	if !a.fine && !a.failed {
+		a.report(goexmars.DiagSyntax, a.cur, opNames[a.opcode])
+	} else if a.bExpr == "" {
+		switch a.opcode {
+		case opDAT:
+			c.bMode = c.aMode
+			a.bExpr = a.aExpr
+			c.aMode = '#'
+			a.aExpr = "0"
+		case opSPL, opJMP, opNOP:
+			c.bMode = '$'
+			a.bExpr = "0"
+		default:
+			a.report(goexmars.DiagIncompleteOperand, a.cur, opNames[a.opcode])
+		}
+	}
+
+	if a.modifier == numMods {
+		a.modifier = defaultModifier(a.opcode, c.aMode, c.bMode)
+	}
+	c.op = a.opcode
+	c.mod = a.modifier
+	return c
+}
+
+func defaultModifier(op int, aMode, bMode byte) int {
+	switch op {
+	case opDAT, opNOP:
+		return modF
+	case opMOV, opCMP, opSEQ, opSNE:
+		switch {
+		case aMode == '#':
+			return modAB
+		case bMode == '#':
+			return modB
+		default:
+			return modI
+		}
+	case opADD, opSUB, opMUL, opDIV, opMOD:
+		switch {
+		case aMode == '#':
+			return modAB
+		case bMode == '#':
+			return modB
+		default:
+			return modF
+		}
+	case opLDP, opSTP, opSLT:
+		if aMode == '#' {
+			return modAB
+		}
+		return modB
+	default:
+		return modB
+	}
+}
+
+// automaton is the operand parser of the second pass.
+func (a *assembler) automaton(expr string, state int, c *cell) {
+	a.lastState = state
+	idx := 0
+	typ, tok := nextToken(expr, &idx)
+	rest := expr[idx:]
+
+	switch state {
+	case stOp:
+		a.fine = false
+		if typ != tokChar {
+			a.report(goexmars.DiagMissing, a.cur, "opcode")
+			return
+		}
+		a.opcode = opcodeIndex(strings.ToUpper(tok))
+		switch {
+		case a.opcode < numOps:
+			a.automaton(rest, stModAddrExp, c)
+		case a.opcode < opEQU:
+			a.automaton(rest, stExpr, c)
+		default:
+			a.report(goexmars.DiagMissing, a.cur, "opcode")
+		}
+
+	case stModAddrExp, stAddrExpA:
+		a.fine = false
+		switch typ {
+		case tokModifier:
+			if state == stModAddrExp {
+				a.automaton(rest, stModf, c)
+			} else {
+				a.report(goexmars.DiagMissing, a.cur, "A-term")
+			}
+		case tokAddr:
+			c.aMode = tok[0]
+			a.automaton(rest, stExpFS, c)
+		case tokNumber, tokExpr:
+			a.automaton(expr, stExpFS, c)
+		case tokChar:
+			if !a.substitute(tok, rest, state, c) {
+				if len(tok) == 1 {
+					a.automaton(expr, stExpFS, c)
+				} else {
+					a.report(goexmars.DiagUndefinedSymbol, a.cur, tok)
+				}
+			}
+		case tokNone:
+		default:
+			if state == stModAddrExp {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+			} else {
+				a.report(goexmars.DiagMissing, a.cur, "A-term")
+			}
+		}
+
+	case stModf:
+		a.fine = false
+		switch typ {
+		case tokChar:
+			if a.modifier = modifierIndex(strings.ToUpper(tok)); a.modifier < numMods {
+				a.automaton(rest, stAddrExpA, c)
+			} else {
+				a.report(goexmars.DiagMissing, a.cur, "modifier")
+			}
+		case tokNone:
+		default:
+			a.report(goexmars.DiagMissing, a.cur, "modifier")
+		}
+
+	case stAddrExpB:
+		a.fine = false
+		switch typ {
+		case tokAddr:
+			c.bMode = tok[0]
+			a.automaton(rest, stExpr, c)
+		case tokNumber, tokExpr:
+			a.automaton(expr, stExpr, c)
+		case tokChar:
+			if !a.substitute(tok, rest, state, c) {
+				if len(tok) == 1 {
+					a.automaton(expr, stExpr, c)
+				} else {
+					a.report(goexmars.DiagUndefinedSymbol, a.cur, tok)
+				}
+			}
+		case tokNone:
+		default:
+			a.report(goexmars.DiagMissing, a.cur, "B-term")
+		}
+
+	case stExpFS, stExpr:
+		target := &a.aExpr
+		if state == stExpr {
+			target = &a.bExpr
+		}
+		switch typ {
+		case tokFieldSep:
+			if state == stExpFS {
+				a.automaton(rest, stAddrExpB, c)
+			} else {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+			}
+		case tokAddr:
+			if tok != ">" && tok != "<" && tok != "*" {
+				a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+				return
+			}
+			a.appendExpr(target, tok)
+			a.automaton(rest, state, c)
+		case tokNumber:
+			if !concat(&tok, " ") {
+				a.report(goexmars.DiagBufferOverflow, a.cur, "")
+			}
+			a.appendExpr(target, tok)
+			a.automaton(rest, state, c)
+		case tokExpr:
+			a.appendExpr(target, tok)
+			a.automaton(rest, state, c)
+		case tokChar:
+			if a.substitute(tok, rest, state, c) {
+				return
+			}
+			if len(tok) != 1 {
+				a.report(goexmars.DiagUndefinedSymbol, a.cur, tok)
+				return
+			}
+			// A single letter is a register.
+			if !concat(target, tok) {
+				a.report(goexmars.DiagBufferOverflow, a.cur, "")
+				return
+			}
+			a.fine = true
+			a.automaton(rest, state, c)
+		case tokNone:
+		default:
+			a.report(goexmars.DiagImproperPlacement, a.cur, tok)
+		}
+	}
+}
+
+func (a *assembler) appendExpr(target *string, tok string) {
+	if !concat(target, tok) {
+		a.report(goexmars.DiagBufferOverflow, a.cur, "")
+	}
+	a.fine = true
+}
+
+// substitute expands a symbol met in the second pass, which happens for
+// forward label references and EQUs defined after their use. It returns false
+// if the symbol is unknown.
+func (a *assembler) substitute(tok, rest string, state int, c *cell) bool {
+	r := a.lookup(tok)
+	if r == nil {
+		return false
+	}
+	if r.visit {
+		a.report(goexmars.DiagRecursiveReference, a.cur, tok)
+		return true
+	}
+
+	r.visit = true
+	if r.kind == refText {
+		a.automaton(r.lines.text, state, c)
+	} else {
+		v := r.value - a.line
+		if state == stExpr && a.opcode >= numOps {
+			v = r.value
+		}
+		a.automaton(strconv.Itoa(v), state, c)
+	}
+	r.visit = false
+
+	next := a.lastState
+	if state == stExpr {
+		next = stExpr
+	}
+	a.automaton(rest, next, c)
+	return true
+}
+
+// listing renders cells in the same layout as the exmars cellview output.
+func (a *assembler) listing(cells []cell) string {
+	var b strings.Builder
+	for _, c := range cells {
+		fmt.Fprintf(&b, "%3s%c%-2s %c%6d, %c%6d %4s\n",
+			opNames[c.op], '.', modNames[c.mod],
+			c.aMode, a.denormalize(c.a),
+			c.bMode, a.denormalize(c.b),
+			"")
+	}
+	fmt.Fprintf(&b, "END %d\n", a.offset)
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigJk/goexmars"
+)
+
+// maxKeptErrors is the number of distinct line errors after which pMARS gives up (ERRMAX).
+const maxKeptErrors = 9
+
+// errTooMany aborts the assembly once maxKeptErrors has been reached.
+type errTooMany struct{}
+
+type keptError struct {
+	code goexmars.DiagnosticCode
+	loc  int
+}
+
+var diagnosticMessages = map[goexmars.DiagnosticCode]string{
+	goexmars.DiagBad88Format:         "Bad '88 format at token '%s'",
+	goexmars.DiagInvalid88:           "Invalid '88 format. Proper format: '%s'",
+	goexmars.DiagIncompleteOperand:   "Incomplete operand at instruction '%s'",
+	goexmars.DiagExtraTokens:         "Ignored, extra tokens in line '%s'",
+	goexmars.DiagUndefinedLabel:      "Undefined label '%s'",
+	goexmars.DiagIllegalAppend:       "Attempting to append a string to an undefined label",
+	goexmars.DiagBufferOverflow:      "Buffer overflow. Substitution is too complex",
+	goexmars.DiagIllegalConcat:       "Illegal use of string concatenation '%s'",
+	goexmars.DiagTooManyLabels:       "Too many labels for a declaration (last: '%s')",
+	goexmars.DiagUnopenedFOR:         "Unopened FOR",
+	goexmars.DiagUnclosedROF:         "Unclosed ROF",
+	goexmars.DiagNoInstructions:      "No instructions",
+	goexmars.DiagDiscardedLabels:     "Discarding these labels: '%s'",
+	goexmars.DiagUnknownToken:        "Unrecognized or improper placement of token: '%s'",
+	goexmars.DiagUndefinedSymbol:     "Undefined label or symbol: '%s'",
+	goexmars.DiagExpectNumber:        "Expecting a number",
+	goexmars.DiagSyntax:              "Syntax error",
+	goexmars.DiagTooManyInstructions: "Too many instructions (about %s more)",
+	goexmars.DiagMissing:             "Missing '%s'",
+	goexmars.DiagRecursiveReference:  "Recursive reference of label '%s'",
+	goexmars.DiagBadExpression:       "Bad expression",
+	goexmars.DiagDivisionByZero:      "Division by zero",
+	goexmars.DiagOverflow:            "Arithmetic overflow detected",
+	goexmars.DiagMissingAssert:       "Missing ';assert'. Warrior may not work with the current setting",
+	goexmars.DiagBadOffset:           "Execution starts from outside of the program",
+	goexmars.DiagConcat:              "Unable to derefer and concatenate symbol '%s'",
+	goexmars.DiagIgnoredEnd:          "Both opcodes ORG and END are used. Ignoring END",
+	goexmars.DiagInvalidAssert:       "Invalid ';assert' parameter",
+	goexmars.DiagImproperPlacement:   "Improper placement of '%s'",
+	goexmars.DiagRedefinition:        "Ignored, redefinition of label '%s'",
+	goexmars.DiagAssertionFailed:     "Assertion in this line fails",
+	goexmars.DiagFileOpen:            "Unable to open file '%s'",
+	goexmars.DiagMisc:                "%s",
+}
+
+func diagnosticSeverity(code goexmars.DiagnosticCode) goexmars.Severity {
+	switch code {
+	case goexmars.DiagIllegalAppend,
+		goexmars.DiagUnclosedROF,
+		goexmars.DiagNoInstructions,
+		goexmars.DiagDiscardedLabels,
+		goexmars.DiagOverflow,
+		goexmars.DiagMissingAssert,
+		goexmars.DiagBadOffset,
+		goexmars.DiagIgnoredEnd,
+		goexmars.DiagInvalidAssert,
+		goexmars.DiagExtraTokens,
+		goexmars.DiagRedefinition,
+		goexmars.DiagUndefinedLabel:
+		return goexmars.SeverityWarning
+	default:
+		return goexmars.SeverityError
+	}
+}
+
+func diagnosticMessage(code goexmars.DiagnosticCode, arg string) string {
+	format, ok := diagnosticMessages[code]
+	if !ok {
+		return arg
+	}
+	if strings.Contains(format, "%s") {
+		return fmt.Sprintf(format, arg)
+	}
+	return format
+}
+
+// report records a diagnostic the same way errprn does in pmars.c, including
+// the per-line de-duplication and the text layout.
+func (a *assembler) report(code goexmars.DiagnosticCode, at *line, arg string) {
+	a.failed = true
+	sev := diagnosticSeverity(code)
+	msg := diagnosticMessage(code, arg)
+	if sev == goexmars.SeverityError {
+		a.errNum++
+	}
+
+	label := "Error"
+	if sev == goexmars.SeverityWarning {
+		label = "Warning"
+	}
+
+	d := goexmars.Diagnostic{Severity: sev, Code: code, Message: msg}
+	if at != nil && at.src != nil {
+		for i := range a.kept {
+			if a.kept[i].loc == at.src.loc && a.kept[i].code == code {
+				return
+			}
+		}
+		d.Line = at.src.loc
+		if arg != "" {
+			d.Column = diagColumn(at.src.text, arg)
+		}
+		fmt.Fprintf(&a.text, "%s in line %d: '%s'\n\t\t\t\t%s\n", label, at.src.loc, at.src.text, msg)
+		a.diags = append(a.diags, d)
+		a.kept = append(a.kept, keptError{code: code, loc: at.src.loc})
+	} else {
+		fmt.Fprintf(&a.text, "%s:\n\t\t\t\t%s\n", label, msg)
+		a.diags = append(a.diags, d)
+	}
+
+	if len(a.kept) >= maxKeptErrors {
+		a.text.WriteString("\nToo many errors or warnings.\nProgram aborted.\n")
+		panic(errTooMany{})
+	}
+}
+
+// diagColumn returns the 1-based column of arg in src, matched as a whole
+// token, or 0 if arg does not occur or occurs more than once, as the
+// diagnostic does not say which occurrence it is about.
+func diagColumn(src, arg string) int {
+	column := 0
+	for from := 0; ; from++ {
+		i := strings.Index(src[from:], arg)
+		if i < 0 {
+			return column
+		}
+		from += i
+		end := from + len(arg)
+		if from > 0 && isWordChar(arg[0]) && isWordChar(src[from-1]) ||
+			end < len(src) && isWordChar(arg[len(arg)-1]) && isWordChar(src[end]) {
+			continue
+		}
+		if column > 0 {
+			return 0
+		}
+		column = from + 1
+	}
+}
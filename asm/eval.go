@@ -0,0 +1,82 @@
+package asm
+
+import (
+	"strconv"
+
+	"github.com/BigJk/goexmars"
+)
+
+// EvalExpression evaluates a Redcode expression exactly like the assembler
+// evaluates ;assert lines, FOR counts and operands before they are reduced
+// modulo CoreSize. It is the pure-Go counterpart of goexmars.EvalExpression,
+// which runs pMARS eval_expr.
+//
+// The predefined constants (CORESIZE, MAXLENGTH, WARRIORS, ...) take their
+// values from cfg as for a single warrior, and defines are substituted like
+// EQU constants, overriding predefined names; their names must pass
+// goexmars.CheckDefines. Arithmetic is 64-bit with C semantics, so division
+// truncates towards zero, and comparisons and the logical operators yield 0
+// or 1. Single letters are the pMARS registers:
+// "a=CORESIZE/3" stores and returns a value that later uses of a in the same
+// expression read.
+//
+// Failures are returned as *goexmars.AssembleError with the diagnostic the
+// assembler reports, e.g. DiagBadExpression, DiagDivisionByZero or
+// DiagUndefinedSymbol. An overflow is only a warning in pMARS: the wrapped
+// value is returned together with an error holding a DiagOverflow warning.
+func EvalExpression(expr string, cfg goexmars.FightConfig, defines map[string]int) (int, error) {
+	cfg.Rounds = 1
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	if err := goexmars.CheckDefines(defines); err != nil {
+		return 0, err
+	}
+
+	a := newAssembler(cfg, 1)
+	for name, v := range defines {
+		a.refs = append(a.refs, &ref{names: []string{name}, kind: refText, lines: &line{text: strconv.Itoa(v)}})
+	}
+	a.cur = &line{text: expr, src: &source{text: expr, loc: 1}}
+
+	v, ok := a.evalLine(expr)
+	if !ok || a.failed {
+		return int(v), &goexmars.AssembleError{Diagnostics: a.diags, Text: a.text.String()}
+	}
+	return int(v), nil
+}
+
+// evalLine substitutes the symbols of expr and evaluates it, reporting
+// problems on the current line.
+func (a *assembler) evalLine(expr string) (v int64, ok bool) {
+	defer catchTooMany(&ok)
+
+	dest := ""
+	if a.trav(expr, &dest, declValue) == declError || a.errNum > 0 {
+		return 0, false
+	}
+	// Names left after substitution are neither defines nor registers.
+	for i := 0; i < len(dest); {
+		typ, tok := nextToken(dest, &i)
+		if typ == tokNone {
+			break
+		}
+		if typ == tokChar && len(tok) > 1 {
+			a.report(goexmars.DiagUndefinedSymbol, a.cur, tok)
+			return 0, false
+		}
+	}
+
+	v, st := a.evalExpr(dest)
+	switch st {
+	case evalBad:
+		a.report(goexmars.DiagBadExpression, a.cur, "")
+		return 0, false
+	case evalDivZero:
+		a.report(goexmars.DiagDivisionByZero, a.cur, "")
+		return 0, false
+	case evalOverflow:
+		a.report(goexmars.DiagOverflow, a.cur, "")
+	}
+	return v, true
+}
@@ -0,0 +1,202 @@
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestEvalExpression(t *testing.T) {
+	defines := map[string]int{"dx": 21, "step": -4}
+	cases := []struct {
+		expr string
+		want int
+	}{
+		{"(CORESIZE/3)+1", 2667},
+		{"CORESIZE % 4 == 0", 1},
+		{"MAXLENGTH <= 100 && dx", 1},
+		{"dx*2", 42},
+		{"2--dx", 23},
+		{"step", -4},
+		{"-7/2", -3},
+		{"!0 || 0", 1},
+		{"a=5*2", 10},
+		{"(a=3)+a", 6},
+		{"1+2*3-4", 3},
+	}
+	for _, tc := range cases {
+		got, err := EvalExpression(tc.expr, goexmars.DefaultConfig, defines)
+		if err != nil {
+			t.Fatalf("EvalExpression(%q) returned error: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Fatalf("EvalExpression(%q) = %d, want %d", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestEvalExpressionDefinesOverridePredefined(t *testing.T) {
+	got, err := EvalExpression("CORESIZE", goexmars.DefaultConfig, map[string]int{"CORESIZE": 55440})
+	if err != nil || got != 55440 {
+		t.Fatalf("EvalExpression = %d, %v; want 55440", got, err)
+	}
+}
+
+func TestEvalExpressionRejectsInvalidDefineNames(t *testing.T) {
+	testlib.Configure(t)
+	lib, err := goexmars.DefaultLibrary()
+	if err != nil {
+		t.Fatalf("load library: %v", err)
+	}
+
+	for _, name := range []string{"", "a", "x y", "1+2", "9lives", "mov", "EQU", "ok\x00"} {
+		defines := map[string]int{"fine": 1, name: 2}
+		_, goErr := EvalExpression("1", goexmars.DefaultConfig, defines)
+		_, libErr := lib.EvalExpression("1", goexmars.DefaultConfig, defines)
+		want := fmt.Sprintf("invalid define name %q", name)
+		if goErr == nil || goErr.Error() != want || libErr == nil || libErr.Error() != want {
+			t.Fatalf("define %q: go error %v, exmars error %v, want %q from both", name, goErr, libErr, want)
+		}
+	}
+}
+
+func TestEvalExpressionErrors(t *testing.T) {
+	cases := map[string]goexmars.DiagnosticCode{
+		"foo+1": goexmars.DiagUndefinedSymbol,
+		"1/0":   goexmars.DiagDivisionByZero,
+		"1+":    goexmars.DiagBadExpression,
+	}
+	for expr, code := range cases {
+		_, err := EvalExpression(expr, goexmars.DefaultConfig, nil)
+		var asmErr *goexmars.AssembleError
+		if !errors.As(err, &asmErr) || len(asmErr.Diagnostics) != 1 || asmErr.Diagnostics[0].Code != code {
+			t.Fatalf("EvalExpression(%q) error = %v, want diagnostic %v", expr, err, code)
+		}
+	}
+
+	v, err := EvalExpression("9223372036854775807+1", goexmars.DefaultConfig, nil)
+	var asmErr *goexmars.AssembleError
+	if !errors.As(err, &asmErr) || asmErr.Diagnostics[0].Code != goexmars.DiagOverflow ||
+		asmErr.Diagnostics[0].Severity != goexmars.SeverityWarning {
+		t.Fatalf("expected an overflow warning, got %v", err)
+	}
+	if v != -9223372036854775808 {
+		t.Fatalf("expected the wrapped value, got %d", v)
+	}
+}
+
+func TestEvalExpressionMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	cfg := goexmars.DefaultConfig
+	for _, expr := range []string{
+		"(CORESIZE/3)+1",
+		"MAXPROCESSES*2-MINDISTANCE",
+		"-7/2",
+		"-7%3",
+		"PSPACESIZE+WARRIORS",
+		"1==1==1",
+		"3<2<1",
+		"(a=7)*a+b",
+		"10-2-3",
+		"2*3%4",
+	} {
+		got, err := EvalExpression(expr, cfg, nil)
+		if err != nil {
+			t.Fatalf("EvalExpression(%q) returned error: %v", expr, err)
+		}
+
+		w, err := goexmars.AssembleParsed(fmt.Sprintf(";redcode-94\n;assert 1\nDAT #%s, #0\n", expr), cfg)
+		if err != nil {
+			t.Fatalf("exmars failed to assemble %q: %v", expr, err)
+		}
+		mod := func(v int) int { return (v%cfg.CoreSize + cfg.CoreSize) % cfg.CoreSize }
+		if mod(got) != mod(w.Commands[0].A) {
+			t.Fatalf("EvalExpression(%q) = %d, exmars assembled %d", expr, got, w.Commands[0].A)
+		}
+	}
+}
+
+// TestEvalExpressionRandomMatchesExmars compares EvalExpression with pMARS
+// eval_expr on random expressions.
+func TestEvalExpressionRandomMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+	lib, err := goexmars.DefaultLibrary()
+	if err != nil {
+		t.Fatalf("load library: %v", err)
+	}
+
+	defines := map[string]int{"step": -4, "gap": 97, "dx": 21, "CORESIZE": 55440}
+	r := rand.New(rand.NewSource(1))
+	for _, cfg := range []goexmars.FightConfig{goexmars.DefaultConfig, goexmars.TinyConfig, goexmars.LimitedProcessConfig} {
+		for i := 0; i < 2000; i++ {
+			expr := randomExpression(r, 4)
+			got, gotErr := EvalExpression(expr, cfg, defines)
+			want, wantErr := lib.EvalExpression(expr, cfg, defines)
+			if evalOutcome(gotErr) != evalOutcome(wantErr) {
+				t.Fatalf("EvalExpression(%q) error = %v, exmars error = %v", expr, gotErr, wantErr)
+			}
+			if evalOutcome(gotErr) != "error" && got != want {
+				t.Fatalf("EvalExpression(%q) = %d, exmars = %d", expr, got, want)
+			}
+		}
+	}
+}
+
+// evalOutcome classifies an EvalExpression error as none, overflow or error.
+func evalOutcome(err error) string {
+	var asmErr *goexmars.AssembleError
+	switch {
+	case err == nil:
+		return "none"
+	case errors.As(err, &asmErr) && len(asmErr.Errors()) == 0:
+		return "overflow"
+	}
+	return "error"
+}
+
+var randomExpressionOps = []string{"+", "-", "*", "/", "%", "==", "!=", "<", ">", "<=", ">=", "&&", "||"}
+
+var randomExpressionNames = []string{"CORESIZE", "MAXPROCESSES", "MAXCYCLES", "MAXLENGTH", "MINDISTANCE", "WARRIORS", "ROUNDS", "PSPACESIZE", "step", "gap", "dx"}
+
+// randomExpression returns a random expression using numbers, predefined
+// constants, the defines of TestEvalExpressionRandomMatchesExmars, registers, unary and binary operators and register assignments.
+func randomExpression(r *rand.Rand, depth int) string {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(5) {
+		case 0:
+			return randomExpressionNames[r.Intn(len(randomExpressionNames))]
+		case 1:
+			return string(rune('a' + r.Intn(26)))
+		case 2:
+			return strconv.FormatInt(r.Int63n(1<<32), 10)
+		default:
+			return strconv.Itoa(r.Intn(10))
+		}
+	}
+
+	sub := func() string { return randomExpression(r, depth-1) }
+	switch r.Intn(6) {
+	case 0:
+		return "-" + sub()
+	case 1:
+		return "!" + sub()
+	case 2:
+		return "(" + sub() + ")"
+	case 3:
+		return "(" + string(rune('a'+r.Intn(26))) + "=" + sub() + ")"
+	default:
+		sep := ""
+		if r.Intn(2) == 0 {
+			sep = " "
+		}
+		op := randomExpressionOps[r.Intn(len(randomExpressionOps))]
+		return strings.Join([]string{sub(), op, sub()}, sep)
+	}
+}
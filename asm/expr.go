@@ -0,0 +1,267 @@
+package asm
+
+import (
+	"math"
+	"strconv"
+)
+
+// Expression evaluation status, as in pMARS eval.c.
+const (
+	evalOverflow = 1
+	evalOK       = 0
+	evalBad      = -1
+	evalDivZero  = -2
+)
+
+// Two-character operators. Single-character operators use their byte value.
+const (
+	opEqual = iota
+	opNotEqual
+	opGreaterEq
+	opLessEq
+	opAnd
+	opOr
+	opIdent
+)
+
+func precedence(op int) int {
+	switch op {
+	case '*', '/', '%':
+		return 5
+	case '+', '-':
+		return 4
+	case '>', '<', opEqual, opNotEqual, opGreaterEq, opLessEq:
+		return 3
+	case opAnd:
+		return 2
+	case opOr:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func terminal(s string, i int) bool {
+	return i >= len(s) || s[i] == ')'
+}
+
+// evaluator implements the pMARS expression evaluator including its
+// single-letter registers, which persist for the whole assembly.
+type evaluator struct {
+	regs     [26]int64
+	err      int
+	saveOper int
+}
+
+// evalExpr evaluates expr and returns the value and the pMARS status code.
+func (e *evaluator) evalExpr(expr string) (int64, int) {
+	e.err = evalOK
+	pos, v := e.eval(-1, 0, opIdent, expr, 0)
+	if pos < len(expr) {
+		e.err = evalBad
+	}
+	return v, e.err
+}
+
+func (e *evaluator) calc(x, y int64, op int) int64 {
+	switch op {
+	case '+':
+		if e.err == evalOK && ((x > 0 && y > 0 && x > math.MaxInt64-y) || (x <= 0 && y < 0 && x < math.MinInt64-y)) {
+			e.err = evalOverflow
+		}
+		return x + y
+	case '-':
+		if e.err == evalOK && ((x > 0 && y < 0 && x > math.MaxInt64+y) || (x <= 0 && y > 0 && x < math.MinInt64+y)) {
+			e.err = evalOverflow
+		}
+		return x - y
+	case '/':
+		if y == 0 {
+			e.err = evalDivZero
+			return 0
+		}
+		return x / y
+	case '*':
+		if e.err == evalOK && x != 0 && y != 0 && x != -1 && y != -1 {
+			var overflow bool
+			if (x > 0) == (y > 0) {
+				overflow = math.MaxInt64/y/x == 0
+			} else {
+				overflow = math.MinInt64/y/x == 0
+			}
+			if overflow {
+				e.err = evalOverflow
+			}
+		}
+		return x * y
+	case '%':
+		if y == 0 {
+			e.err = evalDivZero
+			return 0
+		}
+		return x % y
+	case opAnd:
+		return boolValue(x != 0 && y != 0)
+	case opOr:
+		return boolValue(x != 0 || y != 0)
+	case opEqual:
+		return boolValue(x == y)
+	case opNotEqual:
+		return boolValue(x != y)
+	case '<':
+		return boolValue(x < y)
+	case '>':
+		return boolValue(x > y)
+	case opLessEq:
+		return boolValue(x <= y)
+	case opGreaterEq:
+		return boolValue(x >= y)
+	case opIdent:
+		return y
+	default:
+		e.err = evalBad
+		return 0
+	}
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func advance(s string, i, n int) int {
+	if i+n > len(s) {
+		return len(s)
+	}
+	return i + n
+}
+
+func getOp(s string, i int) (int, int) {
+	ch := at(s, i)
+	i = advance(s, i, 1)
+	switch ch {
+	case '&', '|', '=', '!':
+		next := at(s, i)
+		i = advance(s, i, 1)
+		switch {
+		case ch == '&' && next == '&':
+			return i, opAnd
+		case ch == '|' && next == '|':
+			return i, opOr
+		case ch == '=' && next == '=':
+			return i, opEqual
+		case ch == '!' && next == '=':
+			return i, opNotEqual
+		}
+		// pMARS leaves the operator unset here, which in practice is zero.
+		return i, opEqual
+	case '<':
+		if at(s, i) == '=' {
+			return i + 1, opLessEq
+		}
+		return i, '<'
+	case '>':
+		if at(s, i) == '=' {
+			return i + 1, opGreaterEq
+		}
+		return i, '>'
+	default:
+		return i, int(ch)
+	}
+}
+
+func (e *evaluator) getReg(s string, i, reg int) (int, int64) {
+	i = skipSpace(s, i)
+	if at(s, i) == '=' && at(s, i+1) != '=' {
+		var v int64
+		i, v = e.eval(-1, 0, opIdent, s, i+1)
+		e.regs[reg] = v
+		return i, v
+	}
+	return i, e.regs[reg]
+}
+
+func (e *evaluator) getVal(s string, i int) (int, int64) {
+	i = skipSpace(s, i)
+	ch := at(s, i)
+	switch {
+	case ch == '(':
+		var v int64
+		i, v = e.eval(-1, 0, opIdent, s, i+1)
+		if at(s, i) != ')' {
+			e.err = evalBad
+		}
+		return advance(s, i, 1), v
+	case ch == '-':
+		i, v := e.getVal(s, i+1)
+		return i, -v
+	case ch == '!':
+		i, v := e.getVal(s, i+1)
+		return i, boolValue(v == 0)
+	case ch == '+':
+		return e.getVal(s, i+1)
+	case isLetter(ch):
+		reg := ch
+		if reg >= 'a' {
+			reg -= 'a' - 'A'
+		}
+		return e.getReg(s, i+1, int(reg-'A'))
+	}
+
+	start := i
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i == start {
+		// pMARS leaves the value unset here. Use a non-zero value so a
+		// following division reports the bad expression, as it usually does.
+		e.err = evalBad
+		return i, 1
+	}
+	v, err := strconv.ParseInt(s[start:i], 10, 64)
+	if err != nil {
+		v = math.MaxInt64
+	}
+	return i, v
+}
+
+func (e *evaluator) eval(prevPrec int, val1 int64, oper1 int, s string, i int) (int, int64) {
+	i, val2 := e.getVal(s, i)
+	i = skipSpace(s, i)
+	if terminal(s, i) {
+		return i, e.calc(val1, val2, oper1)
+	}
+	i, oper2 := getOp(s, i)
+	e.saveOper = 0
+
+	var result int64
+	prec1, prec2 := precedence(oper1), precedence(oper2)
+	if prec1 >= prec2 {
+		if prec2 >= prevPrec || prec1 <= prevPrec {
+			i, result = e.eval(prec1, e.calc(val1, val2, oper1), oper2, s, i)
+		} else {
+			result = e.calc(val1, val2, oper1)
+			e.saveOper = oper2
+		}
+	} else {
+		var result2 int64
+		i, result2 = e.eval(prec1, val2, oper2, s, i)
+		result = e.calc(val1, result2, oper1)
+
+		// opEqual is zero, so like pMARS a deferred "==" is dropped here.
+		if e.saveOper != 0 && precedence(e.saveOper) >= prevPrec {
+			i, result = e.eval(prec2, result, e.saveOper, s, i)
+			e.saveOper = 0
+		}
+	}
+	return i, result
+}
@@ -0,0 +1,154 @@
+package asm
+
+// Token types returned by nextToken. They mirror get_token in pmars.c.
+const (
+	tokNone = iota
+	tokAddr
+	tokFieldSep
+	tokComment
+	tokModifier
+	tokExpr
+	tokSpace
+	tokChar
+	tokNumber
+	tokAppend
+	tokMisc
+)
+
+// maxLineChars is the pMARS line and substitution buffer size (MAXALLCHAR).
+const maxLineChars = 256
+
+const (
+	addrSymbols = "#$@<>*{}"
+	exprSymbols = "()/+-%!="
+)
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentStart(c byte) bool {
+	return isLetter(c) || c == '_'
+}
+
+func isWordChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func contains(set string, c byte) bool {
+	for i := 0; i < len(set); i++ {
+		if set[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// nextToken reads the token starting at or after s[*pos] and advances *pos past it.
+func nextToken(s string, pos *int) (int, string) {
+	src := skipSpace(s, *pos)
+	start := src
+	typ := tokNone
+
+	switch {
+	case src >= len(s):
+	case isDigit(s[src]):
+		for src < len(s) && isDigit(s[src]) {
+			src++
+		}
+		typ = tokNumber
+	case isIdentStart(s[src]):
+		for src < len(s) && (isIdentStart(s[src]) || isDigit(s[src])) {
+			src++
+		}
+		typ = tokChar
+	default:
+		ch := s[src]
+		next := byte(0)
+		if src+1 < len(s) {
+			next = s[src+1]
+		}
+		switch {
+		case contains(exprSymbols, ch):
+			typ = tokExpr
+		case contains(addrSymbols, ch):
+			typ = tokAddr
+		case ch == '&':
+			if next == '&' {
+				src++
+				typ = tokExpr
+			} else {
+				typ = tokAppend
+			}
+		case ch == ';':
+			typ = tokComment
+		case ch == ',':
+			typ = tokFieldSep
+		case ch == '.':
+			typ = tokModifier
+		case ch == '|' && next == '|':
+			src++
+			typ = tokExpr
+		default:
+			typ = tokMisc
+		}
+		src++
+	}
+
+	*pos = src
+	return typ, s[start:src]
+}
+
+// concat appends b to *a unless the result would overflow the pMARS line buffer.
+func concat(a *string, b string) bool {
+	if len(*a)+len(b) >= maxLineChars {
+		return false
+	}
+	*a += b
+	return true
+}
+
+// stripComment removes everything from the first ';' on.
+func stripComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ';' {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// scratch mirrors the fixed mars->buf work buffer of pMARS. The assembler
+// reads it back as a C string, so bytes left over from longer earlier
+// contents can show up when a write does not terminate it.
+type scratch [maxLineChars]byte
+
+// set copies s into the buffer and terminates it, like strcpy.
+func (b *scratch) set(s string) {
+	n := copy(b[:maxLineChars-1], s)
+	b[n] = 0
+}
+
+func (b *scratch) String() string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b[:])
+}
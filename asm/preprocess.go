@@ -0,0 +1,90 @@
+package asm
+
+import (
+	"strings"
+
+	"github.com/BigJk/goexmars"
+)
+
+// Preprocessed is a warrior after the text expansion of the first pass.
+type Preprocessed struct {
+	// Source is the expanded source, one line per entry of Lines.
+	Source string
+	// Lines maps every expanded line back to the original source.
+	Lines []PreprocessedLine
+	// Diagnostics contains the warnings reported during expansion.
+	Diagnostics []goexmars.Diagnostic
+}
+
+// PreprocessedLine is a single line of expanded source.
+type PreprocessedLine struct {
+	// Text is the expanded line, starting with its labels.
+	Text string
+	// Labels are the labels declared for the line.
+	Labels []string
+	// SourceLine is the 1-based line of the original source the text comes
+	// from. Lines produced by a multi-line EQU map to its definition, except
+	// for the last one, which the line using the EQU completes.
+	SourceLine int
+}
+
+// PreprocessOptions configures PreprocessWithOptions.
+type PreprocessOptions struct {
+	// KeepLabels keeps all label references in operands by name. By
+	// default, as in pMARS, references to labels declared on earlier lines
+	// are replaced by their relative offset and only forward references are
+	// left for the second pass. Both forms assemble to the same warrior.
+	KeepLabels bool
+}
+
+// Preprocess runs the text expansion stages of the pMARS assembler (expand,
+// blkfor and equsub in pmars.c) and returns the source before it is encoded
+// into instructions.
+//
+// FOR/ROF blocks are unrolled, '&' concatenations and EQU constants are
+// substituted and comments are dropped. Every instruction and pseudo-opcode
+// keeps the labels declared for it, and labels in front of a FOR go to the
+// first instruction after it. As in pMARS, references to labels declared on
+// earlier lines are already replaced by their relative offset; use
+// PreprocessWithOptions with KeepLabels to keep them by name.
+//
+// Expansion errors are returned as *goexmars.AssembleError, like
+// AssembleProgram does.
+func Preprocess(src string, cfg goexmars.FightConfig) (Preprocessed, error) {
+	return PreprocessWithOptions(src, cfg, PreprocessOptions{})
+}
+
+// PreprocessWithOptions is Preprocess with options.
+func PreprocessWithOptions(src string, cfg goexmars.FightConfig, opts PreprocessOptions) (Preprocessed, error) {
+	cfg.Rounds = 1
+	if err := cfg.Validate(); err != nil {
+		return Preprocessed{}, err
+	}
+
+	a := newAssembler(cfg, 1)
+	a.keepLabels = opts.KeepLabels
+	if !a.preprocess(src) || a.errNum > 0 {
+		return Preprocessed{}, &goexmars.AssembleError{Diagnostics: a.diags, Text: a.text.String()}
+	}
+
+	var p Preprocessed
+	var b strings.Builder
+	for l := a.out.head; l != nil; l = l.next {
+		text := strings.TrimSpace(l.text)
+		if len(l.labels) > 0 {
+			text = strings.Join(l.labels, " ") + " " + text
+		}
+		p.Lines = append(p.Lines, PreprocessedLine{Text: text, Labels: l.labels, SourceLine: l.src.loc})
+		b.WriteString(text)
+		b.WriteByte('\n')
+	}
+	p.Source = b.String()
+	p.Diagnostics = a.diags
+	return p, nil
+}
+
+// preprocess runs the first pass and reports whether it was not aborted.
+func (a *assembler) preprocess(src string) (ok bool) {
+	defer catchTooMany(&ok)
+	return a.firstPass(src)
+}
@@ -0,0 +1,186 @@
+package asm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+const preprocessWarrior = `;redcode-94
+;name Preprocess
+;assert 1
+step EQU 4
+     ORG start
+start ADD.AB #step, bomb
+i    FOR 3
+x&i  DAT #i, #step*i
+     ROF
+     MOV.I bomb, @bomb ; bomb away
+     JMP start
+bomb DAT #0, #0
+     END
+`
+
+func TestPreprocess(t *testing.T) {
+	p, err := Preprocess(preprocessWarrior, goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("Preprocess returned error: %v", err)
+	}
+
+	want := []PreprocessedLine{
+		{Text: "ORG start", SourceLine: 5},
+		{Text: "start ADD.AB #4,bomb", Labels: []string{"start"}, SourceLine: 6},
+		{Text: "x01 DAT #01,#4*01", Labels: []string{"x01"}, SourceLine: 8},
+		{Text: "x02 DAT #02,#4*02", Labels: []string{"x02"}, SourceLine: 8},
+		{Text: "x03 DAT #03,#4*03", Labels: []string{"x03"}, SourceLine: 8},
+		{Text: "MOV.I bomb,@bomb", SourceLine: 10},
+		{Text: "JMP -5", SourceLine: 11},
+		{Text: "bomb DAT #0,#0", Labels: []string{"bomb"}, SourceLine: 12},
+		{Text: "END", SourceLine: 13},
+	}
+	if !reflect.DeepEqual(p.Lines, want) {
+		t.Fatalf("unexpected lines:\n got %+v\nwant %+v", p.Lines, want)
+	}
+	if len(p.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", p.Diagnostics)
+	}
+
+	// The expanded source assembles to the same warrior.
+	orig, err := AssembleParsed(preprocessWarrior, goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed returned error: %v", err)
+	}
+	expanded, err := AssembleParsed(";redcode-94\n;assert 1\n"+p.Source, goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed of the expanded source returned error: %v", err)
+	}
+	if expanded.End != orig.End || !reflect.DeepEqual(expanded.Commands, orig.Commands) {
+		t.Fatalf("expanded source assembles to %v, want %v", expanded.Commands, orig.Commands)
+	}
+}
+
+// TestPreprocessMatchesExmars assembles the expanded source of every
+// cross-check warrior with exmars and compares it with exmars' result for
+// the original source.
+func TestPreprocessMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	for name, src := range crossCheckWarriors {
+		for _, cfg := range []goexmars.FightConfig{goexmars.DefaultConfig, goexmars.DefaultConfig.SetCoreSize(800).SetMinSep(80).SetMaxWarriorLen(80)} {
+			want, err := goexmars.AssembleParsed(src, cfg)
+			if err != nil {
+				continue
+			}
+			for _, opts := range []PreprocessOptions{{}, {KeepLabels: true}} {
+				p, err := PreprocessWithOptions(src, cfg, opts)
+				if err != nil {
+					t.Fatalf("%s: Preprocess(%+v) returned error: %v", name, opts, err)
+				}
+				got, err := goexmars.AssembleParsed(";redcode-94\n;assert 1\n"+p.Source, cfg)
+				if err != nil {
+					t.Fatalf("%s: exmars failed to assemble the expanded source (%+v): %v\n%s", name, opts, err, p.Source)
+				}
+				if got.End != want.End || !reflect.DeepEqual(got.Commands, want.Commands) {
+					t.Fatalf("%s: expanded source (%+v) assembles to\n%s\nwant\n%s", name, opts, got.Assembled, want.Assembled)
+				}
+			}
+		}
+	}
+}
+
+func TestPreprocessMultiLineEQU(t *testing.T) {
+	p, err := Preprocess(`;redcode-94
+;assert 1
+pair EQU MOV 0, 1
+     EQU JMP -1
+go   pair
+`, goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("Preprocess returned error: %v", err)
+	}
+	want := []PreprocessedLine{
+		{Text: "go MOV 0,1", Labels: []string{"go"}, SourceLine: 3},
+		{Text: "JMP -1", SourceLine: 5},
+	}
+	if !reflect.DeepEqual(p.Lines, want) {
+		t.Fatalf("unexpected lines:\n got %+v\nwant %+v", p.Lines, want)
+	}
+}
+
+func TestPreprocessForLabels(t *testing.T) {
+	src := `;redcode-94
+;assert 1
+     JMP a
+a i  FOR 2
+     DAT i, a
+     ROF
+     JMP a
+`
+	orig, err := AssembleParsed(src, goexmars.DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed returned error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts PreprocessOptions
+		want []PreprocessedLine
+	}{
+		{
+			name: "offsets",
+			want: []PreprocessedLine{
+				{Text: "JMP a", SourceLine: 3},
+				{Text: "a DAT 01,0", Labels: []string{"a"}, SourceLine: 5},
+				{Text: "DAT 02,-1", SourceLine: 5},
+				{Text: "JMP -2", SourceLine: 7},
+			},
+		},
+		{
+			name: "keep labels",
+			opts: PreprocessOptions{KeepLabels: true},
+			want: []PreprocessedLine{
+				{Text: "JMP a", SourceLine: 3},
+				{Text: "a DAT 01,a", Labels: []string{"a"}, SourceLine: 5},
+				{Text: "DAT 02,a", SourceLine: 5},
+				{Text: "JMP a", SourceLine: 7},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := PreprocessWithOptions(src, goexmars.DefaultConfig, tt.opts)
+			if err != nil {
+				t.Fatalf("PreprocessWithOptions returned error: %v", err)
+			}
+			if !reflect.DeepEqual(p.Lines, tt.want) {
+				t.Fatalf("unexpected lines:\n got %+v\nwant %+v", p.Lines, tt.want)
+			}
+
+			expanded, err := AssembleParsed(";redcode-94\n;assert 1\n"+p.Source, goexmars.DefaultConfig)
+			if err != nil {
+				t.Fatalf("AssembleParsed of the expanded source returned error: %v", err)
+			}
+			if !reflect.DeepEqual(expanded.Commands, orig.Commands) {
+				t.Fatalf("expanded source assembles to %v, want %v", expanded.Commands, orig.Commands)
+			}
+		})
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	_, err := Preprocess(";redcode-94\n;assert 1\n ROF\nMOV 0, 1\n", goexmars.DefaultConfig)
+	var asmErr *goexmars.AssembleError
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *goexmars.AssembleError, got %v", err)
+	}
+	if len(asmErr.Diagnostics) == 0 || asmErr.Diagnostics[0].Code != goexmars.DiagUnopenedFOR || asmErr.Diagnostics[0].Line != 3 {
+		t.Fatalf("unexpected diagnostics: %+v", asmErr.Diagnostics)
+	}
+
+	if _, err := Preprocess("MOV 0, 1", goexmars.FightConfig{}); err == nil {
+		t.Fatalf("expected an invalid config to fail")
+	}
+}
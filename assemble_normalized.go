@@ -6,7 +6,12 @@ package goexmars
 // Unlike Assemble, this output can omit metadata and/or the END line and is
 // rendered via the ParsedWarrior formatter.
 func AssembleNormalized(warrior string, cfg FightConfig, opts RedcodeFormatOptions) (string, error) {
-	parsed, err := AssembleParsed(warrior, cfg)
+	return AssembleNormalizedWithEngine(DefaultEngine, warrior, cfg, opts)
+}
+
+// AssembleNormalizedWithEngine is AssembleNormalized assembling with e.
+func AssembleNormalizedWithEngine(e Engine, warrior string, cfg FightConfig, opts RedcodeFormatOptions) (string, error) {
+	parsed, err := AssembleParsedWithEngine(e, warrior, cfg)
 	if err != nil {
 		return "", err
 	}
@@ -14,6 +14,15 @@ type Benchmark struct {
 	Warriors []ParsedWarrior
 	// Config is used for benchmark fights and for ScoreString assembly/parsing.
 	Config FightConfig
+	// Engine runs the fights and ScoreString assembly. Nil means DefaultEngine.
+	Engine Engine
+}
+
+func (b Benchmark) engine() Engine {
+	if b.Engine == nil {
+		return DefaultEngine
+	}
+	return b.Engine
 }
 
 // BenchmarkScore is the aggregated result of fighting against a benchmark set.
@@ -61,7 +70,7 @@ func (b Benchmark) Score(warrior ParsedWarrior) (BenchmarkScore, error) {
 
 	candidate := warrior.String()
 	for i, opponent := range b.Warriors {
-		result, err := Fight([]string{candidate, opponent.String()}, cfg)
+		result, err := b.engine().Fight([]string{candidate, opponent.String()}, cfg)
 		if err != nil {
 			return BenchmarkScore{}, fmt.Errorf("fight vs benchmark warrior %d: %w", i, err)
 		}
@@ -81,7 +90,7 @@ func (b Benchmark) ScoreString(warrior string) (BenchmarkScore, error) {
 	if cfg == (FightConfig{}) {
 		cfg = DefaultConfig
 	}
-	parsed, err := AssembleParsed(warrior, cfg)
+	parsed, err := AssembleParsedWithEngine(b.engine(), warrior, cfg)
 	if err != nil {
 		return BenchmarkScore{}, err
 	}
@@ -92,6 +101,12 @@ func (b Benchmark) ScoreString(warrior string) (BenchmarkScore, error) {
 //
 // Files are loaded in lexicographic filename order for deterministic results.
 func BenchmarkFromFolder(folder string, cfg FightConfig) (Benchmark, error) {
+	return BenchmarkFromFolderWithEngine(DefaultEngine, folder, cfg)
+}
+
+// BenchmarkFromFolderWithEngine is BenchmarkFromFolder assembling with e.
+// The returned benchmark also fights on e.
+func BenchmarkFromFolderWithEngine(e Engine, folder string, cfg FightConfig) (Benchmark, error) {
 	entries, err := os.ReadDir(folder)
 	if err != nil {
 		return Benchmark{}, err
@@ -115,7 +130,7 @@ func BenchmarkFromFolder(folder string, cfg FightConfig) (Benchmark, error) {
 		if err != nil {
 			return Benchmark{}, fmt.Errorf("read %s: %w", path, err)
 		}
-		parsed, err := AssembleParsed(string(src), cfg)
+		parsed, err := AssembleParsedWithEngine(e, string(src), cfg)
 		if err != nil {
 			return Benchmark{}, fmt.Errorf("assemble %s: %w", path, err)
 		}
@@ -125,5 +140,6 @@ func BenchmarkFromFolder(folder string, cfg FightConfig) (Benchmark, error) {
 	return Benchmark{
 		Warriors: warriors,
 		Config:   cfg,
+		Engine:   e,
 	}, nil
 }
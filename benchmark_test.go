@@ -4,10 +4,12 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
 )
 
 func TestBenchmarkScore(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -45,7 +47,7 @@ END
 }
 
 func TestBenchmarkScoreString(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -76,8 +78,26 @@ END
 	}
 }
 
+func TestBenchmarkScoreIgnoresAsserts(t *testing.T) {
+	testlib.Configure(t)
+
+	parsed, err := AssembleParsed(";assert CORESIZE==8000\nMOV 0, 1\n", DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed failed: %v", err)
+	}
+
+	cfg := DefaultConfig.SetCoreSize(800).SetCycles(8000).SetMaxWarriorLen(10).SetMinSep(10)
+	bm := Benchmark{
+		Warriors: []ParsedWarrior{parsed},
+		Config:   cfg,
+	}
+	if _, err := bm.Score(parsed); err != nil {
+		t.Fatalf("Score re-evaluated the warrior's asserts: %v", err)
+	}
+}
+
 func TestBenchmarkFromFolder(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	dir := t.TempDir()
 
@@ -0,0 +1,245 @@
+package goexmars
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Binary layout of a Command: a little-endian 16-bit word holding the opcode
+// (bits 0-4), modifier (5-7), A-mode (8-10) and B-mode (11-13), followed by A
+// and B as zigzag varints. Most commands take 4 bytes.
+const (
+	commandWordSize = 2
+	opCodeBits      = 5
+	modifierBits    = 3
+	modeBits        = 3
+)
+
+// warriorMagic starts every encoded ParsedWarrior, followed by the format
+// version.
+var warriorMagic = [2]byte{'G', 'W'}
+
+// warriorBinaryVersion is the ParsedWarrior encoding version.
+const warriorBinaryVersion = 1
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+// AppendBinary appends the binary encoding of c to b.
+func (c Command) AppendBinary(b []byte) ([]byte, error) {
+	if int(c.OpCode) >= OpCodeCount || int(c.Modifier) >= ModifierCount ||
+		int(c.AddressingModeA) >= AddressingModeCount || int(c.AddressingModeB) >= AddressingModeCount {
+		return b, fmt.Errorf("invalid command encoding: %d.%d %d %d", c.OpCode, c.Modifier, c.AddressingModeA, c.AddressingModeB)
+	}
+	word := uint16(c.OpCode) |
+		uint16(c.Modifier)<<opCodeBits |
+		uint16(c.AddressingModeA)<<(opCodeBits+modifierBits) |
+		uint16(c.AddressingModeB)<<(opCodeBits+modifierBits+modeBits)
+	b = binary.LittleEndian.AppendUint16(b, word)
+	b = binary.AppendVarint(b, int64(c.A))
+	b = binary.AppendVarint(b, int64(c.B))
+	return b, nil
+}
+
+// MarshalBinary encodes c in the compact binary form described above.
+func (c Command) MarshalBinary() ([]byte, error) {
+	return c.AppendBinary(make([]byte, 0, commandWordSize+2*binary.MaxVarintLen32))
+}
+
+// UnmarshalBinary decodes a single command produced by MarshalBinary.
+func (c *Command) UnmarshalBinary(data []byte) error {
+	cmd, n, err := decodeCommand(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("%d trailing bytes after command", len(data)-n)
+	}
+	*c = cmd
+	return nil
+}
+
+// decodeCommand decodes a command from the start of data and returns the
+// number of bytes read.
+func decodeCommand(data []byte) (Command, int, error) {
+	if len(data) < commandWordSize {
+		return Command{}, 0, errShortBuffer
+	}
+	word := binary.LittleEndian.Uint16(data)
+	if word>>(opCodeBits+modifierBits+2*modeBits) != 0 {
+		return Command{}, 0, fmt.Errorf("invalid command word 0x%04x", word)
+	}
+	cmd := Command{
+		OpCode:          OpCode(word & (1<<opCodeBits - 1)),
+		Modifier:        Modifier(word >> opCodeBits & (1<<modifierBits - 1)),
+		AddressingModeA: AddressingMode(word >> (opCodeBits + modifierBits) & (1<<modeBits - 1)),
+		AddressingModeB: AddressingMode(word >> (opCodeBits + modifierBits + modeBits) & (1<<modeBits - 1)),
+	}
+	if int(cmd.OpCode) >= OpCodeCount || int(cmd.Modifier) >= ModifierCount {
+		return Command{}, 0, fmt.Errorf("invalid command word 0x%04x", word)
+	}
+
+	n := commandWordSize
+	a, m := binary.Varint(data[n:])
+	if m <= 0 {
+		return Command{}, 0, fmt.Errorf("A operand: %w", errShortBuffer)
+	}
+	n += m
+	bv, m := binary.Varint(data[n:])
+	if m <= 0 {
+		return Command{}, 0, fmt.Errorf("B operand: %w", errShortBuffer)
+	}
+	n += m
+	cmd.A, cmd.B = int(a), int(bv)
+	return cmd, n, nil
+}
+
+// AppendBinary appends the binary encoding of w to b.
+//
+// The encoding starts with the bytes "GW" and a format version, followed by
+// End, Name, Author, the commands and the remaining metadata. Assembled is
+// not stored.
+func (w ParsedWarrior) AppendBinary(b []byte) ([]byte, error) {
+	appendString := func(b []byte, s string) []byte {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		return append(b, s...)
+	}
+
+	b = append(b, warriorMagic[0], warriorMagic[1], warriorBinaryVersion)
+	b = binary.AppendVarint(b, int64(w.End))
+	b = appendString(b, w.Name)
+	b = appendString(b, w.Author)
+	b = binary.AppendUvarint(b, uint64(len(w.Commands)))
+	for i, cmd := range w.Commands {
+		var err error
+		if b, err = cmd.AppendBinary(b); err != nil {
+			return b, fmt.Errorf("command %d: %w", i, err)
+		}
+	}
+	for _, s := range []string{w.Strategy, w.Version, w.Date, w.URL, w.Redcode} {
+		b = appendString(b, s)
+	}
+	b = binary.AppendUvarint(b, uint64(len(w.Asserts)))
+	for _, a := range w.Asserts {
+		b = appendString(b, a)
+	}
+	b = binary.AppendUvarint(b, uint64(len(w.Extra)))
+	for _, f := range w.Extra {
+		b = appendString(b, f.Key)
+		b = appendString(b, f.Value)
+	}
+	return b, nil
+}
+
+// MarshalBinary encodes w in a compact, versioned binary form.
+func (w ParsedWarrior) MarshalBinary() ([]byte, error) {
+	return w.AppendBinary(make([]byte, 0, 16+len(w.Name)+len(w.Author)+4*len(w.Commands)))
+}
+
+// UnmarshalBinary decodes a warrior produced by MarshalBinary.
+func (w *ParsedWarrior) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != warriorMagic[0] || data[1] != warriorMagic[1] {
+		return errors.New("not an encoded warrior")
+	}
+	if data[2] != warriorBinaryVersion {
+		return fmt.Errorf("unsupported warrior encoding version %d", data[2])
+	}
+	n := 3
+
+	end, m := binary.Varint(data[n:])
+	if m <= 0 {
+		return fmt.Errorf("end: %w", errShortBuffer)
+	}
+	n += m
+
+	readString := func(field string) (string, error) {
+		l, m := binary.Uvarint(data[n:])
+		if m <= 0 || l > uint64(len(data)-n-m) {
+			return "", fmt.Errorf("%s: %w", field, errShortBuffer)
+		}
+		n += m
+		s := string(data[n : n+int(l)])
+		n += int(l)
+		return s, nil
+	}
+	name, err := readString("name")
+	if err != nil {
+		return err
+	}
+	author, err := readString("author")
+	if err != nil {
+		return err
+	}
+
+	count, m := binary.Uvarint(data[n:])
+	// Every command takes at least 4 bytes, which bounds the allocation.
+	if m <= 0 || count > uint64(len(data)-n-m)/4 {
+		return fmt.Errorf("command count: %w", errShortBuffer)
+	}
+	n += m
+	cmds := make([]Command, count)
+	for i := range cmds {
+		cmd, m, err := decodeCommand(data[n:])
+		if err != nil {
+			return fmt.Errorf("command %d: %w", i, err)
+		}
+		cmds[i] = cmd
+		n += m
+	}
+	out := ParsedWarrior{Name: name, Author: author, End: int(end), Commands: cmds}
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{"strategy", &out.Strategy},
+		{"version", &out.Version},
+		{"date", &out.Date},
+		{"url", &out.URL},
+		{"redcode", &out.Redcode},
+	} {
+		if *f.dst, err = readString(f.name); err != nil {
+			return err
+		}
+	}
+
+	// Every string takes at least one byte.
+	readCount := func(field string) (int, error) {
+		count, m := binary.Uvarint(data[n:])
+		if m <= 0 || count > uint64(len(data)-n-m) {
+			return 0, fmt.Errorf("%s count: %w", field, errShortBuffer)
+		}
+		n += m
+		return int(count), nil
+	}
+	asserts, err := readCount("assert")
+	if err != nil {
+		return err
+	}
+	for range asserts {
+		a, err := readString("assert")
+		if err != nil {
+			return err
+		}
+		out.Asserts = append(out.Asserts, a)
+	}
+	extras, err := readCount("extra")
+	if err != nil {
+		return err
+	}
+	for range extras {
+		var f MetadataField
+		if f.Key, err = readString("extra key"); err != nil {
+			return err
+		}
+		if f.Value, err = readString("extra value"); err != nil {
+			return err
+		}
+		out.Extra = append(out.Extra, f)
+	}
+	if n != len(data) {
+		return fmt.Errorf("%d trailing bytes after warrior", len(data)-n)
+	}
+
+	*w = out
+	return nil
+}
@@ -0,0 +1,109 @@
+package goexmars
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommandBinaryRoundTrip(t *testing.T) {
+	for op := 0; op < OpCodeCount; op++ {
+		for mod := 0; mod < ModifierCount; mod++ {
+			for mode := 0; mode < AddressingModeCount; mode++ {
+				cmd := Command{
+					OpCode:          OpCode(op),
+					Modifier:        Modifier(mod),
+					AddressingModeA: AddressingMode(mode),
+					A:               -mode * 1000,
+					AddressingModeB: AddressingMode(AddressingModeCount - 1 - mode),
+					B:               op * 70000,
+				}
+				data, err := cmd.MarshalBinary()
+				if err != nil {
+					t.Fatalf("MarshalBinary(%v): %v", cmd, err)
+				}
+				var got Command
+				if err := got.UnmarshalBinary(data); err != nil {
+					t.Fatalf("UnmarshalBinary(%v): %v", cmd, err)
+				}
+				if got != cmd {
+					t.Fatalf("round trip: got %v, want %v", got, cmd)
+				}
+			}
+		}
+	}
+}
+
+func TestCommandBinarySize(t *testing.T) {
+	data, err := Command{OpCode: OpCodeMOV, Modifier: ModifierI, A: 0, B: 1}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Fatalf("expected 4 bytes, got %d: %x", len(data), data)
+	}
+}
+
+func TestCommandBinaryErrors(t *testing.T) {
+	if _, err := (Command{OpCode: OpCode(OpCodeCount)}).MarshalBinary(); err == nil {
+		t.Fatal("expected error for invalid opcode")
+	}
+
+	var cmd Command
+	for _, data := range [][]byte{
+		nil,
+		{0x00},
+		{0x00, 0x00},
+		{0x00, 0x00, 0x00},
+		{0x1f, 0x00, 0x00, 0x00},
+		{0x00, 0xc0, 0x00, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+	} {
+		if err := cmd.UnmarshalBinary(data); err == nil {
+			t.Errorf("expected error for %x", data)
+		}
+	}
+}
+
+func TestParsedWarriorBinaryRoundTrip(t *testing.T) {
+	w := ParsedWarrior{
+		Name:   "Dwarf",
+		Author: "A. K. Dewdney",
+		End:    1,
+		Commands: []Command{
+			{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingImmediate, B: 0},
+			{OpCode: OpCodeADD, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 4, AddressingModeB: AddressingDirect, B: -1},
+			{OpCode: OpCodeMOV, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingBIndirect, B: -2},
+			{OpCode: OpCodeJMP, Modifier: ModifierB, AddressingModeA: AddressingDirect, A: -2, AddressingModeB: AddressingDirect, B: 0},
+		},
+		Assembled: "dropped",
+		Strategy:  "bomb every fourth cell\nthen repeat",
+		Redcode:   "redcode-94",
+		Asserts:   []string{"CORESIZE == 8000"},
+		Extra:     []MetadataField{{Key: "hill", Value: "94nop"}},
+	}
+	data, err := w.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got ParsedWarrior
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	w.Assembled = ""
+	if !reflect.DeepEqual(got, w) {
+		t.Fatalf("round trip: got %+v, want %+v", got, w)
+	}
+
+	for n := 0; n < len(data); n++ {
+		if err := got.UnmarshalBinary(data[:n]); err == nil {
+			t.Fatalf("expected error for truncated data of length %d", n)
+		}
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[2] = warriorBinaryVersion + 1
+	if err := got.UnmarshalBinary(bad); err == nil {
+		t.Fatal("expected error for unknown version")
+	}
+}
@@ -0,0 +1,280 @@
+package goexmars
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Operand is an instruction operand for a Builder: an addressing mode and
+// either a number or a label or constant name plus an offset.
+type Operand struct {
+	Mode AddressingMode
+	// Label names a label or an Equ constant. If it is empty the operand is
+	// the plain number Offset.
+	Label string
+	// Offset is the number, or the value added to the resolved Label.
+	Offset int
+}
+
+// Imm returns an immediate operand #v.
+func Imm(v int) Operand { return Operand{Mode: AddressingImmediate, Offset: v} }
+
+// Dir returns a direct operand $v.
+func Dir(v int) Operand { return Operand{Mode: AddressingDirect, Offset: v} }
+
+// AInd returns an A-indirect operand *v.
+func AInd(v int) Operand { return Operand{Mode: AddressingAIndirect, Offset: v} }
+
+// BInd returns a B-indirect operand @v.
+func BInd(v int) Operand { return Operand{Mode: AddressingBIndirect, Offset: v} }
+
+// APre returns an A-predecrement operand {v.
+func APre(v int) Operand { return Operand{Mode: AddressingAIndirectPre, Offset: v} }
+
+// BPre returns a B-predecrement operand <v.
+func BPre(v int) Operand { return Operand{Mode: AddressingBIndirectPre, Offset: v} }
+
+// APost returns an A-postincrement operand }v.
+func APost(v int) Operand { return Operand{Mode: AddressingAIndirectPost, Offset: v} }
+
+// BPost returns a B-postincrement operand >v.
+func BPost(v int) Operand { return Operand{Mode: AddressingBIndirectPost, Offset: v} }
+
+// Lbl returns a direct operand referring to a label or Equ constant. Use
+// With to change the addressing mode and Plus to add an offset, e.g.
+// Lbl("ptr").With(AddressingBIndirect) for @ptr.
+func Lbl(name string) Operand { return Operand{Mode: AddressingDirect, Label: name} }
+
+// With returns o with addressing mode m.
+func (o Operand) With(m AddressingMode) Operand {
+	o.Mode = m
+	return o
+}
+
+// Plus returns o with n added to its value, e.g. Lbl("ptr").Plus(1) for
+// ptr+1.
+func (o Operand) Plus(n int) Operand {
+	o.Offset += n
+	return o
+}
+
+// Builder assembles a warrior from Go calls instead of Redcode text.
+//
+//	b := goexmars.NewBuilder(goexmars.DefaultConfig)
+//	b.Equ("step", 4)
+//	b.Label("ptr").Dat(goexmars.ModifierF, goexmars.Imm(0), goexmars.Imm(0))
+//	b.Label("loop").Add(goexmars.ModifierAB, goexmars.Lbl("step").With(goexmars.AddressingImmediate), goexmars.Lbl("ptr"))
+//	b.Mov(goexmars.ModifierAB, goexmars.Imm(0), goexmars.Lbl("ptr").With(goexmars.AddressingBIndirect))
+//	b.Jmp(goexmars.ModifierB, goexmars.Lbl("loop"), goexmars.Dir(0))
+//	b.Org("loop")
+//	w, err := b.Build()
+//
+// As in Redcode, a label refers to the next instruction added and resolves
+// to the offset from the instruction using it, while an Equ constant is used
+// as is. All operands are reduced modulo the core size into
+// (-CoreSize/2, CoreSize/2]. Errors such as duplicate names or more than
+// MaxWarriorLen instructions are reported by Build, so calls can be chained
+// without checks.
+type Builder struct {
+	coreSize int
+	maxLen   int
+	name     string
+	author   string
+	org      string
+	commands []Command
+	operands [][2]Operand
+	labels   map[string]int
+	consts   map[string]int
+	errs     []error
+}
+
+// NewBuilder returns an empty Builder for warriors fighting with cfg. Only
+// CoreSize and MaxWarriorLen are used; zero means the DefaultConfig value.
+func NewBuilder(cfg FightConfig) *Builder {
+	if cfg.CoreSize == 0 {
+		cfg.CoreSize = DefaultConfig.CoreSize
+	}
+	if cfg.MaxWarriorLen == 0 {
+		cfg.MaxWarriorLen = DefaultConfig.MaxWarriorLen
+	}
+	return &Builder{
+		coreSize: cfg.CoreSize,
+		maxLen:   cfg.MaxWarriorLen,
+		labels:   map[string]int{},
+		consts:   map[string]int{},
+	}
+}
+
+// Name sets the warrior name.
+func (b *Builder) Name(name string) *Builder {
+	b.name = name
+	return b
+}
+
+// Author sets the warrior author.
+func (b *Builder) Author(author string) *Builder {
+	b.author = author
+	return b
+}
+
+// Label names the next instruction added. A label defined after the last
+// instruction refers to the cell right after the warrior.
+func (b *Builder) Label(name string) *Builder {
+	if b.defined(name) {
+		b.errs = append(b.errs, fmt.Errorf("duplicate name %q", name))
+		return b
+	}
+	b.labels[name] = len(b.commands)
+	return b
+}
+
+// Equ defines a constant, like name EQU v.
+func (b *Builder) Equ(name string, v int) *Builder {
+	if b.defined(name) {
+		b.errs = append(b.errs, fmt.Errorf("duplicate name %q", name))
+		return b
+	}
+	b.consts[name] = v
+	return b
+}
+
+// Org sets the label execution starts at, like ORG label or END label. The
+// label must name an instruction of the warrior, not an Equ constant.
+// Without Org execution starts at the first instruction.
+func (b *Builder) Org(label string) *Builder {
+	b.org = label
+	return b
+}
+
+func (b *Builder) defined(name string) bool {
+	_, isLabel := b.labels[name]
+	_, isConst := b.consts[name]
+	return isLabel || isConst
+}
+
+// Op adds an instruction.
+func (b *Builder) Op(op OpCode, mod Modifier, a, bOp Operand) *Builder {
+	b.commands = append(b.commands, Command{OpCode: op, Modifier: mod, AddressingModeA: a.Mode, AddressingModeB: bOp.Mode})
+	b.operands = append(b.operands, [2]Operand{a, bOp})
+	return b
+}
+
+// Dat adds a DAT instruction.
+func (b *Builder) Dat(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeDAT, mod, a, bOp) }
+
+// Mov adds a MOV instruction.
+func (b *Builder) Mov(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeMOV, mod, a, bOp) }
+
+// Add adds an ADD instruction.
+func (b *Builder) Add(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeADD, mod, a, bOp) }
+
+// Sub adds a SUB instruction.
+func (b *Builder) Sub(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSUB, mod, a, bOp) }
+
+// Mul adds a MUL instruction.
+func (b *Builder) Mul(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeMUL, mod, a, bOp) }
+
+// Div adds a DIV instruction.
+func (b *Builder) Div(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeDIV, mod, a, bOp) }
+
+// Mod adds a MOD instruction.
+func (b *Builder) Mod(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeMOD, mod, a, bOp) }
+
+// Jmp adds a JMP instruction.
+func (b *Builder) Jmp(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeJMP, mod, a, bOp) }
+
+// Jmz adds a JMZ instruction.
+func (b *Builder) Jmz(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeJMZ, mod, a, bOp) }
+
+// Jmn adds a JMN instruction.
+func (b *Builder) Jmn(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeJMN, mod, a, bOp) }
+
+// Djn adds a DJN instruction.
+func (b *Builder) Djn(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeDJN, mod, a, bOp) }
+
+// Spl adds a SPL instruction.
+func (b *Builder) Spl(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSPL, mod, a, bOp) }
+
+// Cmp adds a CMP instruction.
+func (b *Builder) Cmp(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeCMP, mod, a, bOp) }
+
+// Seq adds a SEQ instruction.
+func (b *Builder) Seq(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSEQ, mod, a, bOp) }
+
+// Sne adds a SNE instruction.
+func (b *Builder) Sne(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSNE, mod, a, bOp) }
+
+// Slt adds a SLT instruction.
+func (b *Builder) Slt(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSLT, mod, a, bOp) }
+
+// Ldp adds a LDP instruction.
+func (b *Builder) Ldp(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeLDP, mod, a, bOp) }
+
+// Stp adds a STP instruction.
+func (b *Builder) Stp(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeSTP, mod, a, bOp) }
+
+// Nop adds a NOP instruction.
+func (b *Builder) Nop(mod Modifier, a, bOp Operand) *Builder { return b.Op(OpCodeNOP, mod, a, bOp) }
+
+// Build resolves labels and constants and returns the warrior. All problems,
+// such as undefined or duplicate names and an empty or overlong warrior, are
+// returned joined into one error.
+func (b *Builder) Build() (ParsedWarrior, error) {
+	errs := append([]error(nil), b.errs...)
+	if len(b.commands) == 0 {
+		errs = append(errs, errors.New("no commands"))
+	}
+	if len(b.commands) > b.maxLen {
+		errs = append(errs, fmt.Errorf("length %d exceeds MaxWarriorLen %d", len(b.commands), b.maxLen))
+	}
+
+	w := ParsedWarrior{
+		Name:     b.name,
+		Author:   b.author,
+		Commands: make([]Command, len(b.commands)),
+	}
+	for i, cmd := range b.commands {
+		var err error
+		if cmd.A, err = b.resolve(b.operands[i][0], i); err != nil {
+			errs = append(errs, fmt.Errorf("command %d: A operand: %w", i, err))
+		}
+		if cmd.B, err = b.resolve(b.operands[i][1], i); err != nil {
+			errs = append(errs, fmt.Errorf("command %d: B operand: %w", i, err))
+		}
+		w.Commands[i] = cmd
+	}
+
+	if b.org != "" {
+		end, ok := b.labels[b.org]
+		_, isConst := b.consts[b.org]
+		switch {
+		case isConst:
+			errs = append(errs, fmt.Errorf("ORG: %q is a constant, not a label", b.org))
+		case !ok:
+			errs = append(errs, fmt.Errorf("ORG: undefined label %q", b.org))
+		case end >= len(b.commands):
+			errs = append(errs, fmt.Errorf("ORG: label %q is past the last instruction", b.org))
+		}
+		w.End = end
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return ParsedWarrior{}, err
+	}
+	return w, nil
+}
+
+// resolve returns the value of o used by the instruction at index i.
+func (b *Builder) resolve(o Operand, i int) (int, error) {
+	v := o.Offset
+	if o.Label != "" {
+		if target, ok := b.labels[o.Label]; ok {
+			v += target - i
+		} else if c, ok := b.consts[o.Label]; ok {
+			v += c
+		} else {
+			return 0, fmt.Errorf("undefined label %q", o.Label)
+		}
+	}
+	return SignedOffset(v, b.coreSize), nil
+}
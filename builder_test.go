@@ -0,0 +1,139 @@
+package goexmars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestBuilderDwarf(t *testing.T) {
+	b := NewBuilder(DefaultConfig).Name("Dwarf").Author("A. K. Dewdney")
+	b.Equ("step", 4)
+	b.Label("ptr").Dat(ModifierF, Imm(0), Imm(0))
+	b.Label("loop").Add(ModifierAB, Lbl("step").With(AddressingImmediate), Lbl("ptr"))
+	b.Mov(ModifierAB, Imm(0), Lbl("ptr").With(AddressingBIndirect))
+	b.Jmp(ModifierB, Lbl("loop"), Dir(0))
+	b.Org("loop")
+
+	w, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	want := []string{
+		"DAT.F #0, #0",
+		"ADD.AB #4, $-1",
+		"MOV.AB #0, @-2",
+		"JMP.B $-2, $0",
+	}
+	var got []string
+	for _, cmd := range w.Commands {
+		got = append(got, cmd.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+	if w.End != 1 || w.Name != "Dwarf" || w.Author != "A. K. Dewdney" {
+		t.Fatalf("unexpected warrior fields: %+v", w)
+	}
+}
+
+func TestBuilderWrapsOperands(t *testing.T) {
+	b := NewBuilder(DefaultConfig.SetCoreSize(80))
+	b.Equ("far", 75)
+	b.Label("top").Mov(ModifierI, Dir(0), Lbl("far"))
+	b.Jmp(ModifierB, Lbl("top").Plus(-40), Dir(81))
+	b.Label("after")
+	b.Spl(ModifierB, Lbl("after"), Dir(0))
+
+	w, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+	if w.Commands[0].B != -5 {
+		t.Fatalf("expected 75 to wrap to -5, got %d", w.Commands[0].B)
+	}
+	// top-40 from command 1 is -41, which wraps to 39.
+	if w.Commands[1].A != 39 || w.Commands[1].B != 1 {
+		t.Fatalf("unexpected wrapped operands: %+v", w.Commands[1])
+	}
+	if w.Commands[2].A != 0 {
+		t.Fatalf("expected a label before an instruction to refer to it, got %d", w.Commands[2].A)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b := NewBuilder(FightConfig{})
+	b.Label("x").Label("x")
+	b.Equ("x", 1)
+	b.Mov(ModifierI, Lbl("missing"), Dir(1))
+	b.Org("nowhere")
+
+	_, err := b.Build()
+	if err == nil {
+		t.Fatalf("expected Build to fail")
+	}
+	for _, want := range []string{
+		`duplicate name "x"`,
+		`command 0: A operand: undefined label "missing"`,
+		`ORG: undefined label "nowhere"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+
+	if _, err := NewBuilder(FightConfig{}).Build(); err == nil || !strings.Contains(err.Error(), "no commands") {
+		t.Fatalf("expected an empty builder to fail, got %v", err)
+	}
+	_, err = NewBuilder(FightConfig{}).Dat(ModifierF, Imm(0), Imm(0)).Label("after").Org("after").Build()
+	if err == nil || !strings.Contains(err.Error(), `ORG: label "after" is past the last instruction`) {
+		t.Fatalf("expected ORG past the code to fail, got %v", err)
+	}
+	_, err = NewBuilder(FightConfig{}).Equ("start", 0).Dat(ModifierF, Imm(0), Imm(0)).Org("start").Build()
+	if err == nil || !strings.Contains(err.Error(), `ORG: "start" is a constant, not a label`) {
+		t.Fatalf("expected ORG on a constant to fail, got %v", err)
+	}
+
+	long := NewBuilder(DefaultConfig.SetMaxWarriorLen(2))
+	for range 3 {
+		long.Dat(ModifierF, Imm(0), Imm(0))
+	}
+	if _, err := long.Build(); err == nil || !strings.Contains(err.Error(), "length 3 exceeds MaxWarriorLen 2") {
+		t.Fatalf("expected an overlong warrior to fail, got %v", err)
+	}
+}
+
+func TestBuilderAssemblesLikeSource(t *testing.T) {
+	testlib.Configure(t)
+
+	parsed, err := AssembleParsed(`;redcode-94
+step EQU 3044
+ptr  DAT #0, #0
+loop ADD #step, ptr
+     MOV }ptr, >ptr
+     DJN loop, <ptr+1
+END loop
+`, DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed returned error: %v", err)
+	}
+
+	b := NewBuilder(DefaultConfig)
+	b.Equ("step", 3044)
+	b.Label("ptr").Dat(ModifierF, Imm(0), Imm(0))
+	b.Label("loop").Add(ModifierAB, Lbl("step").With(AddressingImmediate), Lbl("ptr"))
+	b.Mov(ModifierI, Lbl("ptr").With(AddressingAIndirectPost), Lbl("ptr").With(AddressingBIndirectPost))
+	b.Djn(ModifierB, Lbl("loop"), Lbl("ptr").Plus(1).With(AddressingBIndirectPre))
+	b.Org("loop")
+
+	w, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+	if w.End != parsed.End || !reflect.DeepEqual(w.Commands, parsed.Canonicalize(DefaultConfig.CoreSize).Commands) {
+		t.Fatalf("built %v, assembled %v", w.Commands, parsed.Commands)
+	}
+}
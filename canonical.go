@@ -0,0 +1,81 @@
+package goexmars
+
+// Canonicalize returns a copy of w in a canonical form for a core of the
+// given size, so equivalent warriors written or assembled differently
+// compare equal:
+//
+//   - A and B are reduced to the signed range (-coreSize/2, coreSize/2] and
+//     End to [0, coreSize);
+//   - CMP becomes SEQ;
+//   - modifiers are replaced by the canonical one among the modifiers that
+//     execute identically for the opcode, e.g. ADD.I becomes ADD.F, JMZ.BA
+//     becomes JMZ.A and DAT, NOP, JMP and SPL take their pMARS defaults
+//     (.F, .F, .B, .B).
+//
+// The rewrites do not change how any instruction executes, but code that
+// inspects itself with SEQ.I or SNE.I can still tell them apart.
+// Assembled is cleared because it no longer matches the commands. A coreSize
+// of zero or less leaves A, B and End unreduced.
+func (w ParsedWarrior) Canonicalize(coreSize int) ParsedWarrior {
+	out := w
+	if coreSize > 0 {
+		out.End = int(modCore(w.End, coreSize))
+	}
+	out.Commands = make([]Command, len(w.Commands))
+	out.Assembled = ""
+	for i, cmd := range w.Commands {
+		out.Commands[i] = cmd.Canonicalize(coreSize)
+	}
+	return out
+}
+
+// SignedOffset returns v as the offset in (-coreSize/2, coreSize/2] it
+// stands for in a core of the given size, e.g. 7999 becomes -1 for 8000. A
+// coreSize of zero or less returns v unchanged.
+func SignedOffset(v, coreSize int) int {
+	if coreSize <= 0 {
+		return v
+	}
+	v = int(modCore(v, coreSize))
+	if v > coreSize/2 {
+		v -= coreSize
+	}
+	return v
+}
+
+// Canonicalize returns the canonical form of c for a core of the given size.
+// See ParsedWarrior.Canonicalize.
+func (c Command) Canonicalize(coreSize int) Command {
+	if c.OpCode == OpCodeCMP {
+		c.OpCode = OpCodeSEQ
+	}
+	c.Modifier = canonicalModifier(c.OpCode, c.Modifier)
+	c.A = SignedOffset(c.A, coreSize)
+	c.B = SignedOffset(c.B, coreSize)
+	return c
+}
+
+// canonicalModifier returns the modifier op.mod executes like, following
+// the cases exmars' sim.c merges.
+func canonicalModifier(op OpCode, mod Modifier) Modifier {
+	switch op {
+	case OpCodeDAT, OpCodeNOP:
+		return ModifierF
+	case OpCodeJMP, OpCodeSPL:
+		return ModifierB
+	case OpCodeJMZ, OpCodeJMN, OpCodeDJN:
+		// Only the tested field matters.
+		switch mod {
+		case ModifierBA:
+			return ModifierA
+		case ModifierAB:
+			return ModifierB
+		case ModifierX, ModifierI:
+			return ModifierF
+		}
+	}
+	if as, ok := oddModifier(op, mod); ok {
+		return as
+	}
+	return mod
+}
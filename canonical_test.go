@@ -0,0 +1,151 @@
+package goexmars
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestCanonicalizeCommand(t *testing.T) {
+	tests := []struct {
+		in, want Command
+	}{
+		{
+			in:   Command{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 7999, AddressingModeB: AddressingDirect, B: 8001},
+			want: Command{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: -1, AddressingModeB: AddressingDirect, B: 1},
+		},
+		{
+			in:   Command{OpCode: OpCodeCMP, Modifier: ModifierAB, A: 4000, B: 4001},
+			want: Command{OpCode: OpCodeSEQ, Modifier: ModifierAB, A: 4000, B: -3999},
+		},
+		{
+			in:   Command{OpCode: OpCodeADD, Modifier: ModifierI},
+			want: Command{OpCode: OpCodeADD, Modifier: ModifierF},
+		},
+		{
+			in:   Command{OpCode: OpCodeJMZ, Modifier: ModifierBA},
+			want: Command{OpCode: OpCodeJMZ, Modifier: ModifierA},
+		},
+		{
+			in:   Command{OpCode: OpCodeDJN, Modifier: ModifierI},
+			want: Command{OpCode: OpCodeDJN, Modifier: ModifierF},
+		},
+		{
+			in:   Command{OpCode: OpCodeLDP, Modifier: ModifierX},
+			want: Command{OpCode: OpCodeLDP, Modifier: ModifierB},
+		},
+		{
+			in:   Command{OpCode: OpCodeDAT, Modifier: ModifierAB},
+			want: Command{OpCode: OpCodeDAT, Modifier: ModifierF},
+		},
+		{
+			in:   Command{OpCode: OpCodeJMP, Modifier: ModifierI},
+			want: Command{OpCode: OpCodeJMP, Modifier: ModifierB},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Canonicalize(8000); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeWithoutCoreSize(t *testing.T) {
+	w := ParsedWarrior{
+		End:      9000,
+		Commands: []Command{{OpCode: OpCodeCMP, Modifier: ModifierI, A: 7999, B: -1}},
+	}
+	got := w.Canonicalize(0)
+	want := ParsedWarrior{
+		End:      9000,
+		Commands: []Command{{OpCode: OpCodeSEQ, Modifier: ModifierI, A: 7999, B: -1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCanonicalFingerprint(t *testing.T) {
+	a := ParsedWarrior{
+		Name: "Dwarf",
+		End:  1,
+		Commands: []Command{
+			{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingImmediate, AddressingModeB: AddressingImmediate},
+			{OpCode: OpCodeADD, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 4, AddressingModeB: AddressingDirect, B: -1},
+			{OpCode: OpCodeMOV, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingBIndirect, B: -2},
+			{OpCode: OpCodeJMP, Modifier: ModifierB, AddressingModeA: AddressingDirect, A: -2, AddressingModeB: AddressingDirect, B: 0},
+		},
+		Assembled: "ignored",
+	}
+	b := a.Canonicalize(800)
+	b.Assembled = ""
+	for i := range b.Commands {
+		b.Commands[i].A = int(modCore(b.Commands[i].A, 800))
+		b.Commands[i].B = int(modCore(b.Commands[i].B, 800))
+	}
+	b.Commands[0].Modifier = ModifierAB
+	b.Commands[3].Modifier = ModifierF
+
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Fatal("expected plain fingerprints to differ")
+	}
+	optsA := DefaultFingerprintOptions()
+	optsA.Canonical = true
+	optsB := optsA
+	optsB.CoreSize = 800
+	if a.FingerprintWithOptions(optsA) != b.FingerprintWithOptions(optsB) {
+		t.Fatalf("expected canonical fingerprints to match:\n%s\n%s", a.Canonicalize(8000), b.Canonicalize(800))
+	}
+}
+
+func TestCanonicalizeFightsIdentically(t *testing.T) {
+	testlib.Configure(t)
+
+	w, err := AssembleParsed(`
+        spl.x  #0, 0
+        add.i  step, ptr
+ptr     mov.i  bomb, }1
+        djn.ba -2, #1000
+        jmz.x  -4, ptr
+        cmp.i  0, 1
+        dat.ab 0, 0
+step    dat.x  #7, #-7
+bomb    dat.ba 0, 0
+`, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := w.Canonicalize(DefaultConfig.CoreSize)
+	if reflect.DeepEqual(c.Commands, w.Commands) {
+		t.Fatal("expected canonicalization to change the commands")
+	}
+
+	imp := ParsedWarrior{Commands: []Command{{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, AddressingModeB: AddressingDirect, B: 1}}}
+	cfg := DefaultConfig.SetRounds(20).SetFixPos(4000)
+	want, err := FightParsed([]ParsedWarrior{w, imp}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FightParsed([]ParsedWarrior{c, imp}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Wins, want.Wins) || got.Ties != want.Ties {
+		t.Fatalf("canonical warrior fights differently: %v/%d vs %v/%d", got.Wins, got.Ties, want.Wins, want.Ties)
+	}
+}
+
+func TestSignedOffset(t *testing.T) {
+	for _, tc := range []struct{ v, coreSize, want int }{
+		{7999, 8000, -1},
+		{4000, 8000, 4000},
+		{4001, 8000, -3999},
+		{-8001, 8000, -1},
+		{7999, 0, 7999},
+	} {
+		if got := SignedOffset(tc.v, tc.coreSize); got != tc.want {
+			t.Fatalf("SignedOffset(%d, %d) = %d, want %d", tc.v, tc.coreSize, got, tc.want)
+		}
+	}
+}
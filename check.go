@@ -0,0 +1,194 @@
+package goexmars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IssueCode identifies a problem found by CheckWarrior.
+type IssueCode int
+
+// Supported issue codes.
+const (
+	IssueInvalidConfig IssueCode = iota
+	IssueNoCommands
+	IssueTooLong
+	IssueExceedsMinSep
+	IssueEndOutOfRange
+	IssueInvalidEncoding
+	IssueOperandOutOfRange
+	IssuePSpaceDefault
+	IssueOddModifier
+	IssueSelfJump
+)
+
+var issueCodeNames = [...]string{
+	IssueInvalidConfig:     "invalid-config",
+	IssueNoCommands:        "no-commands",
+	IssueTooLong:           "too-long",
+	IssueExceedsMinSep:     "exceeds-min-sep",
+	IssueEndOutOfRange:     "end-out-of-range",
+	IssueInvalidEncoding:   "invalid-encoding",
+	IssueOperandOutOfRange: "operand-out-of-range",
+	IssuePSpaceDefault:     "p-space-default",
+	IssueOddModifier:       "odd-modifier",
+	IssueSelfJump:          "self-jump",
+}
+
+// String returns a stable kebab-case identifier for the code.
+func (c IssueCode) String() string {
+	if c >= 0 && int(c) < len(issueCodeNames) {
+		return issueCodeNames[c]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c IssueCode) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(issueCodeNames) {
+		return nil, fmt.Errorf("invalid issue code %d", c)
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *IssueCode) UnmarshalText(text []byte) error {
+	for v, name := range issueCodeNames {
+		if string(text) == name {
+			*c = IssueCode(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown issue code: %q", text)
+}
+
+// Issue is a single problem found by CheckWarrior.
+//
+// Errors make the warrior unusable with the config: exmars would reject it
+// or refuse to load it. Warnings flag code that exmars loads and runs but
+// that probably does not do what was intended.
+type Issue struct {
+	Severity Severity  `json:"severity"`
+	Code     IssueCode `json:"code"`
+	// Index is the offset of the offending command, or -1 if the issue
+	// concerns the whole warrior.
+	Index int `json:"index"`
+	// Message is the human-readable description.
+	Message string `json:"message"`
+}
+
+// String renders the issue as "severity: command I: message (code)".
+func (i Issue) String() string {
+	var b strings.Builder
+	b.WriteString(i.Severity.String())
+	b.WriteString(": ")
+	if i.Index >= 0 {
+		fmt.Fprintf(&b, "command %d: ", i.Index)
+	}
+	b.WriteString(i.Message)
+	b.WriteString(" (")
+	b.WriteString(i.Code.String())
+	b.WriteString(")")
+	return b.String()
+}
+
+// HasErrors reports whether issues contains an issue of SeverityError.
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckWarrior statically checks w against cfg without running a fight.
+//
+// It reports, in command order:
+//   - errors for an invalid cfg, an empty warrior, a length above
+//     MaxWarriorLen or MinSep and enum values outside their range;
+//   - warnings for an END offset outside the code (exmars only warns and
+//     loads the warrior anyway), operands outside ±CoreSize (they are
+//     reduced modulo CoreSize), LDP/STP while PSpaceSize is 0 (exmars then
+//     uses CoreSize/16), opcode/modifier combinations ICWS'94 maps to
+//     another modifier and JMP, JMZ, JMN and DJN with an immediate A
+//     operand, which jump to themselves. SPL with an immediate A operand is
+//     not reported, since SPL #0 is a common way to split to the next
+//     instruction.
+//
+// An invalid cfg is the only issue reported, since the other checks depend
+// on it. A nil result means no issues.
+func CheckWarrior(w ParsedWarrior, cfg FightConfig) []Issue {
+	if err := cfg.Validate(); err != nil {
+		return []Issue{{Severity: SeverityError, Code: IssueInvalidConfig, Index: -1, Message: err.Error()}}
+	}
+
+	var issues []Issue
+	report := func(sev Severity, code IssueCode, index int, format string, args ...any) {
+		issues = append(issues, Issue{Severity: sev, Code: code, Index: index, Message: fmt.Sprintf(format, args...)})
+	}
+
+	n := len(w.Commands)
+	if n == 0 {
+		report(SeverityError, IssueNoCommands, -1, "no commands")
+	}
+	if n > cfg.MaxWarriorLen {
+		report(SeverityError, IssueTooLong, -1, "length %d exceeds MaxWarriorLen %d", n, cfg.MaxWarriorLen)
+	}
+	if n > cfg.MinSep {
+		report(SeverityError, IssueExceedsMinSep, -1, "length %d exceeds MinSep %d", n, cfg.MinSep)
+	}
+	if n > 0 && (w.End < 0 || w.End >= n) {
+		report(SeverityWarning, IssueEndOutOfRange, -1, "END offset %d outside [0, %d)", w.End, n)
+	}
+
+	pspaceReported := false
+	for i, cmd := range w.Commands {
+		if int(cmd.OpCode) >= OpCodeCount || int(cmd.Modifier) >= ModifierCount ||
+			int(cmd.AddressingModeA) >= AddressingModeCount || int(cmd.AddressingModeB) >= AddressingModeCount {
+			report(SeverityError, IssueInvalidEncoding, i, "invalid command encoding %d.%d %d %d",
+				cmd.OpCode, cmd.Modifier, cmd.AddressingModeA, cmd.AddressingModeB)
+			continue
+		}
+
+		if cmd.A <= -cfg.CoreSize || cmd.A >= cfg.CoreSize {
+			report(SeverityWarning, IssueOperandOutOfRange, i, "A operand %d outside ±CoreSize %d", cmd.A, cfg.CoreSize)
+		}
+		if cmd.B <= -cfg.CoreSize || cmd.B >= cfg.CoreSize {
+			report(SeverityWarning, IssueOperandOutOfRange, i, "B operand %d outside ±CoreSize %d", cmd.B, cfg.CoreSize)
+		}
+
+		if (cmd.OpCode == OpCodeLDP || cmd.OpCode == OpCodeSTP) && cfg.PSpaceSize == 0 && !pspaceReported {
+			report(SeverityWarning, IssuePSpaceDefault, i, "%s with PSpaceSize 0 uses the default p-space size CoreSize/16", cmd.OpCode)
+			pspaceReported = true
+		}
+
+		if as, ok := oddModifier(cmd.OpCode, cmd.Modifier); ok {
+			report(SeverityWarning, IssueOddModifier, i, "%s.%s behaves like %s.%s", cmd.OpCode, cmd.Modifier, cmd.OpCode, as)
+		}
+
+		if isJump(cmd.OpCode) && cmd.AddressingModeA == AddressingImmediate {
+			report(SeverityWarning, IssueSelfJump, i, "%s with an immediate A operand targets itself", cmd.OpCode)
+		}
+	}
+	return issues
+}
+
+// oddModifier returns the modifier op.mod behaves like under ICWS'94 if it
+// is not mod itself.
+func oddModifier(op OpCode, mod Modifier) (Modifier, bool) {
+	switch op {
+	case OpCodeADD, OpCodeSUB, OpCodeMUL, OpCodeDIV, OpCodeMOD, OpCodeSLT:
+		// Arithmetic and SLT have no whole-instruction form.
+		if mod == ModifierI {
+			return ModifierF, true
+		}
+	case OpCodeLDP, OpCodeSTP:
+		// P-space cells hold a single number.
+		switch mod {
+		case ModifierF, ModifierX, ModifierI:
+			return ModifierB, true
+		}
+	}
+	return 0, false
+}
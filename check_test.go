@@ -0,0 +1,112 @@
+package goexmars
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func issueCodes(issues []Issue) []IssueCode {
+	codes := make([]IssueCode, len(issues))
+	for i, issue := range issues {
+		codes[i] = issue.Code
+	}
+	return codes
+}
+
+func TestCheckWarriorClean(t *testing.T) {
+	w := ParsedWarrior{Commands: []Command{
+		{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: 1},
+	}}
+	if issues := CheckWarrior(w, DefaultConfig); issues != nil {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+}
+
+func TestCheckWarriorIssues(t *testing.T) {
+	cfg := DefaultConfig.SetPSpaceSize(0)
+	w := ParsedWarrior{
+		End: 5,
+		Commands: []Command{
+			{OpCode: OpCodeADD, Modifier: ModifierI, AddressingModeA: AddressingImmediate, A: 4, AddressingModeB: AddressingDirect, B: 9000},
+			{OpCode: OpCodeLDP, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingDirect, B: 1},
+			{OpCode: OpCodeSTP, Modifier: ModifierF, AddressingModeA: AddressingDirect, A: -1, AddressingModeB: AddressingImmediate, B: 0},
+			{OpCode: OpCodeJMP, Modifier: ModifierB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingDirect, B: 0},
+			{OpCode: OpCode(OpCodeCount), Modifier: ModifierF},
+		},
+	}
+	issues := CheckWarrior(w, cfg)
+	want := []IssueCode{
+		IssueEndOutOfRange,
+		IssueOperandOutOfRange, IssueOddModifier,
+		IssuePSpaceDefault,
+		IssueOddModifier,
+		IssueSelfJump,
+		IssueInvalidEncoding,
+	}
+	got := issueCodes(issues)
+	if len(got) != len(want) {
+		t.Fatalf("got issues %v, want codes %v", issues, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got issues %v, want codes %v", issues, want)
+		}
+	}
+	if !HasErrors(issues) {
+		t.Fatal("expected errors")
+	}
+	if issues[0].Severity != SeverityWarning {
+		t.Fatalf("END outside the code should only warn: %+v", issues[0])
+	}
+	if issues[1].Index != 0 || issues[1].Severity != SeverityWarning {
+		t.Fatalf("unexpected operand issue: %+v", issues[1])
+	}
+}
+
+func TestCheckWarriorAllowsSplitToNext(t *testing.T) {
+	w := ParsedWarrior{Commands: []Command{
+		{OpCode: OpCodeSPL, Modifier: ModifierB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingDirect, B: 0},
+		{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: 1},
+	}}
+	if issues := CheckWarrior(w, DefaultConfig); issues != nil {
+		t.Fatalf("expected no issues for SPL #0, got %v", issues)
+	}
+}
+
+func TestCheckWarriorLength(t *testing.T) {
+	cfg := DefaultConfig.SetMaxWarriorLen(2).SetMinSep(1)
+	w := ParsedWarrior{Commands: make([]Command, 3)}
+	issues := CheckWarrior(w, cfg)
+	if len(issues) != 2 || issues[0].Code != IssueTooLong || issues[1].Code != IssueExceedsMinSep {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+
+	issues = CheckWarrior(ParsedWarrior{}, cfg)
+	if len(issues) != 1 || issues[0].Code != IssueNoCommands {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+
+	issues = CheckWarrior(w, FightConfig{})
+	if len(issues) != 1 || issues[0].Code != IssueInvalidConfig {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	issue := Issue{Severity: SeverityWarning, Code: IssueSelfJump, Index: 3, Message: "JMP with an immediate A operand targets itself"}
+	if got, want := issue.String(), "warning: command 3: JMP with an immediate A operand targets itself (self-jump)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	data, err := json.Marshal(issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Issue
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != issue {
+		t.Fatalf("round trip: got %+v, want %+v", back, issue)
+	}
+}
@@ -0,0 +1,364 @@
+package goexmars
+
+import "fmt"
+
+// Strategy is a classic Core War warrior strategy.
+type Strategy byte
+
+// Supported strategies.
+const (
+	StrategyImp Strategy = iota
+	StrategyStone
+	StrategyPaper
+	StrategyScanner
+	StrategyClear
+	StrategyVampire
+	StrategyPSwitcher
+	StrategyMixed
+)
+
+// StrategyCount is the number of strategies.
+const StrategyCount = int(StrategyMixed) + 1
+
+var strategyNames = [...]string{
+	StrategyImp:       "imp",
+	StrategyStone:     "stone",
+	StrategyPaper:     "paper",
+	StrategyScanner:   "scanner",
+	StrategyClear:     "clear",
+	StrategyVampire:   "vampire",
+	StrategyPSwitcher: "p-switcher",
+	StrategyMixed:     "mixed",
+}
+
+// String returns the lowercase name of the strategy.
+func (s Strategy) String() string {
+	if int(s) < len(strategyNames) {
+		return strategyNames[s]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Strategy) MarshalText() ([]byte, error) {
+	if int(s) >= StrategyCount {
+		return nil, fmt.Errorf("invalid strategy %d", s)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Strategy) UnmarshalText(text []byte) error {
+	for v, name := range strategyNames {
+		if string(text) == name {
+			*s = Strategy(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown strategy: %q", text)
+}
+
+// Classification is the result of Classify.
+type Classification struct {
+	// Strategy is the most likely strategy.
+	Strategy Strategy `json:"strategy"`
+	// Confidence is the score of Strategy in [0, 1].
+	Confidence float64 `json:"confidence"`
+	// Scores holds a score in [0, 1] for every strategy, indexed by
+	// Strategy. The scores are independent and do not sum to 1. The mixed
+	// score is non-zero when two strategies score similarly high.
+	Scores [StrategyCount]float64 `json:"scores"`
+	// Behavioural reports whether the scores include features observed in a
+	// fight, in addition to the static ones.
+	Behavioural bool `json:"behavioural"`
+}
+
+// classifyCycles limits the fight Classify observes.
+const classifyCycles = 4000
+
+// Thresholds for reporting StrategyMixed: the two best strategies must both
+// score at least mixedMinScore and be at most mixedMaxGap apart.
+const (
+	mixedMinScore = 0.5
+	mixedMaxGap   = 0.15
+)
+
+// Classify labels w with the strategy it most likely follows.
+//
+// The static features of ClassifyStatic are combined with behavioural ones
+// taken from a trace of w fighting on its own for at most a few thousand
+// cycles of cfg. It needs the shared library or a fallback with tracing;
+// use ClassifyStatic when neither is available.
+//
+// The classification is heuristic. It recognises the textbook forms of
+// each strategy well; heavily optimised or unusual warriors may score low
+// everywhere or be labelled mixed.
+func Classify(w ParsedWarrior, cfg FightConfig) (Classification, error) {
+	return ClassifyWithEngine(DefaultEngine, w, cfg)
+}
+
+// ClassifyWithEngine is Classify tracing the fight on e.
+func ClassifyWithEngine(e Engine, w ParsedWarrior, cfg FightConfig) (Classification, error) {
+	c := ClassifyStatic(w, cfg)
+
+	traceCfg := cfg.SetRounds(1).SetCycles(min(cfg.Cycles, classifyCycles))
+	trace, err := e.TraceParsed([]ParsedWarrior{w}, traceCfg, 0)
+	if err != nil {
+		return Classification{}, fmt.Errorf("classify: %w", err)
+	}
+
+	behaviour := traceFeatures(w, cfg, trace).scores()
+	for s := range behaviour {
+		if Strategy(s) == StrategyVampire || Strategy(s) == StrategyMixed {
+			// A solo fight shows nothing of a vampire's fangs.
+			continue
+		}
+		c.Scores[s] = 0.6*c.Scores[s] + 0.4*behaviour[s]
+	}
+	c.Behavioural = true
+	c.decide()
+	return c, nil
+}
+
+// ClassifyStatic is Classify using only the static features of w's
+// commands, such as SPL/MOV patterns, LDP/STP use and SEQ/JMZ scan loops.
+// cfg only provides the core size.
+func ClassifyStatic(w ParsedWarrior, cfg FightConfig) Classification {
+	c := Classification{Scores: staticFeatures(w, cfg).scores()}
+	c.decide()
+	return c
+}
+
+// decide picks Strategy and Confidence from the scores.
+func (c *Classification) decide() {
+	c.Scores[StrategyMixed] = 0
+	best, second := Strategy(0), Strategy(1)
+	if c.Scores[second] > c.Scores[best] {
+		best, second = second, best
+	}
+	for s := StrategyPaper; s < StrategyMixed; s++ {
+		switch {
+		case c.Scores[s] > c.Scores[best]:
+			best, second = s, best
+		case c.Scores[s] > c.Scores[second]:
+			second = s
+		}
+	}
+
+	c.Strategy, c.Confidence = best, c.Scores[best]
+	// A p-switcher combines strategies by design, so it never counts as mixed.
+	if best != StrategyPSwitcher && c.Scores[second] >= mixedMinScore && c.Scores[best]-c.Scores[second] <= mixedMaxGap {
+		c.Scores[StrategyMixed] = c.Scores[second]
+		c.Strategy, c.Confidence = StrategyMixed, c.Scores[second]
+	}
+}
+
+// commandFeatures counts the instruction patterns the static classifier
+// looks for.
+type commandFeatures struct {
+	commands int
+
+	ldp, stp int
+	spl      int
+	// imp counts MOV $0, $k (k != 0).
+	imp int
+	// step counts ADD/SUB with an immediate A operand.
+	step int
+	// scan counts SEQ, SNE, CMP, SLT, JMZ and JMN.
+	scan int
+	// bomb counts MOVs writing through an indirect B operand from a direct
+	// or immediate source.
+	bomb int
+	// clear counts bombing MOVs and DJNs whose B operand increments or
+	// decrements a pointer.
+	clear int
+	// copy counts MOVs with indirect A and B operands.
+	copy int
+	// splIndirect counts SPLs with an indirect A operand.
+	splIndirect int
+	// fang counts MOVs whose source is a JMP in the warrior.
+	fang int
+	// loop counts backward jumps within the warrior.
+	loop int
+}
+
+func staticFeatures(w ParsedWarrior, cfg FightConfig) commandFeatures {
+	f := commandFeatures{commands: len(w.Commands)}
+	for i, cmd := range w.Commands {
+		a := SignedOffset(cmd.A, cfg.CoreSize)
+		b := SignedOffset(cmd.B, cfg.CoreSize)
+		switch cmd.OpCode {
+		case OpCodeLDP:
+			f.ldp++
+		case OpCodeSTP:
+			f.stp++
+		case OpCodeSPL:
+			f.spl++
+			if isIndirect(cmd.AddressingModeA) {
+				f.splIndirect++
+			}
+		case OpCodeADD, OpCodeSUB:
+			if cmd.AddressingModeA == AddressingImmediate {
+				f.step++
+			}
+		case OpCodeSEQ, OpCodeSNE, OpCodeCMP, OpCodeSLT, OpCodeJMZ, OpCodeJMN:
+			f.scan++
+		case OpCodeMOV:
+			switch {
+			case cmd.AddressingModeA == AddressingDirect && a == 0 && cmd.AddressingModeB == AddressingDirect && b != 0:
+				f.imp++
+			case isIndirect(cmd.AddressingModeA) && isIndirect(cmd.AddressingModeB):
+				f.copy++
+			case isIndirect(cmd.AddressingModeB):
+				f.bomb++
+				if isIncDec(cmd.AddressingModeB) {
+					f.clear++
+				}
+			}
+			if t := i + a; cmd.AddressingModeA == AddressingDirect && a != 0 && t >= 0 && t < len(w.Commands) && w.Commands[t].OpCode == OpCodeJMP {
+				f.fang++
+			}
+		case OpCodeDJN:
+			if isIncDec(cmd.AddressingModeB) {
+				f.clear++
+			}
+		}
+		if isJump(cmd.OpCode) && cmd.AddressingModeA == AddressingDirect && a < 0 && i+a >= 0 {
+			f.loop++
+		}
+	}
+	return f
+}
+
+func (f commandFeatures) scores() [StrategyCount]float64 {
+	var s [StrategyCount]float64
+	if f.commands == 0 {
+		return s
+	}
+
+	switch {
+	case f.ldp > 0 && f.stp > 0:
+		s[StrategyPSwitcher] = 0.95
+	case f.ldp > 0 || f.stp > 0:
+		s[StrategyPSwitcher] = 0.6
+	}
+
+	if f.imp > 0 {
+		s[StrategyImp] = 0.6 + 0.2*flag(f.commands <= 4) + 0.2*flag(f.spl > 0 || f.step > 0)
+	}
+
+	// Papers, scanners and vampires also bomb, but with copies, after a scan
+	// or with fangs.
+	s[StrategyStone] = (0.45*flag(f.bomb > 0) + 0.3*flag(f.step > 0) + 0.15*flag(f.loop > 0) + 0.1*flag(f.scan == 0)) *
+		(1 - 0.5*flag(f.copy > 0 || f.scan > 0 || f.fang > 0))
+
+	s[StrategyPaper] = 0.45*flag(f.copy > 0) + 0.3*flag(f.splIndirect > 0 || f.spl >= 2) + 0.25*flag(f.copy > 0 && f.spl > 0)
+
+	s[StrategyScanner] = 0.55*flag(f.scan > 0) + 0.25*flag(f.step > 0) + 0.2*flag(f.scan > 0 && f.loop > 0)
+
+	s[StrategyClear] = (0.5*flag(f.clear > 0) + 0.3*flag(f.loop > 0) + 0.2*flag(f.spl > 0)) *
+		(1 - 0.5*flag(f.step > 0))
+
+	if f.fang > 0 {
+		s[StrategyVampire] = 0.7 + 0.2*flag(f.step > 0) + 0.1*flag(f.loop > 0)
+	}
+	return s
+}
+
+// traceStats summarises warrior 0's part of a trace.
+type traceStats struct {
+	steps int
+	// outside counts steps executed outside the warrior's original code.
+	outside int
+	imp     int
+	scan    int
+	bomb    int
+	clear   int
+	step    int
+	pspace  int
+}
+
+func traceFeatures(w ParsedWarrior, cfg FightConfig, trace []TraceStep) traceStats {
+	var t traceStats
+	for _, st := range trace {
+		if st.Warrior != 0 {
+			continue
+		}
+		t.steps++
+		if st.Address >= len(w.Commands) {
+			t.outside++
+		}
+		cmd := st.Command
+		a := SignedOffset(cmd.A, cfg.CoreSize)
+		b := SignedOffset(cmd.B, cfg.CoreSize)
+		switch cmd.OpCode {
+		case OpCodeLDP, OpCodeSTP:
+			t.pspace++
+		case OpCodeADD, OpCodeSUB:
+			if cmd.AddressingModeA == AddressingImmediate {
+				t.step++
+			}
+		case OpCodeSEQ, OpCodeSNE, OpCodeCMP, OpCodeSLT, OpCodeJMZ, OpCodeJMN:
+			t.scan++
+		case OpCodeMOV:
+			switch {
+			case cmd.AddressingModeA == AddressingDirect && a == 0 && cmd.AddressingModeB == AddressingDirect && b != 0:
+				t.imp++
+			case isIndirect(cmd.AddressingModeB) && !isIndirect(cmd.AddressingModeA):
+				t.bomb++
+				if isIncDec(cmd.AddressingModeB) {
+					t.clear++
+				}
+			}
+		case OpCodeDJN:
+			if isIncDec(cmd.AddressingModeB) {
+				t.clear++
+			}
+		}
+	}
+	return t
+}
+
+func (t traceStats) scores() [StrategyCount]float64 {
+	var s [StrategyCount]float64
+	if t.steps == 0 {
+		return s
+	}
+	share := func(n int) float64 { return float64(n) / float64(t.steps) }
+
+	s[StrategyImp] = share(t.imp)
+	// Papers run their copies; imps also leave their code, but as MOV $0.
+	s[StrategyPaper] = share(t.outside - t.imp)
+	s[StrategyScanner] = min(1, 3*share(t.scan))
+	s[StrategyStone] = min(1, 2*share(t.bomb+t.step)) * (1 - share(t.scan))
+	s[StrategyClear] = min(1, 2*share(t.clear)) * (1 - min(1, 3*share(t.step)))
+	s[StrategyPSwitcher] = flag(t.pspace > 0)
+	return s
+}
+
+func isIndirect(m AddressingMode) bool {
+	return m != AddressingImmediate && m != AddressingDirect
+}
+
+func isIncDec(m AddressingMode) bool {
+	switch m {
+	case AddressingAIndirectPre, AddressingBIndirectPre, AddressingAIndirectPost, AddressingBIndirectPost:
+		return true
+	}
+	return false
+}
+
+func isJump(op OpCode) bool {
+	switch op {
+	case OpCodeJMP, OpCodeJMZ, OpCodeJMN, OpCodeDJN:
+		return true
+	}
+	return false
+}
+
+func flag(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
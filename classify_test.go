@@ -0,0 +1,129 @@
+package goexmars
+
+import (
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+var classifyWarriors = map[Strategy]string{
+	StrategyImp: `
+;name Imp
+mov.i 0, 1
+`,
+	StrategyStone: `
+;name Dwarf
+        add.ab #4, bomb
+        mov.ab #0, @bomb
+        jmp    -2
+bomb    dat    #0, #0
+`,
+	StrategyPaper: `
+;name Silk
+        spl    1
+        spl    1
+silk    spl    @0, }400
+        mov.i  }silk, >silk
+        mov.i  {silk, <boot
+boot    jmp    @0, >200
+`,
+	StrategyScanner: `
+;name Scanner
+step    equ    10
+scan    add.ab #step, ptr
+ptr     sne.i  20, 30
+        jmp    scan
+        mov.i  bomb, @ptr
+        jmp    scan
+bomb    dat    #0, #0
+`,
+	StrategyClear: `
+;name Clear
+gate    dat    #0, #-5
+        spl    #0, 0
+loop    mov.i  bomb, >gate
+        djn.f  loop, >gate
+bomb    dat    #0, #1
+        end    1
+`,
+	StrategyVampire: `
+;name Vampire
+const   equ    2365
+fang    jmp    pit-bite, 0
+bite    add.f  step, fang
+        mov.i  fang, @fang
+        jmp    bite
+step    dat    #const, #-const
+pit     spl    #0, 0
+        jmp    -1
+        end    bite
+`,
+	StrategyPSwitcher: `
+;name Switch
+res     ldp.ab #0, #0
+        ldp.a  #1, res
+        sne.ab #0, res
+        add.a  #1, res
+        stp.ab res, #1
+        jmp    @res
+        dat    0, 0
+`,
+}
+
+func TestClassifyStatic(t *testing.T) {
+	testlib.Configure(t)
+
+	for want, src := range classifyWarriors {
+		w, err := AssembleParsed(src, DefaultConfig)
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		c := ClassifyStatic(w, DefaultConfig)
+		if c.Strategy != want {
+			t.Errorf("%s: got %s (%.2f), scores %v", want, c.Strategy, c.Confidence, c.Scores)
+		}
+		if c.Behavioural {
+			t.Errorf("%s: static classification marked behavioural", want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	testlib.Configure(t)
+
+	for want, src := range classifyWarriors {
+		w, err := AssembleParsed(src, DefaultConfig)
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		c, err := Classify(w, DefaultConfig)
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if c.Strategy != want {
+			t.Errorf("%s: got %s (%.2f), scores %v", want, c.Strategy, c.Confidence, c.Scores)
+		}
+		if !c.Behavioural {
+			t.Errorf("%s: expected behavioural features", want)
+		}
+		if c.Confidence <= 0 || c.Confidence > 1 {
+			t.Errorf("%s: confidence %v out of range", want, c.Confidence)
+		}
+	}
+}
+
+func TestClassifyMixed(t *testing.T) {
+	c := Classification{}
+	c.Scores[StrategyStone] = 0.8
+	c.Scores[StrategyImp] = 0.7
+	c.decide()
+	if c.Strategy != StrategyMixed || c.Scores[StrategyMixed] != 0.7 {
+		t.Fatalf("expected mixed, got %s with scores %v", c.Strategy, c.Scores)
+	}
+
+	c.Scores[StrategyImp] = 0.4
+	c.decide()
+	if c.Strategy != StrategyStone || c.Scores[StrategyMixed] != 0 {
+		t.Fatalf("expected stone, got %s with scores %v", c.Strategy, c.Scores)
+	}
+}
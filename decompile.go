@@ -0,0 +1,146 @@
+package goexmars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decompiled is the label and constant assignment for a warrior.
+type decompiled struct {
+	w    ParsedWarrior
+	opts RedcodeFormatOptions
+
+	labels map[int]string
+	// constants maps the absolute value of a constant to its name and the
+	// signed value the EQU defines.
+	constants     map[int]string
+	constantValue map[string]int
+	constantOrder []string
+}
+
+// decompile renders w with generated labels and constants. See
+// RedcodeFormatOptions.Decompile.
+func (w ParsedWarrior) decompile(opts RedcodeFormatOptions) string {
+	d := &decompiled{
+		w:             w,
+		opts:          opts,
+		labels:        map[int]string{},
+		constants:     map[int]string{},
+		constantValue: map[string]int{},
+	}
+	if opts.IncludeEnd && d.inCode(w.End) {
+		d.labels[w.End] = labelName(w.End)
+	}
+	for i, cmd := range w.Commands {
+		d.collect(i, cmd.AddressingModeA, cmd.A)
+		d.collect(i, cmd.AddressingModeB, cmd.B)
+	}
+
+	width := 0
+	for _, name := range d.labels {
+		width = max(width, len(name))
+	}
+	for _, name := range d.constantOrder {
+		width = max(width, len(name))
+	}
+
+	var b strings.Builder
+	w.writeMetadata(&b, opts)
+	for _, name := range d.constantOrder {
+		fmt.Fprintf(&b, "%-*s EQU %d\n", width, name, d.constantValue[name])
+	}
+	if opts.IncludeEnd {
+		org := strconv.Itoa(w.End)
+		if name, ok := d.labels[w.End]; ok {
+			org = name
+		}
+		fmt.Fprintf(&b, "%-*s ORG %s\n", width, "", org)
+	}
+	for i, cmd := range w.Commands {
+		fmt.Fprintf(&b, "%-*s %s.%s %s%s, %s%s\n", width, d.labels[i],
+			cmd.OpCode, cmd.Modifier,
+			cmd.AddressingModeA, d.operand(i, cmd.AddressingModeA, cmd.A),
+			cmd.AddressingModeB, d.operand(i, cmd.AddressingModeB, cmd.B))
+	}
+	if opts.IncludeEnd {
+		fmt.Fprintf(&b, "%-*s END", width, "")
+	}
+	return b.String()
+}
+
+// collect records the label or constant operand v of instruction i needs.
+func (d *decompiled) collect(i int, mode AddressingMode, v int) {
+	if target, ok := d.target(i, mode, v); ok {
+		if _, ok := d.labels[target]; !ok {
+			d.labels[target] = labelName(target)
+		}
+		return
+	}
+	v = d.value(v)
+	if !d.isConstant(v) {
+		return
+	}
+	if _, ok := d.constants[absInt(v)]; ok {
+		return
+	}
+	name := "c" + strconv.Itoa(len(d.constantOrder)+1)
+	d.constants[absInt(v)] = name
+	d.constantValue[name] = v
+	d.constantOrder = append(d.constantOrder, name)
+}
+
+// operand renders operand v of instruction i.
+func (d *decompiled) operand(i int, mode AddressingMode, v int) string {
+	if target, ok := d.target(i, mode, v); ok {
+		return d.labels[target]
+	}
+	v = d.value(v)
+	if d.isConstant(v) {
+		name := d.constants[absInt(v)]
+		if d.constantValue[name] != v {
+			return "-" + name
+		}
+		return name
+	}
+	return strconv.Itoa(v)
+}
+
+// target returns the instruction index operand v of instruction i refers
+// to, if it is a non-immediate reference to another instruction of the
+// warrior.
+func (d *decompiled) target(i int, mode AddressingMode, v int) (int, bool) {
+	if mode == AddressingImmediate {
+		return 0, false
+	}
+	t := i + v
+	if d.opts.CoreSize > 0 {
+		t = int(modCore(t, d.opts.CoreSize))
+	}
+	if t == i || !d.inCode(t) {
+		return 0, false
+	}
+	return t, true
+}
+
+// value returns v as it should be printed.
+func (d *decompiled) value(v int) int {
+	if d.opts.CoreSize <= 0 || !d.opts.ShortOffsets {
+		return v
+	}
+	return SignedOffset(v, d.opts.CoreSize)
+}
+
+// isConstant reports whether v is large enough to deserve an EQU, i.e. it
+// reaches beyond the warrior's own code.
+func (d *decompiled) isConstant(v int) bool {
+	return absInt(v) > len(d.w.Commands)
+}
+
+func (d *decompiled) inCode(i int) bool {
+	return i >= 0 && i < len(d.w.Commands)
+}
+
+func labelName(i int) string {
+	return "l" + strconv.Itoa(i)
+}
@@ -0,0 +1,107 @@
+package goexmars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func decompileOptions(coreSize int) RedcodeFormatOptions {
+	opts := DefaultRedcodeFormatOptions()
+	opts.Decompile = true
+	opts.CoreSize = coreSize
+	opts.ShortOffsets = true
+	return opts
+}
+
+func TestDecompileOutput(t *testing.T) {
+	w := ParsedWarrior{
+		Name: "Dwarf",
+		End:  1,
+		Commands: []Command{
+			{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingImmediate, B: 0},
+			{OpCode: OpCodeADD, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 4, AddressingModeB: AddressingDirect, B: -1},
+			{OpCode: OpCodeMOV, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 0, AddressingModeB: AddressingBIndirect, B: 7998},
+			{OpCode: OpCodeJMP, Modifier: ModifierB, AddressingModeA: AddressingDirect, A: -2, AddressingModeB: AddressingImmediate, B: 3044},
+			{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: -3044},
+		},
+	}
+	got := w.Format(decompileOptions(8000))
+	want := strings.Join([]string{
+		";name Dwarf",
+		"c1 EQU 3044",
+		"   ORG l1",
+		"l0 DAT.F #0, #0",
+		"l1 ADD.AB #4, $l0",
+		"   MOV.AB #0, @l0",
+		"   JMP.B $l1, #c1",
+		"   DAT.F $0, $-c1",
+		"   END",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDecompileReassembles(t *testing.T) {
+	testlib.Configure(t)
+
+	const src = `
+;name Four Winds
+;author John Metcalf
+     step  equ 694
+     diff  equ 5
+sc:  sub   inc,            ptr
+ptr: sne.b >step*4+diff,   >step*4
+     djn.f sc,             <ptr
+inc: spl   #-step,         <-step-1
+     mov   @bptr,          >ptr
+     mov   @bptr,          >ptr
+bptr:djn.f -2,             {clr
+     dat   -5,             8
+clr: spl   #-101,          16
+     end   ptr
+`
+	w, err := AssembleParsed(src, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Operands as they would be read back from a core.
+	wrapped := w
+	wrapped.Commands = make([]Command, len(w.Commands))
+	for i, cmd := range w.Commands {
+		cmd.A = int(modCore(cmd.A, DefaultConfig.CoreSize))
+		cmd.B = int(modCore(cmd.B, DefaultConfig.CoreSize))
+		wrapped.Commands[i] = cmd
+	}
+
+	for _, tc := range []struct {
+		name string
+		w    ParsedWarrior
+		opts RedcodeFormatOptions
+	}{
+		{"plain", w, RedcodeFormatOptions{IncludeName: true, IncludeAuthor: true, IncludeEnd: true, Decompile: true}},
+		{"short", w, decompileOptions(DefaultConfig.CoreSize)},
+		{"wrapped", wrapped, decompileOptions(DefaultConfig.CoreSize)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := tc.w.Format(tc.opts)
+			if !strings.Contains(out, "ORG l1") {
+				t.Fatalf("expected ORG at the start label:\n%s", out)
+			}
+			got, err := AssembleParsed(out, DefaultConfig)
+			if err != nil {
+				t.Fatalf("reassemble:\n%s\n%v", out, err)
+			}
+			if got.End != w.End || !reflect.DeepEqual(got.Commands, w.Commands) {
+				t.Fatalf("reassembled warrior differs:\n%s\ngot:\n%s\nwant:\n%s", out, got.String(), w.String())
+			}
+			if got.Name != w.Name || got.Author != w.Author {
+				t.Fatalf("metadata lost: %q %q", got.Name, got.Author)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package goexmars
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// diagnosticsRecordCap is the number of structured diagnostics read back from exmars.
+const diagnosticsRecordCap = 64
+
+// Severity classifies a diagnostic.
+type Severity byte
+
+// Supported severities.
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// String returns the lowercase name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// DiagnosticCode identifies the kind of problem reported by the exmars assembler.
+//
+// The values mirror the errType enum in pmars.c.
+type DiagnosticCode int
+
+// Supported diagnostic codes.
+const (
+	DiagBufferOverflow DiagnosticCode = iota
+	DiagBad88Format
+	DiagUnknownToken
+	DiagSyntax
+	DiagUndefinedSymbol
+	DiagInvalid88
+	DiagIncompleteOperand
+	DiagBadExpression
+	DiagMissing
+	DiagRecursiveReference
+	DiagIllegalAppend
+	DiagTooManyInstructions
+	DiagImproperPlacement
+	DiagExtraTokens
+	DiagNoInstructions
+	DiagExpectNumber
+	DiagRedefinition
+	DiagUnclosedROF
+	DiagUnopenedFOR
+	DiagIllegalConcat
+	DiagTooManyLabels
+	DiagAssertionFailed
+	DiagMissingAssert
+	DiagInvalidAssert
+	DiagTooManyLines
+	DiagFileOpen
+	DiagUndefinedLabel
+	DiagConcat
+	DiagDiscardedLabels
+	DiagBadOffset
+	DiagIgnoredEnd
+	DiagFileRead
+	DiagOutOfMemory
+	DiagDivisionByZero
+	DiagOverflow
+	DiagMisc
+)
+
+var diagnosticCodeNames = [...]string{
+	DiagBufferOverflow:      "buffer-overflow",
+	DiagBad88Format:         "bad-88-format",
+	DiagUnknownToken:        "unknown-token",
+	DiagSyntax:              "syntax",
+	DiagUndefinedSymbol:     "undefined-symbol",
+	DiagInvalid88:           "invalid-88",
+	DiagIncompleteOperand:   "incomplete-operand",
+	DiagBadExpression:       "bad-expression",
+	DiagMissing:             "missing",
+	DiagRecursiveReference:  "recursive-reference",
+	DiagIllegalAppend:       "illegal-append",
+	DiagTooManyInstructions: "too-many-instructions",
+	DiagImproperPlacement:   "improper-placement",
+	DiagExtraTokens:         "extra-tokens",
+	DiagNoInstructions:      "no-instructions",
+	DiagExpectNumber:        "expect-number",
+	DiagRedefinition:        "redefinition",
+	DiagUnclosedROF:         "unclosed-rof",
+	DiagUnopenedFOR:         "unopened-for",
+	DiagIllegalConcat:       "illegal-concat",
+	DiagTooManyLabels:       "too-many-labels",
+	DiagAssertionFailed:     "assertion-failed",
+	DiagMissingAssert:       "missing-assert",
+	DiagInvalidAssert:       "invalid-assert",
+	DiagTooManyLines:        "too-many-lines",
+	DiagFileOpen:            "file-open",
+	DiagUndefinedLabel:      "undefined-label",
+	DiagConcat:              "concat",
+	DiagDiscardedLabels:     "discarded-labels",
+	DiagBadOffset:           "bad-offset",
+	DiagIgnoredEnd:          "ignored-end",
+	DiagFileRead:            "file-read",
+	DiagOutOfMemory:         "out-of-memory",
+	DiagDivisionByZero:      "division-by-zero",
+	DiagOverflow:            "overflow",
+	DiagMisc:                "misc",
+}
+
+// String returns a stable kebab-case identifier for the code.
+func (c DiagnosticCode) String() string {
+	if c >= 0 && int(c) < len(diagnosticCodeNames) {
+		return diagnosticCodeNames[c]
+	}
+	return "unknown"
+}
+
+// Diagnostic is a single structured warning or error reported by the exmars assembler.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Warrior is the index of the warrior the diagnostic belongs to in the input order.
+	Warrior int `json:"warrior"`
+	// Line is the 1-based physical source line, or 0 if the diagnostic is not tied to a line.
+	Line int `json:"line"`
+	// Column is the 1-based column of the offending token, or 0 if unknown or if the token occurs more than once on the line.
+	Column int            `json:"column"`
+	Code   DiagnosticCode `json:"code"`
+	// Message is the human-readable message without location information.
+	Message string `json:"message"`
+}
+
+// String renders the diagnostic as "severity: line L:C: message (code)".
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	b.WriteString(d.Severity.String())
+	b.WriteString(": ")
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "line %d", d.Line)
+		if d.Column > 0 {
+			fmt.Fprintf(&b, ":%d", d.Column)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(d.Message)
+	b.WriteString(" (")
+	b.WriteString(d.Code.String())
+	b.WriteString(")")
+	return b.String()
+}
+
+// AssembleError is returned when exmars rejects one or more warriors.
+//
+// Use errors.As to access the structured diagnostics.
+type AssembleError struct {
+	// Diagnostics contains the structured warnings and errors.
+	Diagnostics []Diagnostic
+	// Text is the diagnostics text as printed by exmars.
+	Text string
+}
+
+// Error returns the exmars diagnostics text.
+func (e *AssembleError) Error() string {
+	if e.Text != "" {
+		return e.Text
+	}
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			return d.String()
+		}
+	}
+	return "assembly failed"
+}
+
+// Errors returns only the diagnostics with error severity.
+func (e *AssembleError) Errors() []Diagnostic {
+	out := make([]Diagnostic, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+type cDiagnostic struct {
+	Severity int32
+	Warrior  int32
+	Line     int32
+	Column   int32
+	Code     int32
+	Message  [256]byte
+}
+
+type cDiagnosticList struct {
+	Items unsafe.Pointer
+	Cap   int32
+	Len   int32
+}
+
+// diagnosticsBuffers holds the text and record buffers exmars writes diagnostics into.
+//
+// exmars always reports the full text length and record count, even when the
+// buffers are too small, so callers can grow the buffers and retry.
+type diagnosticsBuffers struct {
+	text    []byte
+	textLen int32
+	recs    []cDiagnostic
+	list    cDiagnosticList
+}
+
+func newDiagnosticsBuffers() *diagnosticsBuffers {
+	d := &diagnosticsBuffers{}
+	d.alloc(diagnosticsBufferSize, diagnosticsRecordCap)
+	return d
+}
+
+func (d *diagnosticsBuffers) alloc(textCap, recCap int) {
+	d.text = make([]byte, textCap)
+	d.textLen = 0
+	d.recs = make([]cDiagnostic, recCap)
+	d.list = cDiagnosticList{
+		Items: unsafe.Pointer(&d.recs[0]),
+		Cap:   int32(len(d.recs)),
+	}
+}
+
+// truncated reports whether the last call produced more diagnostics than fit.
+func (d *diagnosticsBuffers) truncated() bool {
+	return d.textLen > int32(len(d.text)-1) || d.list.Len > int32(len(d.recs))
+}
+
+// grow resizes the buffers to hold the sizes reported by the last call.
+func (d *diagnosticsBuffers) grow() {
+	textCap := len(d.text)
+	if need := int(d.textLen) + 1; need > textCap {
+		textCap = need
+	}
+	recCap := len(d.recs)
+	if need := int(d.list.Len); need > recCap {
+		recCap = need
+	}
+	d.alloc(textCap, recCap)
+}
+
+func (d *diagnosticsBuffers) String() string {
+	return diagnosticsString(d.text, d.textLen)
+}
+
+func (d *diagnosticsBuffers) Diagnostics() []Diagnostic {
+	return diagnosticsList(d.recs, d.list.Len)
+}
+
+func (d *diagnosticsBuffers) err() *AssembleError {
+	return &AssembleError{Diagnostics: d.Diagnostics(), Text: d.String()}
+}
+
+func diagnosticsList(items []cDiagnostic, n int32) []Diagnostic {
+	if n > int32(len(items)) {
+		n = int32(len(items))
+	}
+	if n <= 0 {
+		return nil
+	}
+	out := make([]Diagnostic, n)
+	for i := range out {
+		rec := items[i]
+		msg := rec.Message[:]
+		for j, c := range msg {
+			if c == 0 {
+				msg = msg[:j]
+				break
+			}
+		}
+		out[i] = Diagnostic{
+			Severity: Severity(rec.Severity),
+			Warrior:  int(rec.Warrior),
+			Line:     int(rec.Line),
+			Column:   int(rec.Column),
+			Code:     DiagnosticCode(rec.Code),
+			Message:  string(msg),
+		}
+	}
+	return out
+}
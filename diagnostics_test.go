@@ -0,0 +1,162 @@
+package goexmars
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestAssembleErrorStructuredDiagnostics(t *testing.T) {
+	testlib.Configure(t)
+
+	const malformed = `
+;redcode-94
+;name Broken
+MOV.Z 0, 1
+END
+`
+
+	_, err := Assemble(malformed, DefaultConfig)
+	var asmErr *AssembleError
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *AssembleError, got %T: %v", err, err)
+	}
+
+	var found bool
+	for _, d := range asmErr.Errors() {
+		if d.Code != DiagMissing {
+			continue
+		}
+		found = true
+		if d.Line != 4 {
+			t.Fatalf("expected diagnostic on line 4, got %d", d.Line)
+		}
+		if d.Message != "Missing 'modifier'" {
+			t.Fatalf("unexpected diagnostic message: %q", d.Message)
+		}
+	}
+	if !found {
+		t.Fatalf("expected a missing-modifier diagnostic, got %v", asmErr.Diagnostics)
+	}
+}
+
+func TestFightDiagnosticListWarriorIndex(t *testing.T) {
+	testlib.Configure(t)
+
+	const imp = `
+;redcode-94
+;assert 1
+MOV 0, 1
+END
+`
+
+	const undefined = `
+;redcode-94
+;assert 1
+JMP nowhere
+END
+`
+
+	cfg := DefaultConfig.SetRounds(1)
+	result, err := Fight([]string{imp, undefined}, cfg)
+	if err == nil {
+		t.Fatalf("expected undefined label to fail")
+	}
+	if len(result.DiagnosticList) == 0 {
+		t.Fatalf("expected structured diagnostics, got none")
+	}
+	for _, d := range result.DiagnosticList {
+		if d.Severity == SeverityError && d.Warrior != 1 {
+			t.Fatalf("expected error to belong to warrior 1, got %+v", d)
+		}
+	}
+
+	d := result.DiagnosticList[len(result.DiagnosticList)-1]
+	if d.Code != DiagUndefinedSymbol || d.Line != 4 || d.Column != 5 {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestDiagnosticColumnSkipsPartialMatches(t *testing.T) {
+	testlib.Configure(t)
+
+	// "lab" also occurs inside the label, which must not be reported.
+	_, err := AssembleParsed(";redcode-94\n;assert 1\nlabel mov lab, 1\nEND\n", DefaultConfig)
+	var asmErr *AssembleError
+	if !errors.As(err, &asmErr) || len(asmErr.Diagnostics) == 0 {
+		t.Fatalf("expected an undefined symbol, got %v", err)
+	}
+	if d := asmErr.Diagnostics[0]; d.Code != DiagUndefinedSymbol || d.Line != 3 || d.Column != 11 {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestFightGrowsDiagnosticsOnSuccess(t *testing.T) {
+	testlib.Configure(t)
+
+	lib, err := DefaultLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without ;assert every warrior gets a warning, which overflows a
+	// single record and an 8-byte text buffer.
+	warriors := []string{"MOV 0, 1", "MOV 0, 1", "MOV 0, 1"}
+	cfg := DefaultConfig.SetRounds(1)
+	want, err := lib.Fight(warriors, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diag := &diagnosticsBuffers{}
+	diag.alloc(8, 1)
+	got, err := lib.fight(warriors, cfg, diag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Failed() {
+		t.Fatalf("fight failed: %+v", got)
+	}
+	if len(got.DiagnosticList) != 3 || got.Diagnostics != want.Diagnostics {
+		t.Fatalf("truncated diagnostics:\n%q\n%v", got.Diagnostics, got.DiagnosticList)
+	}
+}
+
+func TestFightGrowsDiagnosticsOnFailure(t *testing.T) {
+	testlib.Configure(t)
+
+	lib, err := DefaultLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warriors := []string{"MOV 0, 1", "JMP nowhere"}
+	cfg := DefaultConfig.SetRounds(1)
+	want, wantErr := lib.Fight(warriors, cfg)
+	if wantErr == nil {
+		t.Fatal("expected the undefined label to fail")
+	}
+
+	diag := &diagnosticsBuffers{}
+	diag.alloc(8, 1)
+	got, err := lib.fight(warriors, cfg, diag)
+	if err == nil || !got.Failed() {
+		t.Fatalf("expected the fight to fail, got %+v, %v", got, err)
+	}
+	if got.Diagnostics != want.Diagnostics || len(got.DiagnosticList) != len(want.DiagnosticList) {
+		t.Fatalf("truncated diagnostics:\n%q\n%v", got.Diagnostics, got.DiagnosticList)
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Severity: SeverityError, Line: 3, Column: 5, Code: DiagMissing, Message: "Missing 'modifier'"}
+	if got, want := d.String(), "error: line 3:5: Missing 'modifier' (missing)"; got != want {
+		t.Fatalf("unexpected string: got %q want %q", got, want)
+	}
+
+	d = Diagnostic{Severity: SeverityWarning, Code: DiagMissingAssert, Message: "Missing ';assert'"}
+	if got, want := d.String(), "warning: Missing ';assert' (missing-assert)"; got != want {
+		t.Fatalf("unexpected string: got %q want %q", got, want)
+	}
+}
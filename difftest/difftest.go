@@ -0,0 +1,271 @@
+// Package difftest compares two simulator engines on the same fights.
+//
+// Both engines run every fight with the same FixPos, so they see identical
+// seeds, positions and starting orders. When the results differ, Compare
+// narrows the difference down to the first round and the first executed
+// instruction where the engines' traces disagree. Run does this for randomly
+// generated warriors and Minimize shrinks a mismatching case:
+//
+//	d, err := difftest.Run(difftest.Exmars, difftest.Sim, difftest.Options{
+//		Config: goexmars.DefaultConfig.SetRounds(10),
+//		Fights: 100,
+//	})
+//	if d != nil {
+//		d, _ = difftest.Minimize(difftest.Exmars, difftest.Sim, d)
+//		fmt.Println(d)
+//	}
+package difftest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/sim"
+)
+
+// Engine is a simulator under test.
+type Engine struct {
+	Name        string
+	FightParsed func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error)
+	// TraceParsed is optional. Without it a divergence is only located to
+	// its round.
+	TraceParsed func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error)
+}
+
+var (
+	// Exmars runs fights through the default exmars library. It fails
+	// instead of using a registered fallback when the library cannot be
+	// loaded.
+	Exmars = Engine{
+		Name: "exmars",
+		FightParsed: func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+			lib, err := goexmars.DefaultLibrary()
+			if err != nil {
+				return goexmars.FightResult{}, err
+			}
+			return lib.FightParsed(warriors, cfg)
+		},
+		TraceParsed: func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error) {
+			lib, err := goexmars.DefaultLibrary()
+			if err != nil {
+				return nil, err
+			}
+			return lib.TraceParsed(warriors, cfg, round)
+		},
+	}
+
+	// Sim runs fights through the pure-Go simulator.
+	Sim = Engine{
+		Name:        "sim",
+		FightParsed: sim.FightParsed,
+		TraceParsed: sim.TraceParsed,
+	}
+)
+
+// FromEngine returns an engine under test for a goexmars.Engine, e.g. a
+// goexmars.DefaultEngine or an enginetest.Fake.
+func FromEngine(name string, e goexmars.Engine) Engine {
+	return Engine{
+		Name:        name,
+		FightParsed: e.FightParsed,
+		TraceParsed: e.TraceParsed,
+	}
+}
+
+// LibraryEngine returns an engine for an explicitly loaded library, e.g. to
+// compare two exmars builds.
+func LibraryEngine(lib *goexmars.Library) Engine {
+	return FromEngine(lib.Path(), lib)
+}
+
+// Divergence describes where two engines disagree on a fight.
+type Divergence struct {
+	Warriors []goexmars.ParsedWarrior
+	Config   goexmars.FightConfig
+
+	// A and B are the results of the first and second engine.
+	A, B goexmars.FightResult
+
+	// Round is the first 0-based round whose trace or result differs.
+	Round int
+	// Cycle is the index of the first differing instruction in the trace of
+	// Round, counting every warrior's turn like exmars' cycle counter. It is
+	// -1 if the traces agree or are not available.
+	Cycle int
+	// AStep and BStep are the instructions the engines executed at Cycle.
+	// One of them is nil if that engine's round ended earlier.
+	AStep, BStep *goexmars.TraceStep
+}
+
+// String renders the divergence and the warriors as Redcode.
+func (d *Divergence) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "results differ: wins %v ties %d vs wins %v ties %d\n", d.A.Wins, d.A.Ties, d.B.Wins, d.B.Ties)
+	fmt.Fprintf(&sb, "first difference in round %d", d.Round)
+	if d.Cycle >= 0 {
+		fmt.Fprintf(&sb, ", cycle %d: %s vs %s", d.Cycle, formatStep(d.AStep), formatStep(d.BStep))
+	}
+	sb.WriteString("\n")
+	for i, w := range d.Warriors {
+		fmt.Fprintf(&sb, "\n; warrior %d\nORG %d\n", i, w.End)
+		for _, cmd := range w.Commands {
+			sb.WriteString(cmd.String())
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func formatStep(st *goexmars.TraceStep) string {
+	if st == nil {
+		return "<end of round>"
+	}
+	return fmt.Sprintf("warrior %d at %d %s", st.Warrior, st.Address, st.Command)
+}
+
+// Compare runs warriors through both engines and returns nil if their
+// results agree. cfg.FixPos must be set so both engines place the warriors
+// the same way.
+func Compare(a, b Engine, warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (*Divergence, error) {
+	if cfg.FixPos == 0 {
+		return nil, fmt.Errorf("FixPos must be set so both engines use the same positions")
+	}
+
+	resA, resB, err := fightBoth(a, b, warriors, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if sameResult(resA, resB) {
+		return nil, nil
+	}
+
+	d := &Divergence{Warriors: warriors, Config: cfg, A: resA, B: resB, Cycle: -1}
+
+	// Results are cumulative, so the first round whose prefix differs is the
+	// first round with a different outcome.
+	d.Round = cfg.Rounds - 1
+	for r := 1; r < cfg.Rounds; r++ {
+		pa, pb, err := fightBoth(a, b, warriors, cfg.SetRounds(r))
+		if err != nil {
+			return nil, err
+		}
+		if !sameResult(pa, pb) {
+			d.Round = r - 1
+			break
+		}
+	}
+
+	if a.TraceParsed == nil || b.TraceParsed == nil {
+		return d, nil
+	}
+
+	// State may diverge rounds before an outcome does, e.g. in p-space.
+	for r := 0; r <= d.Round; r++ {
+		ta, err := a.TraceParsed(warriors, cfg, r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: trace round %d: %w", a.Name, r, err)
+		}
+		tb, err := b.TraceParsed(warriors, cfg, r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: trace round %d: %w", b.Name, r, err)
+		}
+		if i := firstDifference(ta, tb); i >= 0 {
+			d.Round, d.Cycle = r, i
+			if i < len(ta) {
+				d.AStep = &ta[i]
+			}
+			if i < len(tb) {
+				d.BStep = &tb[i]
+			}
+			break
+		}
+	}
+	return d, nil
+}
+
+func fightBoth(a, b Engine, warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, goexmars.FightResult, error) {
+	resA, err := a.FightParsed(warriors, cfg)
+	if err != nil {
+		return resA, goexmars.FightResult{}, fmt.Errorf("%s: %w", a.Name, err)
+	}
+	resB, err := b.FightParsed(warriors, cfg)
+	if err != nil {
+		return resA, resB, fmt.Errorf("%s: %w", b.Name, err)
+	}
+	return resA, resB, nil
+}
+
+func sameResult(a, b goexmars.FightResult) bool {
+	return a.Ties == b.Ties && reflect.DeepEqual(a.Wins, b.Wins)
+}
+
+// firstDifference returns the index of the first differing step, or -1.
+func firstDifference(a, b []goexmars.TraceStep) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
+
+// Options configures Run.
+type Options struct {
+	// Config is the fight configuration. A zero FixPos is replaced by a
+	// random one for every fight.
+	Config goexmars.FightConfig
+	// Warriors is the number of warriors per fight. The default is 2.
+	Warriors int
+	// Fights is the number of fights to run. The default is 1.
+	Fights int
+	// Seed seeds the warrior generator.
+	Seed int64
+	// MaxLength limits the generated warriors. The default is the longest
+	// warrior cfg allows.
+	MaxLength int
+}
+
+// Run generates random warriors for opts.Fights fights and compares a and b
+// on each. It returns the first divergence, or nil if all fights agree.
+func Run(a, b Engine, opts Options) (*Divergence, error) {
+	if opts.Warriors == 0 {
+		opts.Warriors = 2
+	}
+	if opts.Fights == 0 {
+		opts.Fights = 1
+	}
+	cfg := opts.Config
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Warriors < 1 || opts.Warriors > 6 || opts.Warriors*cfg.MinSep > cfg.CoreSize {
+		return nil, fmt.Errorf("cannot fit %d warriors with MinSep %d into CoreSize %d", opts.Warriors, cfg.MinSep, cfg.CoreSize)
+	}
+
+	r := rand.New(rand.NewSource(opts.Seed))
+	for i := 0; i < opts.Fights; i++ {
+		warriors := make([]goexmars.ParsedWarrior, opts.Warriors)
+		for j := range warriors {
+			warriors[j] = RandomWarrior(r, cfg, opts.MaxLength)
+		}
+		fightCfg := cfg
+		if fightCfg.FixPos == 0 {
+			fightCfg.FixPos = cfg.MinSep + r.Intn(max(cfg.CoreSize-2*cfg.MinSep+1, 1))
+		}
+
+		d, err := Compare(a, b, warriors, fightCfg)
+		if err != nil {
+			return nil, fmt.Errorf("fight %d: %w", i, err)
+		}
+		if d != nil {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
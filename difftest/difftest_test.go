@@ -0,0 +1,148 @@
+package difftest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/testlib"
+	"github.com/BigJk/goexmars/sim"
+)
+
+func TestRunExmarsMatchesSim(t *testing.T) {
+	testlib.Configure(t)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"default", Options{Config: goexmars.DefaultConfig.SetCycles(4000).SetRounds(4), Fights: 40, Seed: 1}},
+		{"small", Options{Config: goexmars.TinyConfig.SetRounds(6), Fights: 60, Seed: 2}},
+		{"multi", Options{Config: goexmars.DefaultConfig.SetCycles(2000).SetRounds(3), Warriors: 4, Fights: 20, Seed: 3}},
+		{"limited", Options{Config: goexmars.LimitedProcessConfig.SetCycles(3000).SetRounds(3), Warriors: 3, Fights: 20, Seed: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Run(Exmars, Sim, tt.opts)
+			if err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+			if d != nil {
+				t.Fatalf("engines diverge:\n%s", d)
+			}
+		})
+	}
+}
+
+func TestRandomWarriorWithinLimits(t *testing.T) {
+	cfg := goexmars.DefaultConfig.SetMaxWarriorLen(30).SetMinSep(12)
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 200; i++ {
+		w := RandomWarrior(r, cfg, 0)
+		if len(w.Commands) < 1 || len(w.Commands) > 12 {
+			t.Fatalf("unexpected length %d", len(w.Commands))
+		}
+		if w.End < 0 || w.End >= len(w.Commands) {
+			t.Fatalf("End %d outside warrior of length %d", w.End, len(w.Commands))
+		}
+		for _, cmd := range w.Commands {
+			if cmd.A < 0 || cmd.A >= cfg.CoreSize || cmd.B < 0 || cmd.B >= cfg.CoreSize {
+				t.Fatalf("field outside core: %s", cmd)
+			}
+		}
+	}
+}
+
+// buggy is the Go simulator with a wrong result whenever a warrior contains
+// a MUL instruction and a wrong trace once one executes.
+var buggy = Engine{
+	Name: "buggy",
+	FightParsed: func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+		res, err := sim.FightParsed(warriors, cfg)
+		if err != nil || !hasMUL(warriors) {
+			return res, err
+		}
+		res.Ties++
+		return res, nil
+	},
+	TraceParsed: func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error) {
+		trace, err := sim.TraceParsed(warriors, cfg, round)
+		if err != nil {
+			return nil, err
+		}
+		for i := range trace {
+			if trace[i].Command.OpCode == goexmars.OpCodeMUL {
+				trace[i].Command.B++
+				break
+			}
+		}
+		return trace, nil
+	},
+}
+
+func hasMUL(warriors []goexmars.ParsedWarrior) bool {
+	for _, w := range warriors {
+		for _, cmd := range w.Commands {
+			if cmd.OpCode == goexmars.OpCodeMUL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCompareLocatesDivergence(t *testing.T) {
+	mul := goexmars.Command{OpCode: goexmars.OpCodeMUL, Modifier: goexmars.ModifierAB, AddressingModeA: goexmars.AddressingImmediate, A: 3, AddressingModeB: goexmars.AddressingDirect, B: 1}
+	jmp := goexmars.Command{OpCode: goexmars.OpCodeJMP, Modifier: goexmars.ModifierB, AddressingModeA: goexmars.AddressingDirect, A: -1, AddressingModeB: goexmars.AddressingDirect}
+	nop := goexmars.Command{OpCode: goexmars.OpCodeNOP, Modifier: goexmars.ModifierF, AddressingModeA: goexmars.AddressingDirect, AddressingModeB: goexmars.AddressingDirect}
+	warriors := []goexmars.ParsedWarrior{
+		{Commands: []goexmars.Command{nop, mul, jmp}},
+		{Commands: []goexmars.Command{nop, {OpCode: goexmars.OpCodeJMP, Modifier: goexmars.ModifierB, AddressingModeA: goexmars.AddressingDirect, AddressingModeB: goexmars.AddressingDirect}}},
+	}
+	cfg := goexmars.TinyConfig.SetRounds(3).SetFixPos(400)
+
+	d, err := Compare(Sim, buggy, warriors, cfg)
+	if err != nil {
+		t.Fatalf("Compare returned error: %v", err)
+	}
+	if d == nil {
+		t.Fatalf("expected a divergence")
+	}
+	// Round 0 runs warrior 0 first: its NOP, warrior 1's NOP, then the MUL.
+	if d.Round != 0 || d.Cycle != 2 || d.AStep == nil || d.BStep == nil || d.AStep.Command.OpCode != goexmars.OpCodeMUL {
+		t.Fatalf("unexpected divergence:\n%s", d)
+	}
+}
+
+func TestMinimizeShrinksDivergence(t *testing.T) {
+	cfg := goexmars.TinyConfig.SetRounds(4)
+	var found *Divergence
+	for seed := int64(0); found == nil; seed++ {
+		d, err := Run(Sim, buggy, Options{Config: cfg, Fights: 10, Seed: seed})
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		found = d
+	}
+
+	before := 0
+	for _, w := range found.Warriors {
+		before += len(w.Commands)
+	}
+
+	d, err := Minimize(Sim, buggy, found)
+	if err != nil {
+		t.Fatalf("Minimize returned error: %v", err)
+	}
+	if !hasMUL(d.Warriors) {
+		t.Fatalf("minimized case lost the MUL:\n%s", d)
+	}
+	for _, w := range d.Warriors {
+		if len(w.Commands) != 1 {
+			t.Fatalf("warrior not minimized (was %d instructions in total):\n%s", before, d)
+		}
+	}
+	if d.Config.Rounds != d.Round+1 {
+		t.Fatalf("expected rounds trimmed to %d, got %d", d.Round+1, d.Config.Rounds)
+	}
+}
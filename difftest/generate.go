@@ -0,0 +1,52 @@
+package difftest
+
+import (
+	"math/rand"
+
+	"github.com/BigJk/goexmars"
+)
+
+// RandomWarrior returns a random warrior that cfg can load: between 1 and
+// maxLength instructions, never longer than MaxWarriorLen or MinSep. A
+// maxLength of 0 means the longest warrior cfg allows.
+//
+// Every opcode, modifier and addressing mode is equally likely. Fields are
+// mostly small offsets so the code interacts with itself, otherwise they are
+// uniform over the core.
+func RandomWarrior(r *rand.Rand, cfg goexmars.FightConfig, maxLength int) goexmars.ParsedWarrior {
+	limit := min(cfg.MaxWarriorLen, cfg.MinSep)
+	if maxLength > 0 {
+		limit = min(limit, maxLength)
+	}
+
+	n := 1 + r.Intn(limit)
+	w := goexmars.ParsedWarrior{
+		Commands: make([]goexmars.Command, n),
+		End:      r.Intn(n),
+	}
+	for i := range w.Commands {
+		w.Commands[i] = goexmars.Command{
+			OpCode:          goexmars.OpCode(r.Intn(goexmars.OpCodeCount)),
+			Modifier:        goexmars.Modifier(r.Intn(goexmars.ModifierCount)),
+			AddressingModeA: goexmars.AddressingMode(r.Intn(goexmars.AddressingModeCount)),
+			A:               randomField(r, n, cfg.CoreSize),
+			AddressingModeB: goexmars.AddressingMode(r.Intn(goexmars.AddressingModeCount)),
+			B:               randomField(r, n, cfg.CoreSize),
+		}
+	}
+	return w
+}
+
+// randomField returns a value in [0, coreSize), within 2n of 0 three times
+// out of four.
+func randomField(r *rand.Rand, n, coreSize int) int {
+	if r.Intn(4) == 0 {
+		return r.Intn(coreSize)
+	}
+	v := r.Intn(4*n+1) - 2*n
+	v %= coreSize
+	if v < 0 {
+		v += coreSize
+	}
+	return v
+}
@@ -0,0 +1,101 @@
+package difftest
+
+import (
+	"slices"
+
+	"github.com/BigJk/goexmars"
+)
+
+// Minimize shrinks a divergence found for a and b. It drops rounds after
+// the diverging one, then repeatedly removes instructions and simplifies
+// fields and addressing modes, keeping each change only while the engines
+// still disagree. The returned divergence describes the smallest case found.
+func Minimize(a, b Engine, d *Divergence) (*Divergence, error) {
+	best := d
+	try := func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (bool, error) {
+		nd, err := Compare(a, b, warriors, cfg)
+		if err != nil || nd == nil {
+			return false, err
+		}
+		best = nd
+		return true, nil
+	}
+
+	if best.Round+1 < best.Config.Rounds {
+		if _, err := try(best.Warriors, best.Config.SetRounds(best.Round+1)); err != nil {
+			return best, err
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for wi := range best.Warriors {
+			for _, candidate := range shrinkWarrior(best.Warriors[wi]) {
+				warriors := slices.Clone(best.Warriors)
+				warriors[wi] = candidate
+				ok, err := try(warriors, best.Config)
+				if err != nil {
+					return best, err
+				}
+				if ok {
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return best, nil
+}
+
+// shrinkWarrior returns smaller variants of w, most aggressive first.
+func shrinkWarrior(w goexmars.ParsedWarrior) []goexmars.ParsedWarrior {
+	var out []goexmars.ParsedWarrior
+
+	if len(w.Commands) > 1 {
+		for i := range w.Commands {
+			s := goexmars.ParsedWarrior{Commands: slices.Delete(slices.Clone(w.Commands), i, i+1), End: w.End}
+			if s.End > i || s.End == len(s.Commands) {
+				s.End--
+			}
+			out = append(out, s)
+		}
+	}
+	if w.End != 0 {
+		out = append(out, goexmars.ParsedWarrior{Commands: w.Commands, End: 0})
+	}
+
+	for i, cmd := range w.Commands {
+		var simpler []goexmars.Command
+		if cmd.A != 0 {
+			c := cmd
+			c.A = 0
+			simpler = append(simpler, c)
+		}
+		if cmd.B != 0 {
+			c := cmd
+			c.B = 0
+			simpler = append(simpler, c)
+		}
+		if cmd.AddressingModeA != goexmars.AddressingDirect {
+			c := cmd
+			c.AddressingModeA = goexmars.AddressingDirect
+			simpler = append(simpler, c)
+		}
+		if cmd.AddressingModeB != goexmars.AddressingDirect {
+			c := cmd
+			c.AddressingModeB = goexmars.AddressingDirect
+			simpler = append(simpler, c)
+		}
+		if cmd.OpCode != goexmars.OpCodeDAT {
+			c := cmd
+			c.OpCode = goexmars.OpCodeDAT
+			simpler = append(simpler, c)
+		}
+		for _, c := range simpler {
+			cmds := slices.Clone(w.Commands)
+			cmds[i] = c
+			out = append(out, goexmars.ParsedWarrior{Commands: cmds, End: w.End})
+		}
+	}
+	return out
+}
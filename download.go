@@ -2,23 +2,70 @@ package goexmars
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/BigJk/goexmars/internal/atomicfile"
 )
 
 const githubReleasesLatestDownloadBase = "https://github.com/BigJk/goexmars/releases/latest/download"
 
+// DefaultReleasesURL is the base URL DownloadLibWithOptions uses by default.
+const DefaultReleasesURL = "https://github.com/BigJk/goexmars/releases"
+
+// ChecksumManifest is the name of the release asset listing the SHA-256 of
+// every other asset in sha256sum format.
+const ChecksumManifest = "SHA256SUMS"
+
+// Size limits for downloads, far above the real release assets.
+const (
+	maxAssetSize    = 64 << 20
+	maxManifestSize = 1 << 20
+	// maxLibrarySize bounds the decompressed library, which the checksum
+	// does not when the manifest comes from the same mirror as the asset.
+	maxLibrarySize = 64 << 20
+)
+
+// DownloadOptions configures DownloadLibWithOptions.
+type DownloadOptions struct {
+	// Version is the release tag to fetch, e.g. "v1.2.0". Empty selects the
+	// latest release and is only allowed together with Checksum.
+	Version string
+	// BaseURL replaces DefaultReleasesURL, e.g. with an internal mirror or a
+	// file:// directory. It must have the layout of a GitHub releases page:
+	// assets live in <BaseURL>/download/<Version>/ and, for the latest
+	// release, in <BaseURL>/latest/download/.
+	BaseURL string
+	// Checksum is the expected hex SHA-256 of the release archive. If empty,
+	// it is looked up in the ChecksumManifest next to the archive. Only
+	// Checksum pins the content: the manifest comes from the same origin as
+	// the archive, so it detects corrupted downloads but not a compromised
+	// origin.
+	Checksum string
+	// Dir is the destination directory. The library is written to
+	// <Dir>/lib/<platform-library>. Empty means the executable's directory.
+	Dir string
+	// Client is used for http and https URLs. Nil means http.DefaultClient.
+	Client *http.Client
+}
+
 // DownloadLib downloads the latest shared-library release asset for the current
 // OS/arch and extracts it next to the executable.
 //
 // The release zip is expected to contain a top-level lib/ directory, resulting
-// in <exe-dir>/lib/<platform-library>.
+// in <exe-dir>/lib/<platform-library>. The download is not verified; use
+// DownloadLibWithOptions for pinned and checksummed downloads.
 func DownloadLib() error {
 	exePath, err := os.Executable()
 	if err != nil {
@@ -51,7 +98,7 @@ func downloadLibToDir(destDir string, client *http.Client) error {
 		return fmt.Errorf("download release asset: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := readLimited(resp.Body, maxAssetSize, url)
 	if err != nil {
 		return fmt.Errorf("read release asset: %w", err)
 	}
@@ -66,6 +113,183 @@ func downloadLibToDir(destDir string, client *http.Client) error {
 	return nil
 }
 
+// DownloadLibWithOptions downloads the shared-library release asset for the
+// current OS/arch, verifies its SHA-256 and installs the library, returning
+// its path.
+//
+// The checksum comes from opts.Checksum or the release's ChecksumManifest;
+// the download fails if neither is available or the archive does not match.
+// Either opts.Version or opts.Checksum must be set, so the latest release is
+// never installed unverified by a checksum the caller chose.
+// The library replaces any existing file atomically, so a running process
+// never sees a partially written library.
+func DownloadLibWithOptions(opts DownloadOptions) (string, error) {
+	if opts.Version == "" && strings.TrimSpace(opts.Checksum) == "" {
+		return "", errors.New("download: Version or Checksum is required")
+	}
+
+	asset, err := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		exePath, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("resolve executable path: %w", err)
+		}
+		dir = filepath.Dir(exePath)
+	}
+
+	base := opts.BaseURL
+	if base == "" {
+		base = DefaultReleasesURL
+	}
+	base = strings.TrimSuffix(base, "/")
+	if opts.Version == "" {
+		base += "/latest/download"
+	} else {
+		base += "/download/" + url.PathEscape(opts.Version)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	data, err := fetch(client, base+"/"+asset, maxAssetSize)
+	if err != nil {
+		return "", fmt.Errorf("download release asset: %w", err)
+	}
+
+	want := strings.ToLower(strings.TrimSpace(opts.Checksum))
+	if want == "" {
+		manifest, err := fetch(client, base+"/"+ChecksumManifest, maxManifestSize)
+		if err != nil {
+			return "", fmt.Errorf("download checksum manifest: %w", err)
+		}
+		if want, err = lookupChecksum(manifest, asset); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset, got, want)
+	}
+
+	lib, err := readZipEntry(data, "lib/"+exmarsLibraryName(), maxLibrarySize)
+	if err != nil {
+		return "", err
+	}
+	libPath := filepath.Join(dir, "lib", exmarsLibraryName())
+	if err := atomicfile.WriteFile(libPath, lib, 0o755); err != nil {
+		return "", err
+	}
+	return libPath, nil
+}
+
+// fetch reads at most limit bytes from rawURL over http(s) or from a file://
+// URL.
+func fetch(client *http.Client, rawURL string, limit int64) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url %q: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		p := filepath.FromSlash(u.Path)
+		// file:///C:/dir parses to the path /C:/dir.
+		if runtime.GOOS == "windows" && len(u.Path) > 2 && u.Path[0] == '/' && u.Path[2] == ':' {
+			p = filepath.FromSlash(u.Path[1:])
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readLimited(f, limit, rawURL)
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("User-Agent", "goexmars")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: status %d: %s", rawURL, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return readLimited(resp.Body, limit, rawURL)
+}
+
+// readLimited reads r and fails if it holds more than limit bytes.
+func readLimited(r io.Reader, limit int64, name string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes", name, limit)
+	}
+	return data, nil
+}
+
+// lookupChecksum finds name in a sha256sum style manifest.
+func lookupChecksum(manifest []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(manifest))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// A leading '*' marks binary mode in sha256sum output.
+		if strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("read checksum manifest: %w", err)
+	}
+	return "", fmt.Errorf("checksum manifest has no entry for %s", name)
+}
+
+// readZipEntry returns the content of the file called name in a zip
+// archive, failing if it decompresses to more than limit bytes.
+func readZipEntry(zipData []byte, name string, limit int64) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		in, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open zip entry %q: %w", f.Name, err)
+		}
+		defer in.Close()
+		data, err := readLimited(in, limit, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("extract %q: %w", f.Name, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("archive missing expected library %q", name)
+}
+
 func releaseAssetName(goos, goarch string) (string, error) {
 	switch goos {
 	case "darwin":
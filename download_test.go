@@ -0,0 +1,189 @@
+package goexmars
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeTestRelease lays out a release for the current platform under
+// <root>/download/<version> and returns the archive checksum.
+func writeTestRelease(t *testing.T, root, version string, lib []byte) string {
+	t.Helper()
+
+	asset, err := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("lib/" + exmarsLibraryName())
+	if err != nil {
+		t.Fatalf("create zip entry: %v", err)
+	}
+	w.Write(lib)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	dir := filepath.Join(root, "download", version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("create release dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, asset), buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write asset: %v", err)
+	}
+	manifest := fmt.Sprintf("%s  other.zip\n%s *%s\n", strings.Repeat("0", 64), checksum, asset)
+	if err := os.WriteFile(filepath.Join(dir, ChecksumManifest), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	return checksum
+}
+
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+func TestDownloadLibWithOptionsFileMirror(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestRelease(t, mirror, "v1.0.0", []byte("library v1"))
+	dest := t.TempDir()
+
+	path, err := DownloadLibWithOptions(DownloadOptions{Version: "v1.0.0", BaseURL: fileURL(mirror), Dir: dest})
+	if err != nil {
+		t.Fatalf("DownloadLibWithOptions returned error: %v", err)
+	}
+	if want := filepath.Join(dest, "lib", exmarsLibraryName()); path != want {
+		t.Fatalf("library path = %q, want %q", path, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "library v1" {
+		t.Fatalf("unexpected library content %q", data)
+	}
+
+	// A newer version replaces the file in place.
+	writeTestRelease(t, mirror, "v2.0.0", []byte("library v2"))
+	if _, err := DownloadLibWithOptions(DownloadOptions{Version: "v2.0.0", BaseURL: fileURL(mirror), Dir: dest}); err != nil {
+		t.Fatalf("DownloadLibWithOptions returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "library v2" {
+		t.Fatalf("library not replaced, got %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("expected only the library in %s, got %d entries", filepath.Dir(path), len(entries))
+	}
+}
+
+func TestDownloadLibWithOptionsRejectsBadChecksum(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestRelease(t, mirror, "v1.0.0", []byte("library"))
+	dest := t.TempDir()
+
+	_, err := DownloadLibWithOptions(DownloadOptions{
+		Version:  "v1.0.0",
+		BaseURL:  fileURL(mirror),
+		Checksum: strings.Repeat("ab", 32),
+		Dir:      dest,
+	})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "lib")); !os.IsNotExist(err) {
+		t.Fatalf("nothing should be written on checksum mismatch")
+	}
+}
+
+func TestDownloadLibWithOptionsRequiresManifestEntry(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestRelease(t, mirror, "v1.0.0", []byte("library"))
+	os.WriteFile(filepath.Join(mirror, "download", "v1.0.0", ChecksumManifest), []byte("deadbeef  other.zip\n"), 0o644)
+
+	_, err := DownloadLibWithOptions(DownloadOptions{Version: "v1.0.0", BaseURL: fileURL(mirror), Dir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "no entry") {
+		t.Fatalf("expected missing manifest entry error, got %v", err)
+	}
+}
+
+func TestDownloadLibWithOptionsRequiresPin(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestRelease(t, mirror, "v1.0.0", []byte("library"))
+
+	_, err := DownloadLibWithOptions(DownloadOptions{BaseURL: fileURL(mirror), Dir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "Version or Checksum") {
+		t.Fatalf("expected an error for an unpinned download, got %v", err)
+	}
+}
+
+func TestDownloadLibWithOptionsLimitsManifest(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestRelease(t, mirror, "v1.0.0", []byte("library"))
+	big := bytes.Repeat([]byte("0"), maxManifestSize+1)
+	os.WriteFile(filepath.Join(mirror, "download", "v1.0.0", ChecksumManifest), big, 0o644)
+
+	_, err := DownloadLibWithOptions(DownloadOptions{Version: "v1.0.0", BaseURL: fileURL(mirror), Dir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected an oversized manifest error, got %v", err)
+	}
+}
+
+func TestReadZipEntryLimitsSize(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("lib/big")
+	if err != nil {
+		t.Fatalf("create zip entry: %v", err)
+	}
+	w.Write(bytes.Repeat([]byte("0"), 4096))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+
+	if _, err := readZipEntry(buf.Bytes(), "lib/big", 4096); err != nil {
+		t.Fatalf("readZipEntry at the limit returned error: %v", err)
+	}
+	_, err = readZipEntry(buf.Bytes(), "lib/big", 4095)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected an oversized entry error, got %v", err)
+	}
+}
+
+func TestDownloadLibWithOptionsHTTP(t *testing.T) {
+	root := t.TempDir()
+	checksum := writeTestRelease(t, root, "v1.0.0", []byte("library"))
+	// The latest release uses GitHub's latest/download layout.
+	os.MkdirAll(filepath.Join(root, "latest"), 0o755)
+	os.Rename(filepath.Join(root, "download", "v1.0.0"), filepath.Join(root, "latest", "download"))
+	os.Remove(filepath.Join(root, "latest", "download", ChecksumManifest))
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer srv.Close()
+
+	path, err := DownloadLibWithOptions(DownloadOptions{BaseURL: srv.URL + "/", Checksum: strings.ToUpper(checksum), Dir: t.TempDir(), Client: srv.Client()})
+	if err != nil {
+		t.Fatalf("DownloadLibWithOptions returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "library" {
+		t.Fatalf("unexpected library content %q", data)
+	}
+
+	if _, err := DownloadLibWithOptions(DownloadOptions{BaseURL: srv.URL, Version: "v9.9.9", Dir: t.TempDir(), Client: srv.Client()}); err == nil {
+		t.Fatalf("expected error for missing version")
+	}
+}
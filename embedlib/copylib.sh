@@ -0,0 +1,21 @@
+#!/bin/sh
+# Copies the exmars library built by exmars/build.sh into lib/<GOOS>_<GOARCH>/
+# for go:embed. build.sh only builds for the host, so generating for another
+# platform fails instead of embedding the host library under its name.
+set -eu
+
+host="$(go env GOHOSTOS)_$(go env GOHOSTARCH)"
+target="${GOOS}_${GOARCH}"
+if [ "$target" != "$host" ]; then
+  echo "embedlib: ../lib holds the $host library; build exmars for $target and copy it to lib/$target/" >&2
+  exit 1
+fi
+
+case "$GOOS" in
+darwin) name=libexmars.dylib ;;
+windows) name=exmars.dll ;;
+*) name=libexmars.so ;;
+esac
+
+mkdir -p "lib/$target"
+cp "../lib/$name" "lib/$target/"
@@ -0,0 +1,32 @@
+// Package embedlib embeds a prebuilt exmars shared library for the current
+// platform so binaries work without a lib/ directory.
+//
+// The package is only active when built with the goexmars_embed tag:
+//
+//	import _ "github.com/BigJk/goexmars/embedlib"
+//
+//	go build -tags goexmars_embed
+//
+// On first use goexmars asks Extract for the library path. Extract writes the
+// embedded library once to os.UserCacheDir() under a name derived from its
+// SHA-256 hash, so different builds never overwrite each other, and reuses it
+// afterwards. GOEXMARS_LIB_PATH still takes precedence.
+//
+// embedlib is a separate module so the main module stays free of binaries.
+// For every goexmars release vX.Y.Z the release workflow builds the
+// libraries for all supported platforms, commits them into lib/ and tags the
+// result embedlib/vX.Y.Z, which requires goexmars vX.Y.Z:
+//
+//	go get github.com/BigJk/goexmars/embedlib@vX.Y.Z
+//
+// Inside the repository lib/ is not checked in. For the host platform, go
+// generate copies the library exmars/build.sh built; other targets need their
+// library placed in lib/<GOOS>_<GOARCH>/ by hand.
+//
+// Libraries are embedded for linux/amd64, darwin/amd64, darwin/arm64 and
+// windows/amd64. On other platforms the package builds, but Extract returns
+// an error matching fs.ErrNotExist, so a registered fallback such as the sim
+// package is used instead.
+package embedlib
+
+//go:generate sh copylib.sh
@@ -0,0 +1,10 @@
+//go:build goexmars_embed && darwin && amd64
+
+package embedlib
+
+import _ "embed"
+
+const libraryName = "libexmars.dylib"
+
+//go:embed lib/darwin_amd64/libexmars.dylib
+var library []byte
@@ -0,0 +1,10 @@
+//go:build goexmars_embed && darwin && arm64
+
+package embedlib
+
+import _ "embed"
+
+const libraryName = "libexmars.dylib"
+
+//go:embed lib/darwin_arm64/libexmars.dylib
+var library []byte
@@ -0,0 +1,10 @@
+//go:build goexmars_embed && linux && amd64
+
+package embedlib
+
+import _ "embed"
+
+const libraryName = "libexmars.so"
+
+//go:embed lib/linux_amd64/libexmars.so
+var library []byte
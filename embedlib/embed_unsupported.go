@@ -0,0 +1,9 @@
+//go:build goexmars_embed && !((linux && amd64) || (darwin && (amd64 || arm64)) || (windows && amd64))
+
+package embedlib
+
+// No library is embedded for this platform, so Extract returns an error
+// naming it instead of the build failing on an undefined library.
+const libraryName = ""
+
+var library []byte
@@ -0,0 +1,10 @@
+//go:build goexmars_embed && windows && amd64
+
+package embedlib
+
+import _ "embed"
+
+const libraryName = "exmars.dll"
+
+//go:embed lib/windows_amd64/exmars.dll
+var library []byte
@@ -0,0 +1,65 @@
+//go:build goexmars_embed
+
+package embedlib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/atomicfile"
+)
+
+func init() {
+	goexmars.SetLibPathProvider(Extract)
+}
+
+var (
+	extractOnce sync.Once
+	extractPath string
+	extractErr  error
+)
+
+// Extract writes the embedded library to the user cache directory if it is
+// not there yet and returns its path.
+func Extract() (string, error) {
+	extractOnce.Do(func() {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			extractErr = fmt.Errorf("resolve cache directory: %w", err)
+			return
+		}
+		extractPath, extractErr = extractTo(filepath.Join(dir, "goexmars"))
+	})
+	return extractPath, extractErr
+}
+
+// extractTo writes the embedded library into dir under a content-hash name
+// and returns its path. An existing file is kept if its content matches.
+func extractTo(dir string) (string, error) {
+	if len(library) == 0 {
+		return "", fmt.Errorf("embedlib: no exmars library embedded for %s/%s: %w", runtime.GOOS, runtime.GOARCH, fs.ErrNotExist)
+	}
+
+	sum := sha256.Sum256(library)
+	ext := filepath.Ext(libraryName)
+	name := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(libraryName, ext), hex.EncodeToString(sum[:8]), ext)
+	path := filepath.Join(dir, name)
+
+	if data, err := os.ReadFile(path); err == nil && bytes.Equal(data, library) {
+		return path, nil
+	}
+
+	if err := atomicfile.WriteFile(path, library, 0o755); err != nil {
+		return "", fmt.Errorf("install library: %w", err)
+	}
+	return path, nil
+}
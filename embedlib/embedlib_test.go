@@ -0,0 +1,99 @@
+//go:build goexmars_embed
+
+package embedlib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+)
+
+func TestExtractToWritesOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	path, err := extractTo(dir)
+	if err != nil {
+		t.Fatalf("extractTo returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read extracted library: %v", err)
+	}
+	if !bytes.Equal(data, library) {
+		t.Fatalf("extracted library differs from embedded one")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat extracted library: %v", err)
+	}
+	again, err := extractTo(dir)
+	if err != nil || again != path {
+		t.Fatalf("second extractTo = %q, %v; want %q", again, err, path)
+	}
+	info2, err := os.Stat(path)
+	if err != nil || !info2.ModTime().Equal(info.ModTime()) {
+		t.Fatalf("library was rewritten")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the library in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestExtractToReplacesCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	path, err := extractTo(dir)
+	if err != nil {
+		t.Fatalf("extractTo returned error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("truncated"), 0o755); err != nil {
+		t.Fatalf("corrupt library: %v", err)
+	}
+	if _, err := extractTo(dir); err != nil {
+		t.Fatalf("extractTo returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, library) {
+		t.Fatalf("corrupt library was not replaced")
+	}
+}
+
+func TestExtractToWithoutLibrary(t *testing.T) {
+	// Platforms without an embedded library build with an empty one.
+	saved := library
+	library = nil
+	defer func() { library = saved }()
+
+	dir := t.TempDir()
+	if _, err := extractTo(dir); err == nil || !strings.Contains(err.Error(), "no exmars library embedded for") {
+		t.Fatalf("expected a missing library error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing written to %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestFightUsesEmbeddedLibrary(t *testing.T) {
+	t.Setenv("GOEXMARS_LIB_PATH", "")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	res, err := goexmars.Fight([]string{"MOV 0, 1\n"}, goexmars.DefaultConfig.SetRounds(2))
+	if err != nil {
+		t.Fatalf("Fight returned error: %v", err)
+	}
+	if len(res.Wins) != 1 || res.Wins[0] != 2 {
+		t.Fatalf("expected the imp to survive both rounds, got %+v", res)
+	}
+	path, err := Extract()
+	if err != nil {
+		t.Fatalf("Extract returned error: %v", err)
+	}
+	if dir, _ := os.UserCacheDir(); filepath.Dir(path) != filepath.Join(dir, "goexmars") {
+		t.Fatalf("library extracted to unexpected path %s", path)
+	}
+}
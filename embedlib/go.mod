@@ -0,0 +1,11 @@
+module github.com/BigJk/goexmars/embedlib
+
+go 1.25.5
+
+require github.com/BigJk/goexmars v0.0.0-00010101000000-000000000000
+
+require github.com/ebitengine/purego v0.10.0 // indirect
+
+// Development builds use the goexmars tree around this module. Release tags
+// of this module require the matching goexmars version instead.
+replace github.com/BigJk/goexmars => ../
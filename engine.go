@@ -0,0 +1,44 @@
+package goexmars
+
+// Engine fights, assembles and validates warriors, and fights and traces
+// parsed warriors.
+//
+// *Library implements it for an explicitly loaded exmars build and
+// DefaultEngine for the package-level functions. The enginetest package
+// provides a scriptable fake, so code written against Engine can be tested
+// without the shared library.
+type Engine interface {
+	Fight(warriors []string, cfg FightConfig) (FightResult, error)
+	Assemble(warrior string, cfg FightConfig) (string, error)
+	Validate(warrior string, cfg FightConfig) error
+	FightParsed(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error)
+	TraceParsed(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error)
+}
+
+var _ Engine = (*Library)(nil)
+
+// DefaultEngine runs the package-level functions: the default library, or
+// the registered fallback when it cannot be loaded.
+var DefaultEngine Engine = defaultEngine{}
+
+type defaultEngine struct{}
+
+func (defaultEngine) Fight(warriors []string, cfg FightConfig) (FightResult, error) {
+	return Fight(warriors, cfg)
+}
+
+func (defaultEngine) Assemble(warrior string, cfg FightConfig) (string, error) {
+	return Assemble(warrior, cfg)
+}
+
+func (defaultEngine) Validate(warrior string, cfg FightConfig) error {
+	return Validate(warrior, cfg)
+}
+
+func (defaultEngine) FightParsed(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error) {
+	return FightParsed(warriors, cfg)
+}
+
+func (defaultEngine) TraceParsed(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error) {
+	return TraceParsed(warriors, cfg, round)
+}
@@ -0,0 +1,202 @@
+// Package enginetest provides a scriptable goexmars.Engine for tests that
+// should not depend on the shared library.
+//
+//	fake := &enginetest.Fake{}
+//	fake.QueueFight(goexmars.FightResult{Wins: []int{3, 1}, Ties: 1}, nil)
+//	score, err := goexmars.Benchmark{Warriors: opponents, Engine: fake}.Score(w)
+package enginetest
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/BigJk/goexmars"
+)
+
+// Call records one call made to a Fake.
+type Call struct {
+	// Method is "Fight", "Assemble", "Validate", "FightParsed" or
+	// "TraceParsed".
+	Method   string
+	Warriors []string
+	// Parsed holds the warriors of FightParsed and TraceParsed calls.
+	Parsed []goexmars.ParsedWarrior
+	// Round is the traced round of a TraceParsed call.
+	Round  int
+	Config goexmars.FightConfig
+}
+
+// Fake is a goexmars.Engine whose answers are scripted by the test.
+//
+// Queued results are returned first, in order. Once a method's queue is
+// empty its Func field is called, and without one the fake answers with a
+// neutral default: every round of a fight is a tie, Assemble returns the
+// source unchanged, Validate succeeds and a trace is empty. All calls are
+// recorded. A Fake is safe for concurrent use; set the Func fields before
+// sharing it.
+type Fake struct {
+	FightFunc    func(warriors []string, cfg goexmars.FightConfig) (goexmars.FightResult, error)
+	AssembleFunc func(warrior string, cfg goexmars.FightConfig) (string, error)
+	ValidateFunc func(warrior string, cfg goexmars.FightConfig) error
+	// FightParsedFunc and TraceParsedFunc answer FightParsed and
+	// TraceParsed calls.
+	FightParsedFunc func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error)
+	TraceParsedFunc func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error)
+
+	mu           sync.Mutex
+	fights       []fightAnswer
+	assembly     []assembleAnswer
+	validity     []error
+	parsedFights []fightAnswer
+	traces       []traceAnswer
+	calls        []Call
+}
+
+var _ goexmars.Engine = (*Fake)(nil)
+
+type fightAnswer struct {
+	result goexmars.FightResult
+	err    error
+}
+
+type assembleAnswer struct {
+	listing string
+	err     error
+}
+
+type traceAnswer struct {
+	steps []goexmars.TraceStep
+	err   error
+}
+
+// QueueFight makes a later Fight call return result and err.
+func (f *Fake) QueueFight(result goexmars.FightResult, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fights = append(f.fights, fightAnswer{result, err})
+}
+
+// QueueAssemble makes a later Assemble call return listing and err.
+func (f *Fake) QueueAssemble(listing string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.assembly = append(f.assembly, assembleAnswer{listing, err})
+}
+
+// QueueValidate makes a later Validate call return err.
+func (f *Fake) QueueValidate(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.validity = append(f.validity, err)
+}
+
+// QueueFightParsed makes a later FightParsed call return result and err.
+func (f *Fake) QueueFightParsed(result goexmars.FightResult, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parsedFights = append(f.parsedFights, fightAnswer{result, err})
+}
+
+// QueueTrace makes a later TraceParsed call return steps and err.
+func (f *Fake) QueueTrace(steps []goexmars.TraceStep, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.traces = append(f.traces, traceAnswer{steps, err})
+}
+
+// Calls returns the calls made so far.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// Fight implements goexmars.Engine.
+func (f *Fake) Fight(warriors []string, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: "Fight", Warriors: slices.Clone(warriors), Config: cfg})
+	if len(f.fights) > 0 {
+		a := f.fights[0]
+		f.fights = f.fights[1:]
+		f.mu.Unlock()
+		return a.result, a.err
+	}
+	f.mu.Unlock()
+
+	if f.FightFunc != nil {
+		return f.FightFunc(warriors, cfg)
+	}
+	return goexmars.FightResult{Wins: make([]int, len(warriors)), Ties: cfg.Rounds}, nil
+}
+
+// Assemble implements goexmars.Engine.
+func (f *Fake) Assemble(warrior string, cfg goexmars.FightConfig) (string, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: "Assemble", Warriors: []string{warrior}, Config: cfg})
+	if len(f.assembly) > 0 {
+		a := f.assembly[0]
+		f.assembly = f.assembly[1:]
+		f.mu.Unlock()
+		return a.listing, a.err
+	}
+	f.mu.Unlock()
+
+	if f.AssembleFunc != nil {
+		return f.AssembleFunc(warrior, cfg)
+	}
+	return warrior, nil
+}
+
+// Validate implements goexmars.Engine.
+func (f *Fake) Validate(warrior string, cfg goexmars.FightConfig) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: "Validate", Warriors: []string{warrior}, Config: cfg})
+	if len(f.validity) > 0 {
+		err := f.validity[0]
+		f.validity = f.validity[1:]
+		f.mu.Unlock()
+		return err
+	}
+	f.mu.Unlock()
+
+	if f.ValidateFunc != nil {
+		return f.ValidateFunc(warrior, cfg)
+	}
+	return nil
+}
+
+// FightParsed implements goexmars.Engine.
+func (f *Fake) FightParsed(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: "FightParsed", Parsed: slices.Clone(warriors), Config: cfg})
+	if len(f.parsedFights) > 0 {
+		a := f.parsedFights[0]
+		f.parsedFights = f.parsedFights[1:]
+		f.mu.Unlock()
+		return a.result, a.err
+	}
+	f.mu.Unlock()
+
+	if f.FightParsedFunc != nil {
+		return f.FightParsedFunc(warriors, cfg)
+	}
+	return goexmars.FightResult{Wins: make([]int, len(warriors)), Ties: cfg.Rounds}, nil
+}
+
+// TraceParsed implements goexmars.Engine.
+func (f *Fake) TraceParsed(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: "TraceParsed", Parsed: slices.Clone(warriors), Round: round, Config: cfg})
+	if len(f.traces) > 0 {
+		a := f.traces[0]
+		f.traces = f.traces[1:]
+		f.mu.Unlock()
+		return a.steps, a.err
+	}
+	f.mu.Unlock()
+
+	if f.TraceParsedFunc != nil {
+		return f.TraceParsedFunc(warriors, cfg, round)
+	}
+	return nil, nil
+}
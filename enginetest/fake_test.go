@@ -0,0 +1,125 @@
+package enginetest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+)
+
+func TestFakeQueuesThenDefaults(t *testing.T) {
+	f := &Fake{}
+	cfg := goexmars.DefaultConfig.SetRounds(5)
+	boom := errors.New("boom")
+
+	f.QueueFight(goexmars.FightResult{Wins: []int{4, 1}}, nil)
+	f.QueueFight(goexmars.FightResult{}, boom)
+
+	if res, err := f.Fight([]string{"a", "b"}, cfg); err != nil || !reflect.DeepEqual(res.Wins, []int{4, 1}) {
+		t.Fatalf("first Fight = %+v, %v", res, err)
+	}
+	if _, err := f.Fight([]string{"a", "b"}, cfg); err != boom {
+		t.Fatalf("second Fight error = %v, want boom", err)
+	}
+	if res, err := f.Fight([]string{"a", "b", "c"}, cfg); err != nil || res.Ties != 5 || len(res.Wins) != 3 {
+		t.Fatalf("default Fight = %+v, %v", res, err)
+	}
+
+	f.QueueValidate(boom)
+	if err := f.Validate("x", cfg); err != boom {
+		t.Fatalf("Validate = %v, want boom", err)
+	}
+	if err := f.Validate("x", cfg); err != nil {
+		t.Fatalf("default Validate = %v", err)
+	}
+	if got, err := f.Assemble("src", cfg); err != nil || got != "src" {
+		t.Fatalf("default Assemble = %q, %v", got, err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 6 || calls[0].Method != "Fight" || calls[5].Method != "Assemble" || calls[2].Warriors[2] != "c" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+func TestFakeFuncs(t *testing.T) {
+	f := &Fake{
+		FightFunc: func(warriors []string, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+			return goexmars.FightResult{Wins: []int{cfg.Rounds}, Ties: 0}, nil
+		},
+	}
+	res, err := goexmars.FightNamedWithEngine(f, map[string]string{"imp": "MOV 0, 1"}, goexmars.DefaultConfig.SetRounds(7))
+	if err != nil {
+		t.Fatalf("FightNamedWithEngine returned error: %v", err)
+	}
+	if wins, ties := res.Get("imp"); wins != 7 || ties != 0 {
+		t.Fatalf("Get(imp) = %d, %d", wins, ties)
+	}
+}
+
+func TestBenchmarkWithFake(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.red", "b.red"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(";name "+name+"\n"), 0o644); err != nil {
+			t.Fatalf("write warrior: %v", err)
+		}
+	}
+
+	f := &Fake{
+		AssembleFunc: func(warrior string, cfg goexmars.FightConfig) (string, error) {
+			return "MOV.I $ 0, $ 1\nEND 0\n", nil
+		},
+	}
+	cfg := goexmars.DefaultConfig.SetRounds(4)
+	bm, err := goexmars.BenchmarkFromFolderWithEngine(f, dir, cfg)
+	if err != nil {
+		t.Fatalf("BenchmarkFromFolderWithEngine returned error: %v", err)
+	}
+	if len(bm.Warriors) != 2 || bm.Warriors[1].Name != "b.red" || bm.Engine != f {
+		t.Fatalf("unexpected benchmark %+v", bm)
+	}
+
+	f.QueueFight(goexmars.FightResult{Wins: []int{3, 1}, Ties: 0}, nil)
+	f.QueueFight(goexmars.FightResult{Wins: []int{0, 2}, Ties: 2}, nil)
+	score, err := bm.Score(bm.Warriors[0])
+	if err != nil {
+		t.Fatalf("Score returned error: %v", err)
+	}
+	if want := (goexmars.BenchmarkScore{Wins: 3, Losses: 3, Ties: 2}); score != want {
+		t.Fatalf("Score = %+v, want %+v", score, want)
+	}
+}
+
+func TestFakeParsed(t *testing.T) {
+	f := &Fake{}
+	cfg := goexmars.DefaultConfig.SetRounds(3)
+	imp := goexmars.ParsedWarrior{Commands: []goexmars.Command{{OpCode: goexmars.OpCodeMOV, Modifier: goexmars.ModifierI, B: 1}}}
+	boom := errors.New("boom")
+
+	f.QueueFightParsed(goexmars.FightResult{Wins: []int{2}}, nil)
+	if res, err := goexmars.FightParsedWithEngine(f, []goexmars.ParsedWarrior{imp}, cfg); err != nil || !reflect.DeepEqual(res.Wins, []int{2}) {
+		t.Fatalf("queued FightParsed = %+v, %v", res, err)
+	}
+	if res, err := f.FightParsed([]goexmars.ParsedWarrior{imp, imp}, cfg); err != nil || res.Ties != 3 || len(res.Wins) != 2 {
+		t.Fatalf("default FightParsed = %+v, %v", res, err)
+	}
+
+	f.QueueTrace(nil, boom)
+	if _, err := goexmars.ClassifyWithEngine(f, imp, cfg); !errors.Is(err, boom) {
+		t.Fatalf("ClassifyWithEngine error = %v, want boom", err)
+	}
+	f.TraceParsedFunc = func(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error) {
+		return []goexmars.TraceStep{{Command: warriors[0].Commands[0]}}, nil
+	}
+	if c, err := goexmars.ClassifyWithEngine(f, imp, cfg); err != nil || !c.Behavioural {
+		t.Fatalf("ClassifyWithEngine = %+v, %v", c, err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 4 || calls[2].Method != "TraceParsed" || calls[2].Round != 0 || calls[2].Config.Rounds != 1 || len(calls[1].Parsed) != 2 {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
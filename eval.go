@@ -0,0 +1,131 @@
+package goexmars
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// EvalExpression evaluates a Redcode expression with pMARS eval_expr, the
+// way the assembler evaluates an ;assert line of a single warrior assembled
+// with cfg.
+//
+// The predefined constants (CORESIZE, MAXLENGTH, WARRIORS, ...) take their
+// values from cfg, and defines are substituted like EQU constants, overriding
+// predefined names; their names must pass CheckDefines. Single letters are
+// the pMARS registers. Failures are
+// returned as *AssembleError with a DiagBadExpression or DiagDivisionByZero
+// diagnostic. An overflow is only a warning in pMARS: the wrapped value is
+// returned together with an error holding a DiagOverflow warning.
+//
+// When the library cannot be loaded, the registered fallback evaluates the
+// expression, if it supports that.
+func EvalExpression(expr string, cfg FightConfig, defines map[string]int) (int, error) {
+	lib, f, err := libraryOrFallback()
+	if err != nil {
+		return 0, err
+	}
+	if f != nil {
+		if f.EvalExpression == nil {
+			return 0, fmt.Errorf("fallback %s does not evaluate expressions", f.Name)
+		}
+		return f.EvalExpression(expr, cfg, defines)
+	}
+	return lib.EvalExpression(expr, cfg, defines)
+}
+
+// EvalExpression is the package-level EvalExpression using l.
+func (l *Library) EvalExpression(expr string, cfg FightConfig, defines map[string]int) (int, error) {
+	cfg.Rounds = 1
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+
+	if err := CheckDefines(defines); err != nil {
+		return 0, err
+	}
+
+	// The names are passed back to back, each NUL-terminated.
+	var names []byte
+	values := make([]int64, 0, len(defines))
+	for name, v := range defines {
+		names = append(append(names, name...), 0)
+		values = append(values, int64(v))
+	}
+	var namesPtr, valuesPtr unsafe.Pointer
+	if len(values) > 0 {
+		namesPtr, valuesPtr = unsafe.Pointer(&names[0]), unsafe.Pointer(&values[0])
+	}
+	cfgC := toCFightCfg(cfg)
+	diag := newDiagnosticsBuffers()
+
+	var result int64
+	var rc int32
+	for {
+		rc = l.evalExpr1(
+			expr,
+			unsafe.Pointer(&cfgC),
+			namesPtr, valuesPtr, int32(len(values)),
+			&result,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
+		)
+		runtime.KeepAlive(diag.recs)
+		runtime.KeepAlive(names)
+		runtime.KeepAlive(values)
+		if !diag.truncated() {
+			break
+		}
+		diag.grow()
+	}
+
+	switch {
+	case rc < 0:
+		return 0, diag.err()
+	case rc > 0:
+		return int(result), diag.err()
+	}
+	return int(result), nil
+}
+
+// CheckDefines returns an error for the first name of defines, in sorted
+// order, that an EQU could not define: names must be identifiers of at least
+// two letters, digits or underscores, starting with a letter or underscore,
+// since single letters are registers, and must not be an opcode or a
+// pseudo-opcode.
+func CheckDefines(defines map[string]int) error {
+	names := make([]string, 0, len(defines))
+	for name := range defines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !isDefineName(name) {
+			return fmt.Errorf("invalid define name %q", name)
+		}
+	}
+	return nil
+}
+
+func isDefineName(name string) bool {
+	if len(name) < 2 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	if _, ok := parseOpCode(name); ok {
+		return false
+	}
+	switch strings.ToUpper(name) {
+	case "EQU", "END", "ORG", "FOR", "ROF", "PIN":
+		return false
+	}
+	return true
+}
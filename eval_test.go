@@ -0,0 +1,72 @@
+package goexmars
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestEvalExpression(t *testing.T) {
+	testlib.Configure(t)
+
+	defines := map[string]int{"dx": 21, "step": -4}
+	cases := []struct {
+		expr string
+		want int
+	}{
+		{"(CORESIZE/3)+1", 2667},
+		{"CORESIZE % 4 == 0", 1},
+		{"MAXLENGTH <= 100 && WARRIORS", 1},
+		{"dx*2", 42},
+		{"2--dx", 23},
+		{"step", -4},
+		{"-7/2", -3},
+		{"(a=3)+a", 6},
+		{"1+2*3-4", 3},
+	}
+	for _, tc := range cases {
+		got, err := EvalExpression(tc.expr, DefaultConfig, defines)
+		if err != nil {
+			t.Fatalf("EvalExpression(%q) returned error: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Fatalf("EvalExpression(%q) = %d, want %d", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestEvalExpressionDefinesOverridePredefined(t *testing.T) {
+	testlib.Configure(t)
+
+	got, err := EvalExpression("CORESIZE", DefaultConfig, map[string]int{"CORESIZE": 55440})
+	if err != nil || got != 55440 {
+		t.Fatalf("EvalExpression = %d, %v; want 55440", got, err)
+	}
+}
+
+func TestEvalExpressionErrors(t *testing.T) {
+	testlib.Configure(t)
+
+	cases := map[string]DiagnosticCode{
+		"1/0": DiagDivisionByZero,
+		"1+":  DiagBadExpression,
+	}
+	for expr, code := range cases {
+		_, err := EvalExpression(expr, DefaultConfig, nil)
+		var asmErr *AssembleError
+		if !errors.As(err, &asmErr) || len(asmErr.Diagnostics) != 1 || asmErr.Diagnostics[0].Code != code {
+			t.Fatalf("EvalExpression(%q) error = %v, want diagnostic %v", expr, err, code)
+		}
+	}
+
+	v, err := EvalExpression("9223372036854775807+1", DefaultConfig, nil)
+	var asmErr *AssembleError
+	if !errors.As(err, &asmErr) || asmErr.Diagnostics[0].Code != DiagOverflow ||
+		asmErr.Diagnostics[0].Severity != SeverityWarning {
+		t.Fatalf("expected an overflow warning, got %v", err)
+	}
+	if v != -9223372036854775808 {
+		t.Fatalf("expected the wrapped value, got %d", v)
+	}
+}
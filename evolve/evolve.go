@@ -0,0 +1,141 @@
+// Package evolve provides seeded, composable mutation operators for evolving
+// warriors.
+//
+// An Operator returns a mutated copy of a warrior and never modifies its
+// input. All randomness comes from the *rand.Rand passed in, so a run is
+// reproducible from its seed. Operators can be combined with Choose, Chain
+// and Repeat, and Weights builds a weighted choice of the standard operators:
+//
+//	r := rand.New(rand.NewSource(1))
+//	mutate := evolve.DefaultWeights.Operator()
+//	child := mutate(r, parent, goexmars.DefaultConfig)
+//
+// Every operator keeps a warrior within the length cfg allows, see MaxLength,
+// and keeps a valid END offset valid. cfg must pass FightConfig.Validate.
+package evolve
+
+import (
+	"math/rand"
+
+	"github.com/BigJk/goexmars"
+)
+
+// Operator returns a mutated copy of w. An operator that does not apply,
+// e.g. Delete on a single instruction, returns w unchanged.
+type Operator func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior
+
+// Weighted pairs an operator with its relative weight for Choose.
+type Weighted struct {
+	Op     Operator
+	Weight float64
+}
+
+// Choose returns an operator that applies one of ops, picked with
+// probability proportional to its weight. Operators with a weight of zero or
+// less are never picked; if no weight is positive w is returned unchanged.
+func Choose(ops ...Weighted) Operator {
+	total := 0.0
+	for _, o := range ops {
+		if o.Weight > 0 {
+			total += o.Weight
+		}
+	}
+	return func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+		if total <= 0 {
+			return w
+		}
+		x := r.Float64() * total
+		last := -1
+		for i, o := range ops {
+			if o.Weight <= 0 {
+				continue
+			}
+			last = i
+			if x < o.Weight {
+				return o.Op(r, w, cfg)
+			}
+			x -= o.Weight
+		}
+		// Rounding can leave x just above the last weight.
+		return ops[last].Op(r, w, cfg)
+	}
+}
+
+// Chain returns an operator that applies ops in order.
+func Chain(ops ...Operator) Operator {
+	return func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+		for _, op := range ops {
+			w = op(r, w, cfg)
+		}
+		return w
+	}
+}
+
+// Repeat returns an operator that applies op n times.
+func Repeat(n int, op Operator) Operator {
+	return func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+		for i := 0; i < n; i++ {
+			w = op(r, w, cfg)
+		}
+		return w
+	}
+}
+
+// Weights are the relative weights of the standard operators in
+// Weights.Operator.
+type Weights struct {
+	OpCode         float64
+	Modifier       float64
+	AddressingMode float64
+	Nudge          float64
+	Randomize      float64
+	Insert         float64
+	Delete         float64
+	Duplicate      float64
+	ShiftEnd       float64
+
+	// MaxNudge is the largest change made by Nudge. Zero means
+	// DefaultMaxNudge.
+	MaxNudge int
+}
+
+// DefaultMaxNudge is the default largest change made by Nudge.
+const DefaultMaxNudge = 4
+
+// DefaultWeights favours small operand changes over structural ones.
+var DefaultWeights = Weights{
+	OpCode:         2,
+	Modifier:       2,
+	AddressingMode: 2,
+	Nudge:          4,
+	Randomize:      1,
+	Insert:         1,
+	Delete:         1,
+	Duplicate:      1,
+	ShiftEnd:       1,
+}
+
+// Operator returns a weighted choice of the standard operators.
+func (ws Weights) Operator() Operator {
+	maxNudge := ws.MaxNudge
+	if maxNudge == 0 {
+		maxNudge = DefaultMaxNudge
+	}
+	return Choose(
+		Weighted{SwapOpCode, ws.OpCode},
+		Weighted{ChangeModifier, ws.Modifier},
+		Weighted{ChangeAddressingMode, ws.AddressingMode},
+		Weighted{Nudge(maxNudge), ws.Nudge},
+		Weighted{RandomizeOperand, ws.Randomize},
+		Weighted{Insert, ws.Insert},
+		Weighted{Delete, ws.Delete},
+		Weighted{Duplicate, ws.Duplicate},
+		Weighted{ShiftEnd, ws.ShiftEnd},
+	)
+}
+
+// MaxLength returns the longest warrior cfg allows: MaxWarriorLen, or MinSep
+// if that is smaller, since exmars rejects warriors longer than MinSep.
+func MaxLength(cfg goexmars.FightConfig) int {
+	return min(cfg.MaxWarriorLen, cfg.MinSep)
+}
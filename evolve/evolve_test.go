@@ -0,0 +1,234 @@
+package evolve
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+)
+
+// dwarf is DAT #0, #0 / ADD.AB #4, $-1 / MOV.AB #0, @-2 / JMP $-2 starting
+// at the ADD.
+func dwarf() goexmars.ParsedWarrior {
+	b := goexmars.NewBuilder(goexmars.DefaultConfig)
+	b.Label("ptr").Dat(goexmars.ModifierF, goexmars.Imm(0), goexmars.Imm(0))
+	b.Label("loop").Add(goexmars.ModifierAB, goexmars.Imm(4), goexmars.Lbl("ptr"))
+	b.Mov(goexmars.ModifierAB, goexmars.Imm(0), goexmars.Lbl("ptr").With(goexmars.AddressingBIndirect))
+	b.Jmp(goexmars.ModifierB, goexmars.Lbl("loop"), goexmars.Dir(0))
+	b.Org("loop")
+	w, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func TestOperatorsKeepWarriorValid(t *testing.T) {
+	cfg := goexmars.DefaultConfig.SetMaxWarriorLen(8).SetMinSep(8)
+	ops := map[string]Operator{
+		"SwapOpCode":           SwapOpCode,
+		"ChangeModifier":       ChangeModifier,
+		"ChangeAddressingMode": ChangeAddressingMode,
+		"Nudge":                Nudge(DefaultMaxNudge),
+		"RandomizeOperand":     RandomizeOperand,
+		"Insert":               Insert,
+		"Delete":               Delete,
+		"Duplicate":            Duplicate,
+		"ShiftEnd":             ShiftEnd,
+		"Default":              DefaultWeights.Operator(),
+	}
+	for name, op := range ops {
+		r := rand.New(rand.NewSource(1))
+		w := dwarf()
+		for i := 0; i < 500; i++ {
+			w = op(r, w, cfg)
+			for _, issue := range goexmars.CheckWarrior(w, cfg) {
+				if issue.Severity == goexmars.SeverityError || issue.Code == goexmars.IssueOperandOutOfRange || issue.Code == goexmars.IssueEndOutOfRange {
+					t.Fatalf("%s: step %d produced %v:\n%s", name, i, issue, w.Format(goexmars.RedcodeFormatOptions{IncludeEnd: true}))
+				}
+			}
+		}
+	}
+}
+
+func TestOperatorsDoNotModifyInput(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	w := dwarf()
+	orig := dwarf()
+	op := DefaultWeights.Operator()
+	for i := 0; i < 200; i++ {
+		op(r, w, goexmars.DefaultConfig)
+	}
+	if !reflect.DeepEqual(w, orig) {
+		t.Fatalf("operator modified its input: %+v", w.Commands)
+	}
+}
+
+func TestOperatorsAreSeeded(t *testing.T) {
+	run := func() goexmars.ParsedWarrior {
+		r := rand.New(rand.NewSource(42))
+		return Repeat(50, DefaultWeights.Operator())(r, dwarf(), goexmars.DefaultConfig)
+	}
+	if a, b := run(), run(); !reflect.DeepEqual(a, b) {
+		t.Fatalf("same seed gave different warriors:\n%v\n%v", a.Commands, b.Commands)
+	}
+}
+
+func TestSingleStepOperators(t *testing.T) {
+	cfg := goexmars.DefaultConfig
+	r := rand.New(rand.NewSource(3))
+	w := dwarf()
+
+	differs := func(name string, got goexmars.ParsedWarrior, field func(goexmars.Command) int) {
+		t.Helper()
+		changed := 0
+		for i := range w.Commands {
+			if field(got.Commands[i]) != field(w.Commands[i]) {
+				changed++
+			}
+		}
+		if changed != 1 {
+			t.Fatalf("%s changed %d instructions: %v", name, changed, got.Commands)
+		}
+	}
+	differs("SwapOpCode", SwapOpCode(r, w, cfg), func(c goexmars.Command) int { return int(c.OpCode) })
+	differs("ChangeModifier", ChangeModifier(r, w, cfg), func(c goexmars.Command) int { return int(c.Modifier) })
+	differs("ChangeAddressingMode", ChangeAddressingMode(r, w, cfg), func(c goexmars.Command) int {
+		return int(c.AddressingModeA)<<8 | int(c.AddressingModeB)
+	})
+	differs("Nudge", Nudge(1)(r, w, cfg), func(c goexmars.Command) int { return c.A<<16 ^ c.B })
+
+	for i := 0; i < 20; i++ {
+		if e := ShiftEnd(r, w, cfg).End; e != 0 && e != 2 {
+			t.Fatalf("ShiftEnd moved END from 1 to %d", e)
+		}
+	}
+}
+
+func TestStructuralOperatorsKeepReferences(t *testing.T) {
+	cfg := goexmars.DefaultConfig
+	w := dwarf()
+
+	for seed := int64(0); seed < 20; seed++ {
+		dup := Duplicate(rand.New(rand.NewSource(seed)), w, cfg)
+		if len(dup.Commands) != 5 {
+			t.Fatalf("Duplicate gave %d instructions", len(dup.Commands))
+		}
+		// The dwarf has no identical neighbours, so the copy is the second
+		// of the only identical pair.
+		p := 1
+		for dup.Commands[p] != dup.Commands[p-1] {
+			p++
+		}
+		pos := func(j int) int {
+			if j >= p {
+				return j + 1
+			}
+			return j
+		}
+
+		for j, cmd := range w.Commands {
+			got := dup.Commands[pos(j)]
+			for _, f := range []struct {
+				mode          goexmars.AddressingMode
+				before, after int
+			}{{cmd.AddressingModeA, cmd.A, got.A}, {cmd.AddressingModeB, cmd.B, got.B}} {
+				if f.mode == goexmars.AddressingImmediate {
+					continue
+				}
+				if pos(j)+f.after != pos(j+f.before) {
+					t.Fatalf("seed %d: instruction %d no longer refers to %d:\n%v", seed, j, j+f.before, dup.Commands)
+				}
+			}
+		}
+		if dup.End != pos(w.End) {
+			t.Fatalf("seed %d: END moved to %d, want %d", seed, dup.End, pos(w.End))
+		}
+	}
+
+	// Deleting the DAT makes ADD and MOV refer to the ADD that takes its
+	// place, and moves END back to the ADD.
+	del := Delete(rand.New(rand.NewSource(0)), w, cfg)
+	for seed := int64(1); del.Commands[0].OpCode != goexmars.OpCodeADD; seed++ {
+		del = Delete(rand.New(rand.NewSource(seed)), w, cfg)
+	}
+	if del.End != 0 || del.Commands[0].B != 0 || del.Commands[1].B != -1 || del.Commands[2].A != -2 {
+		t.Fatalf("unexpected result of deleting the DAT: END %d %v", del.End, del.Commands)
+	}
+}
+
+func TestStructuralOperatorsRelocateWrappedOperands(t *testing.T) {
+	cfg := goexmars.DefaultConfig
+	// Loaded from exmars instructions, the dwarf's backward references are
+	// wrapped: the ADD refers to the DAT as 7999 rather than -1.
+	w := dwarf()
+	for i, cmd := range w.Commands {
+		c, err := goexmars.CommandFromInsn(cmd.Insn(cfg.CoreSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Commands[i] = c
+	}
+	if w.Commands[1].B != cfg.CoreSize-1 {
+		t.Fatalf("expected a wrapped operand, got %v", w.Commands[1])
+	}
+
+	// Duplicating the DAT moves every other instruction one further away
+	// from it.
+	dup := Duplicate(rand.New(rand.NewSource(0)), w, cfg)
+	for seed := int64(1); dup.Commands[1].OpCode != goexmars.OpCodeDAT; seed++ {
+		dup = Duplicate(rand.New(rand.NewSource(seed)), w, cfg)
+	}
+	if dup.Commands[2].B != -2 || dup.Commands[3].B != -3 || dup.Commands[4].A != -2 {
+		t.Fatalf("unexpected result of duplicating the DAT: %v", dup.Commands)
+	}
+
+	// Deleting the DAT makes ADD and MOV refer to the ADD as in
+	// TestStructuralOperatorsKeepReferences.
+	del := Delete(rand.New(rand.NewSource(0)), w, cfg)
+	for seed := int64(1); del.Commands[0].OpCode != goexmars.OpCodeADD; seed++ {
+		del = Delete(rand.New(rand.NewSource(seed)), w, cfg)
+	}
+	if del.End != 0 || del.Commands[0].B != 0 || del.Commands[1].B != -1 || del.Commands[2].A != -2 {
+		t.Fatalf("unexpected result of deleting the DAT: END %d %v", del.End, del.Commands)
+	}
+}
+
+func TestChoose(t *testing.T) {
+	calls := make([]int, 3)
+	counter := func(i int) Operator {
+		return func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+			calls[i]++
+			return w
+		}
+	}
+	op := Choose(Weighted{counter(0), 3}, Weighted{counter(1), 0}, Weighted{counter(2), 1})
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		op(r, goexmars.ParsedWarrior{}, goexmars.DefaultConfig)
+	}
+	if calls[1] != 0 {
+		t.Fatalf("zero-weight operator was chosen %d times", calls[1])
+	}
+	if calls[0] < 2700 || calls[0] > 3300 {
+		t.Fatalf("unexpected distribution %v", calls)
+	}
+
+	w := dwarf()
+	if got := Choose()(r, w, goexmars.DefaultConfig); !reflect.DeepEqual(got, w) {
+		t.Fatalf("empty Choose changed the warrior")
+	}
+}
+
+func TestInsertRespectsMaxLength(t *testing.T) {
+	cfg := goexmars.DefaultConfig.SetMaxWarriorLen(6)
+	r := rand.New(rand.NewSource(5))
+	w := dwarf()
+	for i := 0; i < 20; i++ {
+		w = Chain(Insert, Duplicate)(r, w, cfg)
+	}
+	if len(w.Commands) != MaxLength(cfg) {
+		t.Fatalf("expected %d instructions, got %d", MaxLength(cfg), len(w.Commands))
+	}
+}
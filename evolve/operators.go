@@ -0,0 +1,244 @@
+package evolve
+
+import (
+	"math/rand"
+	"slices"
+
+	"github.com/BigJk/goexmars"
+)
+
+// SwapOpCode replaces the opcode of a random instruction with a different
+// one.
+func SwapOpCode(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) == 0 {
+		return w
+	}
+	w.Commands = slices.Clone(w.Commands)
+	cmd := &w.Commands[r.Intn(len(w.Commands))]
+	cmd.OpCode = goexmars.OpCode(other(r, int(cmd.OpCode), goexmars.OpCodeCount))
+	return w
+}
+
+// ChangeModifier replaces the modifier of a random instruction with a
+// different one.
+func ChangeModifier(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) == 0 {
+		return w
+	}
+	w.Commands = slices.Clone(w.Commands)
+	cmd := &w.Commands[r.Intn(len(w.Commands))]
+	cmd.Modifier = goexmars.Modifier(other(r, int(cmd.Modifier), goexmars.ModifierCount))
+	return w
+}
+
+// ChangeAddressingMode replaces the A or B addressing mode of a random
+// instruction with a different one.
+func ChangeAddressingMode(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) == 0 {
+		return w
+	}
+	w.Commands = slices.Clone(w.Commands)
+	cmd := &w.Commands[r.Intn(len(w.Commands))]
+	mode := &cmd.AddressingModeA
+	if r.Intn(2) == 1 {
+		mode = &cmd.AddressingModeB
+	}
+	*mode = goexmars.AddressingMode(other(r, int(*mode), goexmars.AddressingModeCount))
+	return w
+}
+
+// Nudge returns an operator that adds a non-zero value in
+// [-maxDelta, maxDelta] to the A or B operand of a random instruction. The
+// result is kept within ±CoreSize.
+func Nudge(maxDelta int) Operator {
+	return func(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+		if len(w.Commands) == 0 || maxDelta <= 0 {
+			return w
+		}
+		w.Commands = slices.Clone(w.Commands)
+		v := operand(r, &w.Commands[r.Intn(len(w.Commands))])
+		delta := 1 + r.Intn(maxDelta)
+		if r.Intn(2) == 1 {
+			delta = -delta
+		}
+		*v = (*v + delta) % cfg.CoreSize
+		return w
+	}
+}
+
+// RandomizeOperand sets the A or B operand of a random instruction to a
+// uniform value in (-CoreSize, CoreSize).
+func RandomizeOperand(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) == 0 {
+		return w
+	}
+	w.Commands = slices.Clone(w.Commands)
+	v := operand(r, &w.Commands[r.Intn(len(w.Commands))])
+	*v = r.Intn(2*cfg.CoreSize-1) - (cfg.CoreSize - 1)
+	return w
+}
+
+// Insert inserts a random instruction at a random position, including the
+// end, unless the warrior already has MaxLength instructions.
+//
+// Operands and the END offset that refer to an instruction of the warrior
+// are adjusted to keep referring to it. The new instruction's operands are
+// mostly small offsets so it interacts with the surrounding code.
+func Insert(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) >= MaxLength(cfg) {
+		return w
+	}
+	return insertAt(w, r.Intn(len(w.Commands)+1), randomCommand(r, len(w.Commands)+1, cfg.CoreSize), cfg.CoreSize)
+}
+
+// Duplicate inserts a copy of a random instruction right after it, unless
+// the warrior already has MaxLength instructions. References are adjusted as
+// in Insert; the copy keeps the original's operands.
+func Duplicate(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	if len(w.Commands) == 0 || len(w.Commands) >= MaxLength(cfg) {
+		return w
+	}
+	i := r.Intn(len(w.Commands))
+	return insertAt(w, i+1, w.Commands[i], cfg.CoreSize)
+}
+
+// Delete removes a random instruction, unless it is the only one.
+//
+// Operands and the END offset that refer to an instruction of the warrior
+// are adjusted to keep referring to it; references to the removed
+// instruction move to the one that takes its place.
+func Delete(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	n := len(w.Commands)
+	if n <= 1 {
+		return w
+	}
+	p := r.Intn(n)
+	newPos := func(t int) int {
+		switch {
+		case t > p:
+			return t - 1
+		case t == p && p == n-1:
+			// The last instruction has no successor, so refer back.
+			return t - 1
+		default:
+			return t
+		}
+	}
+
+	cmds := make([]goexmars.Command, 0, n-1)
+	for i, cmd := range w.Commands {
+		if i == p {
+			continue
+		}
+		relocate(&cmd, i, newPos(i), n, cfg.CoreSize, newPos)
+		cmds = append(cmds, cmd)
+	}
+	if w.End >= 0 && w.End < n {
+		w.End = newPos(w.End)
+	}
+	w.Commands = cmds
+	return w
+}
+
+// ShiftEnd moves the END offset by one instruction forwards or backwards,
+// staying within the code.
+func ShiftEnd(r *rand.Rand, w goexmars.ParsedWarrior, cfg goexmars.FightConfig) goexmars.ParsedWarrior {
+	n := len(w.Commands)
+	if n <= 1 {
+		return w
+	}
+	switch {
+	case w.End <= 0:
+		w.End = 1
+	case w.End >= n-1:
+		w.End = n - 2
+	case r.Intn(2) == 0:
+		w.End--
+	default:
+		w.End++
+	}
+	return w
+}
+
+// insertAt inserts cmd before position p.
+func insertAt(w goexmars.ParsedWarrior, p int, cmd goexmars.Command, coreSize int) goexmars.ParsedWarrior {
+	n := len(w.Commands)
+	newPos := func(t int) int {
+		if t >= p {
+			return t + 1
+		}
+		return t
+	}
+
+	cmds := make([]goexmars.Command, 0, n+1)
+	for i, c := range w.Commands {
+		if i == p {
+			cmds = append(cmds, cmd)
+		}
+		relocate(&c, i, newPos(i), n, coreSize, newPos)
+		cmds = append(cmds, c)
+	}
+	if p == n {
+		cmds = append(cmds, cmd)
+	}
+	if w.End >= 0 && w.End < n {
+		w.End = newPos(w.End)
+	}
+	w.Commands = cmds
+	return w
+}
+
+// relocate adjusts the operands of cmd, which moves from index from to to,
+// so those referring to an instruction in [0, n) keep referring to it at
+// its new position newPos(target). Operands are offsets modulo coreSize, so
+// 7999 refers to the previous instruction in a core of 8000.
+func relocate(cmd *goexmars.Command, from, to, n, coreSize int, newPos func(int) int) {
+	fix := func(mode goexmars.AddressingMode, v *int) {
+		if mode == goexmars.AddressingImmediate {
+			return
+		}
+		if t := from + goexmars.SignedOffset(*v, coreSize); t >= 0 && t < n {
+			*v = newPos(t) - to
+		}
+	}
+	fix(cmd.AddressingModeA, &cmd.A)
+	fix(cmd.AddressingModeB, &cmd.B)
+}
+
+// randomCommand returns a random instruction for a warrior of n
+// instructions. Every opcode, modifier and addressing mode is equally
+// likely; operands are within ±n three times out of four, otherwise uniform
+// in (-coreSize, coreSize).
+func randomCommand(r *rand.Rand, n, coreSize int) goexmars.Command {
+	field := func() int {
+		if r.Intn(4) == 0 {
+			return r.Intn(2*coreSize-1) - (coreSize - 1)
+		}
+		return (r.Intn(2*n+1) - n) % coreSize
+	}
+	return goexmars.Command{
+		OpCode:          goexmars.OpCode(r.Intn(goexmars.OpCodeCount)),
+		Modifier:        goexmars.Modifier(r.Intn(goexmars.ModifierCount)),
+		AddressingModeA: goexmars.AddressingMode(r.Intn(goexmars.AddressingModeCount)),
+		A:               field(),
+		AddressingModeB: goexmars.AddressingMode(r.Intn(goexmars.AddressingModeCount)),
+		B:               field(),
+	}
+}
+
+// operand returns the A or B operand of cmd, each with probability 1/2.
+func operand(r *rand.Rand, cmd *goexmars.Command) *int {
+	if r.Intn(2) == 0 {
+		return &cmd.A
+	}
+	return &cmd.B
+}
+
+// other returns a value in [0, count) different from v.
+func other(r *rand.Rand, v, count int) int {
+	x := r.Intn(count - 1)
+	if x >= v {
+		x++
+	}
+	return x
+}
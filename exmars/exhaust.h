@@ -166,6 +166,23 @@ typedef struct err_st {
     uShrt   code, loc, num;
 }       err_st;
 
+/* structured diagnostic emitted by errprn */
+typedef struct diagrec_st {
+    int     severity;               /* 0 = warning, 1 = error */
+    int     warrior;                /* index of the warrior being assembled */
+    int     line;                   /* physical source line, 0 if none */
+    int     column;                 /* column of the offending token, 0 if unknown */
+    int     code;                   /* errType */
+    char    message[MAXALLCHAR];
+}       diagrec_st;
+
+/* executed instruction recorded by sim_proper while tracing */
+typedef struct tracerec_st {
+    u32_t   warrior;                /* execution slot of the warrior */
+    u32_t   address;                /* core address of the instruction */
+    insn_t  insn;                   /* the instruction before it executed */
+}       tracerec_st;
+
 /* Memory structure */
 typedef struct mem_struct {
     ADDR_T  A_value, B_value;
@@ -253,6 +270,16 @@ typedef struct mars_st {
     char *diagbuf;
     u32_t diaglen;
     u32_t diagcap;
+    diagrec_st *diagrecs;
+    u32_t diagreclen;
+    u32_t diagreccap;
+    int diagwarrior;
+
+    /* trace of the current round, recorded while tracing is set */
+    int tracing;
+    tracerec_st *tracerecs;
+    u32_t tracelen;         /* number of executed instructions */
+    u32_t tracecap;
 
     /* Some parameters */
     int taskNum;
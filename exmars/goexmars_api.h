@@ -5,6 +5,22 @@
 extern "C" {
 #endif
 
+/*
+ * GOEXMARS_ABI_VERSION changes whenever a struct layout or function signature
+ * below changes. The Go bindings refuse to load a library reporting another
+ * version.
+ */
+#define GOEXMARS_ABI_VERSION 2
+
+/* capability bits reported by goexmars_capabilities */
+#define GOEXMARS_CAP_DIAGNOSTICS    (1u << 0) /* structured diagnostics via goexmars_diag_list_t */
+#define GOEXMARS_CAP_FIGHT_INSNS    (1u << 1) /* fight_insns */
+#define GOEXMARS_CAP_TRACE          (1u << 2) /* trace_insns */
+#define GOEXMARS_CAP_FIXED_POSITION (1u << 3) /* cfg.fixpos seeds the positions */
+#define GOEXMARS_CAP_RW_LIMITS      (1u << 4) /* read/write limits, not implemented by exmars */
+#define GOEXMARS_CAP_EVAL_EXPR      (1u << 5) /* eval_expr_1 */
+#define GOEXMARS_CAP_DIAGNOSE       (1u << 6) /* diagnose_warriors, diagnose_insns */
+
 typedef struct goexmars_fight_cfg_st {
 	int coresize;
 	int cycles;
@@ -16,13 +32,100 @@ typedef struct goexmars_fight_cfg_st {
 	int fixpos;
 } goexmars_fight_cfg_t;
 
-void fight_1(char* w1, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-void fight_2(char* w1, char* w2, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-void fight_3(char* w1, char* w2, char* w3, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-void fight_4(char* w1, char* w2, char* w3, char* w4, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-void fight_5(char* w1, char* w2, char* w3, char* w4, char* w5, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-void fight_6(char* w1, char* w2, char* w3, char* w4, char* w5, char* w6, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen);
-int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, int* outLen, char* diagBuf, int diagCap, int* diagLen);
+typedef struct goexmars_diag_st {
+	int severity;
+	int warrior;
+	int line;
+	int column;
+	int code;
+	char message[256];
+} goexmars_diag_t;
+
+typedef struct goexmars_diag_list_st {
+	goexmars_diag_t* items;
+	int cap;
+	int len;
+} goexmars_diag_list_t;
+
+/*
+ * goexmars_insn_t is one instruction for fight_insns. opcode, modifier and the
+ * addressing modes use the goexmars Go enum order (OpCode, Modifier,
+ * AddressingMode); a and b are taken modulo the core size.
+ */
+typedef struct goexmars_insn_st {
+	int opcode;
+	int modifier;
+	int amode;
+	int a;
+	int bmode;
+	int b;
+} goexmars_insn_t;
+
+/*
+ * goexmars_trace_step_t is one instruction executed by trace_insns. warrior is
+ * the index of the executing warrior in the input order, address its absolute
+ * core address and insn the instruction before it executed, in the same
+ * encoding as goexmars_insn_t (CMP is reported as SEQ).
+ */
+typedef struct goexmars_trace_step_st {
+	int warrior;
+	int address;
+	goexmars_insn_t insn;
+} goexmars_trace_step_t;
+
+/*
+ * goexmars_trace_t receives the steps of trace_insns. steps is allocated by
+ * the library and sized to the trace; release it with trace_free.
+ */
+typedef struct goexmars_trace_st {
+	goexmars_trace_step_t* steps;
+	int len;
+} goexmars_trace_t;
+
+int goexmars_abi_version(void);
+unsigned int goexmars_capabilities(void);
+
+/*
+ * Output buffers are filled up to cap-1 bytes and NUL-terminated. The *Len
+ * out parameters and diagList->len always receive the full size, so callers
+ * can detect truncation and retry with larger buffers.
+ */
+void fight_1(char* w1, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_2(char* w1, char* w2, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_3(char* w1, char* w2, char* w3, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_4(char* w1, char* w2, char* w3, char* w4, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_5(char* w1, char* w2, char* w3, char* w4, char* w5, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_6(char* w1, char* w2, char* w3, char* w4, char* w5, char* w6, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void fight_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+/*
+ * diagnose_warriors and diagnose_insns load the warriors of a fight_N or
+ * fight_insns call without running the fight and report the same
+ * diagnostics, e.g. to collect them again with larger buffers. ws holds the
+ * nWarriors (1 to 6) NUL-terminated sources back to back. Both return 0 if
+ * the warriors load and -1 otherwise.
+ */
+int diagnose_warriors(char* ws, int nWarriors, goexmars_fight_cfg_t* cfg, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+int diagnose_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+/*
+ * trace_insns runs the rounds of a fight_insns fight up to and including the
+ * 0-based round and records every instruction executed in that round into
+ * trace. Returns 0 on success and -1 if the warriors could not be loaded or
+ * the trace could not be allocated. trace_free releases the steps.
+ */
+int trace_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, int round, goexmars_trace_t* trace, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+void trace_free(goexmars_trace_t* trace);
+/*
+ * eval_expr_1 evaluates expr like an ;assert line of a single warrior
+ * assembled with cfg: the predefined constants are substituted and the
+ * result is computed by pMARS eval_expr. defineNames holds nDefines
+ * NUL-terminated names back to back, defineValues their values; they are
+ * substituted like EQU constants and shadow the predefined names. Returns 0
+ * on success, 1 if the value overflowed (a warning, *result holds the wrapped
+ * value), -1 for a bad expression and -2 for a division by zero. The problem
+ * is also reported as a diagnostic.
+ */
+int eval_expr_1(char* expr, goexmars_fight_cfg_t* cfg, char* defineNames, long long* defineValues, int nDefines, long long* result, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
+int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, int* outLen, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList);
 
 #ifdef __cplusplus
 }
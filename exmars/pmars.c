@@ -253,8 +253,8 @@ char	 *tooManyMsgErr = "\nToo many errors or warnings.\nProgram aborted.\n";
 char	 *fileOpenErr = "Unable to open file '%s'";
 char	 *fileReadErr = "Unable to read file '%s'";
 char	 *notEnoughMemErr = "MALLOC() fails\nProgram aborted\n";
-char	 *warning = "Warning";
-char	 *error = "Error";
+static char *warning = "Warning";
+static char *error = "Error";
 char	 *inLine = " in line %d: '%s'\n";
 char	 *opcodeMsg = "opcode";
 char	 *modifierMsg = "modifier";
@@ -340,6 +340,39 @@ enum ex_addr_mode p2eAddr[] = {
 		EX_APOSTINC /* } */	 /* 8 */
 };
 
+/* goexmars_insn_t fields use the Go OpCode/Modifier/AddressingMode order */
+#define G_OPNUM 19
+#define G_MODNUM 7
+#define G_ADDRNUM 8
+
+enum ex_op g2eOp[] = {
+		EX_DAT, EX_MOV, EX_ADD, EX_SUB, EX_MUL, EX_DIV, EX_MODM,
+		EX_JMP, EX_JMZ, EX_JMN, EX_DJN, EX_SPL, EX_SEQ, EX_SEQ, /* CMP = SEQ */
+		EX_SNE, EX_SLT, EX_LDP, EX_STP, EX_NOP
+};
+
+/* inverse of g2eOp and g2eAddr for reporting exhaust instructions */
+int e2gOp[] = {
+		0 /* DAT */, 11 /* SPL */, 1 /* MOV */, 10 /* DJN */, 2 /* ADD */, 8 /* JMZ */,
+		3 /* SUB */, 13 /* SEQ */, 14 /* SNE */, 15 /* SLT */, 9 /* JMN */, 7 /* JMP */,
+		18 /* NOP */, 4 /* MUL */, 6 /* MOD */, 5 /* DIV */, 16 /* LDP */, 17 /* STP */
+};
+
+int e2gAddr[] = {
+		1 /* $ */, 0 /* # */, 3 /* @ */, 5 /* < */, 7 /* > */, 2 /* * */, 4 /* { */, 6 /* } */
+};
+
+enum ex_addr_mode g2eAddr[] = {
+		EX_IMMEDIATE, /* # */
+		EX_DIRECT,	/* $ */
+		EX_AINDIRECT,	 /* * */
+		EX_BINDIRECT,	 /* @ */
+		EX_APREDEC, /* { */
+		EX_BPREDEC, /* < */
+		EX_APOSTINC, /* } */
+		EX_BPOSTINC		/* > */
+};
+
 /*--------------------*/
 long calc(mars_t* mars, long x, long y, int op)
 {
@@ -866,14 +899,14 @@ addsym(mars_t* mars, char* symn, grp_st* curgroup)
 
 /* ******************************************************************* */
 
+/* Register symn as a text constant holding mars->token. */
 static void
-addpredef(mars_t* mars, char* symn, U32_T value)
+addtokendef(mars_t* mars, char* symn)
 {
 	grp_st *lsymtbl = NULL;
 	line_st *aline;
 
 	lsymtbl = addsym(mars, symn, lsymtbl);
-	sprintf(mars->token, "%lu", (unsigned long) value);
 	newtbl(mars);
 	mars->reftbl->grpsym = lsymtbl;
 	mars->reftbl->reftype = RTEXT;
@@ -885,6 +918,13 @@ addpredef(mars_t* mars, char* symn, U32_T value)
 		MEMORYERROR;
 }
 
+static void
+addpredef(mars_t* mars, char* symn, U32_T value)
+{
+	sprintf(mars->token, "%lu", (unsigned long) value);
+	addtokendef(mars, symn);
+}
+
 /* ******************************************************************* */
 
 static void
@@ -1070,6 +1110,7 @@ static void mars_diag_reset(mars_t* mars)
 	mars->diaglen = 0;
 	if (mars->diagbuf)
 		mars->diagbuf[0] = '\0';
+	mars->diagreclen = 0;
 }
 
 static void mars_diag_append(mars_t* mars, const char* str)
@@ -1102,6 +1143,89 @@ static void mars_diag_append(mars_t* mars, const char* str)
 	mars->diagbuf[mars->diaglen] = '\0';
 }
 
+static int diag_word_char(char c)
+{
+	return isalnum((unsigned char) c) || c == '_';
+}
+
+/* 1-based column of arg in src, matched as a whole token. 0 if arg does not
+   occur or occurs more than once, as the diagnostic does not say which. */
+static int diag_column(const char* src, const char* arg)
+{
+	size_t n = strlen(arg);
+	const char *at;
+	int column = 0;
+
+	for (at = strstr(src, arg); at; at = strstr(at + 1, arg)) {
+		if (at > src && diag_word_char(arg[0]) && diag_word_char(at[-1]))
+			continue;
+		if (diag_word_char(arg[n - 1]) && diag_word_char(at[n]))
+			continue;
+		if (column)
+			return 0;
+		column = (int)(at - src) + 1;
+	}
+	return column;
+}
+
+static void mars_diag_record(mars_t* mars, errType code, line_st* aline, const char* arg, const char* msg)
+{
+	diagrec_st *rec;
+	u32_t newcap;
+
+	if (mars == NULL)
+		return;
+
+	if (mars->diagreclen >= mars->diagreccap) {
+		newcap = mars->diagreccap ? mars->diagreccap * 2 : 16;
+		rec = (diagrec_st*)realloc(mars->diagrecs, sizeof(diagrec_st) * newcap);
+		if (rec == NULL)
+			return;
+		mars->diagrecs = rec;
+		mars->diagreccap = newcap;
+	}
+
+	rec = mars->diagrecs + mars->diagreclen++;
+	rec->severity = mars->errorlevel == WARNING ? 0 : 1;
+	rec->warrior = mars->diagwarrior;
+	rec->line = 0;
+	rec->column = 0;
+	rec->code = (int)code;
+	strncpy(rec->message, msg, MAXALLCHAR - 1);
+	rec->message[MAXALLCHAR - 1] = '\0';
+
+	if (aline && aline->linesrc) {
+		rec->line = aline->linesrc->loc;
+		if (arg && *arg && aline->linesrc->src)
+			rec->column = diag_column(aline->linesrc->src, arg);
+	}
+}
+
+static void mars_diag_records_copy_out(mars_t* mars, goexmars_diag_list_t* list)
+{
+	int n = 0;
+	int i;
+
+	if (list == NULL)
+		return;
+	if (mars != NULL)
+		n = (int)mars->diagreclen;
+	list->len = n;
+
+	if (list->items == NULL || list->cap <= 0)
+		return;
+	for (i = 0; i < n && i < list->cap; ++i) {
+		diagrec_st *rec = mars->diagrecs + i;
+		goexmars_diag_t *out = list->items + i;
+		out->severity = rec->severity;
+		out->warrior = rec->warrior;
+		out->line = rec->line;
+		out->column = rec->column;
+		out->code = rec->code;
+		memcpy(out->message, rec->message, sizeof(out->message));
+	}
+}
+
 static void mars_diag_copy_out(mars_t* mars, char* buf, int cap, int* outLen)
 {
 	int n = 0;
@@ -1293,6 +1417,7 @@ errprn(mars_t* mars, errType code, line_st* aline, const char* arg)
 			sprintf(mars->outs, "				%s\n", abuf);
 			mars_diag_append(mars, mars->outs);
 			textout(mars->outs);
+			mars_diag_record(mars, code, aline, arg, abuf);
 			mars->errkeep[mars->ierr].num = 1;
 			mars->errkeep[mars->ierr].loc = aline->linesrc->loc;
 			mars->errkeep[mars->ierr++].code = code;
@@ -1307,6 +1432,7 @@ errprn(mars_t* mars, errType code, line_st* aline, const char* arg)
 		sprintf(mars->outs, "				%s\n", abuf);
 		mars_diag_append(mars, mars->outs);
 		textout(mars->outs);
+		mars_diag_record(mars, code, (line_st *) NULL, arg, abuf);
 	}
 
 	if (mars->ierr >= ERRMAX) {
@@ -3124,7 +3250,7 @@ void readargsN(char** warriors, int nWarriors, mars_t* mars) {
 			currWarrior->next = (warriorNames_t*)malloc(sizeof(warriorNames_t));
 			currWarrior = currWarrior->next;
 		}
-		currWarrior->warriorName = warriors[i];
+		currWarrior->warriorName = warriors != NULL ? warriors[i] : NULL;
 		currWarrior->next = NULL;
 	}
 }
@@ -3270,50 +3396,13 @@ static void free_fight_warriors(mars_t* mars, warrior_struct** warriors)
 	FREE(warriors);
 }
 
-static void fight_warriors_detailed_common(mars_t* mars, int *wins, int winsLen, int *ties, char* diagBuf, int diagCap, int* diagLen)
+/* traceRound is the round recorded into mars->tracerecs, or -1 */
+static void run_fight_rounds(mars_t* mars, int *wins, int winsLen, int *ties, int traceRound)
 {
 	u32_t i, seed;
-	warriorNames_t* currWarrior;
-	warrior_struct** warriors;
 	int j;
 	int totalWins = 0;
 
-	currWarrior = mars->warriorNames;
-	warriors = (warrior_struct**)malloc(sizeof(warrior_struct*)*mars->nWarriors);
-	if (warriors == NULL) {
-		if (winsLen > 0) {
-			for (j = 0; j < winsLen; ++j) wins[j] = -1;
-		}
-		if (ties) *ties = -1;
-		mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
-		sim_free_bufs(mars);
-		return;
-	}
-	memset(warriors, 0, sizeof(warrior_struct*)*mars->nWarriors);
-
-	i = 0;
-	while (currWarrior != NULL)
-	{
-		warrior_struct* w = (warrior_struct*)MALLOC(sizeof(warrior_struct));
-		warriors[i] = w;
-
-		memset(w, 0, sizeof(warrior_struct));
-		if (assemble_warrior2(mars, currWarrior->warriorName, w)) {
-			if (winsLen > 0) {
-				for (j = 0; j < winsLen; ++j) wins[j] = -1;
-			}
-			if (ties) *ties = -1;
-			mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
-			free_fight_warriors(mars, warriors);
-			sim_free_bufs(mars);
-			return;
-		}
-		currWarrior = currWarrior->next;
-		++i;
-	}
-
-	pmars2exhaust(mars, warriors, mars->nWarriors);
-
 	check_sanity(mars);
 	clear_results(mars);
 
@@ -3334,6 +3423,7 @@ static void fight_warriors_detailed_common(mars_t* mars, int *wins, int winsLen,
 		load_warriors(mars);
 		set_starting_order(i, mars);
 
+		mars->tracing = (int)i == traceRound;
 		nalive = sim_mw(mars, mars->startPositions, mars->deaths);
 		if (nalive<0)
 			panic("simulator panic!\n");
@@ -3351,19 +3441,247 @@ static void fight_warriors_detailed_common(mars_t* mars, int *wins, int winsLen,
 		}
 	}
 	if (ties) *ties = (int)mars->rounds - totalWins;
+}
+
+/* assemble the warriors named in mars->warriorNames into warriors, stopping at
+   the first one that fails; returns 0 on success and -1 on failure */
+static int assemble_fight_warriors(mars_t* mars, warrior_struct** warriors)
+{
+	warriorNames_t* currWarrior;
+	u32_t i = 0;
+
+	for (currWarrior = mars->warriorNames; currWarrior != NULL; currWarrior = currWarrior->next, ++i) {
+		warrior_struct* w = (warrior_struct*)MALLOC(sizeof(warrior_struct));
+		warriors[i] = w;
+
+		memset(w, 0, sizeof(warrior_struct));
+		mars->diagwarrior = (int)i;
+		if (assemble_warrior2(mars, currWarrior->warriorName, w))
+			return -1;
+	}
+	return 0;
+}
+
+static void fight_warriors_detailed_common(mars_t* mars, int *wins, int winsLen, int *ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	warrior_struct** warriors;
+	int j;
+
+	warriors = (warrior_struct**)malloc(sizeof(warrior_struct*)*mars->nWarriors);
+	if (warriors == NULL) {
+		if (winsLen > 0) {
+			for (j = 0; j < winsLen; ++j) wins[j] = -1;
+		}
+		if (ties) *ties = -1;
+		mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+		mars_diag_records_copy_out(mars, diagList);
+		sim_free_bufs(mars);
+		return;
+	}
+	memset(warriors, 0, sizeof(warrior_struct*)*mars->nWarriors);
+
+	if (assemble_fight_warriors(mars, warriors)) {
+		if (winsLen > 0) {
+			for (j = 0; j < winsLen; ++j) wins[j] = -1;
+		}
+		if (ties) *ties = -1;
+		mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+		mars_diag_records_copy_out(mars, diagList);
+		free_fight_warriors(mars, warriors);
+		sim_free_bufs(mars);
+		return;
+	}
+
+	pmars2exhaust(mars, warriors, mars->nWarriors);
+	run_fight_rounds(mars, wins, winsLen, ties, -1);
 	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
 
 	free_fight_warriors(mars, warriors);
 	sim_free_bufs(mars);
 }
 
-static void fight_n_warriors_detailed(char** ws, int nWarriors, int coresize, int cycles, int maxprocess, int rounds, int maxwarriorlen, int minsep, int pspacesize, int fixpos, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+static void fight_n_warriors_detailed(char** ws, int nWarriors, int coresize, int cycles, int maxprocess, int rounds, int maxwarriorlen, int minsep, int pspacesize, int fixpos, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	mars_t* mars = initN(ws, nWarriors, coresize, cycles, maxprocess, rounds, maxwarriorlen, minsep, pspacesize);
 	if (fixpos != -1) {
 		mars->fixedPosition = fixpos;
 	}
-	fight_warriors_detailed_common(mars, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_warriors_detailed_common(mars, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
+}
+
+/* load goexmars_insn_t records into exhaust's format, like pmars2exhaust */
+static int insns2exhaust(mars_t* mars, const goexmars_insn_t* insns, const int* lens, const int* starts)
+{
+	u32_t w;
+	int i;
+
+	for (w = 0; w < mars->nWarriors; ++w) {
+		warrior_t* warrior = &(mars->warriors[w]);
+		insn_t* in = warrior->code;
+		int len = lens[w];
+
+		mars->diagwarrior = (int)w;
+		if (len <= 0 || (u32_t)len > mars->maxWarriorLength || (mars->minsep && (u32_t)len > mars->minsep)) {
+			sprintf(mars->buf, "Invalid warrior length %d", len);
+			errprn(mars, MISC, (line_st *) NULL, mars->buf);
+			return -1;
+		}
+
+		warrior->start = MODS(starts[w], (int)mars->coresize);
+		warrior->len = len;
+		warrior->have_pin = 0;
+
+		for (i = 0; i < len; ++i, ++insns, ++in) {
+			if (insns->opcode < 0 || insns->opcode >= G_OPNUM
+			    || insns->modifier < 0 || insns->modifier >= G_MODNUM
+			    || insns->amode < 0 || insns->amode >= G_ADDRNUM
+			    || insns->bmode < 0 || insns->bmode >= G_ADDRNUM) {
+				sprintf(mars->buf, "Invalid instruction encoding at offset %d", i);
+				errprn(mars, MISC, (line_st *) NULL, mars->buf);
+				return -1;
+			}
+			in->a = MODS(insns->a, (int)mars->coresize);
+			in->b = MODS(insns->b, (int)mars->coresize);
+			in->in = OP(g2eOp[insns->opcode], insns->modifier, g2eAddr[insns->amode], g2eAddr[insns->bmode]);
+		}
+	}
+	return 0;
+}
+
+void fight_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	mars_t* mars;
+	int j;
+
+	mars = initN(NULL, nWarriors, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize);
+	if (cfg->fixpos != -1)
+		mars->fixedPosition = cfg->fixpos;
+
+	if (insns2exhaust(mars, insns, lens, starts)) {
+		for (j = 0; j < winsLen; ++j) wins[j] = -1;
+		if (ties) *ties = -1;
+	} else {
+		run_fight_rounds(mars, wins, winsLen, ties, -1);
+	}
+
+	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
+	sim_free_bufs(mars);
+}
+
+int diagnose_warriors(char* ws, int nWarriors, goexmars_fight_cfg_t* cfg, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	char* srcs[6];
+	mars_t* mars;
+	warrior_struct** warriors;
+	int i;
+	int rc;
+
+	if (nWarriors < 1 || nWarriors > 6)
+		return -1;
+	for (i = 0; i < nWarriors; ++i) {
+		srcs[i] = ws;
+		ws += strlen(ws) + 1;
+	}
+
+	mars = initN(srcs, nWarriors, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize);
+	warriors = (warrior_struct**)malloc(sizeof(warrior_struct*)*mars->nWarriors);
+	if (warriors == NULL) {
+		sim_free_bufs(mars);
+		return -1;
+	}
+	memset(warriors, 0, sizeof(warrior_struct*)*mars->nWarriors);
+
+	rc = assemble_fight_warriors(mars, warriors);
+	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
+	free_fight_warriors(mars, warriors);
+	sim_free_bufs(mars);
+	return rc;
+}
+
+int diagnose_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	mars_t* mars;
+	int rc;
+
+	mars = initN(NULL, nWarriors, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize);
+	rc = insns2exhaust(mars, insns, lens, starts);
+	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
+	sim_free_bufs(mars);
+	return rc;
+}
+
+int trace_insns(goexmars_insn_t* insns, int* lens, int* starts, int nWarriors, goexmars_fight_cfg_t* cfg, int round, goexmars_trace_t* trace, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	mars_t* mars;
+	int wins[6];
+	int ties;
+	int ret = 0;
+	u32_t i;
+
+	trace->steps = NULL;
+	trace->len = 0;
+	mars = initN(NULL, nWarriors, cfg->coresize, cfg->cycles, cfg->maxprocess, round+1, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize);
+	if (cfg->fixpos != -1)
+		mars->fixedPosition = cfg->fixpos;
+
+	if (insns2exhaust(mars, insns, lens, starts)) {
+		ret = -1;
+	}
+	if (ret == 0) {
+		/* the trace buffer grows while the round runs, see trace_grow */
+		run_fight_rounds(mars, wins, nWarriors, &ties, round);
+
+		if (mars->tracelen > mars->tracecap
+		    || (mars->tracelen > 0 && (trace->steps = (goexmars_trace_step_t*)malloc(sizeof(goexmars_trace_step_t)*mars->tracelen)) == NULL)) {
+			errprn(mars, MISC, (line_st *) NULL, "Could not allocate trace buffer");
+			ret = -1;
+		}
+	}
+	if (ret == 0) {
+		/* execution slot s holds warrior (s+round) % n, see set_starting_order */
+		for (i = 0; i < mars->tracelen; ++i) {
+			const tracerec_st* r = &(mars->tracerecs[i]);
+			goexmars_trace_step_t* st = &(trace->steps[i]);
+			u32_t in = r->insn.in;
+
+			st->warrior = (int)((r->warrior + (u32_t)round) % mars->nWarriors);
+			st->address = (int)r->address;
+			st->insn.opcode = e2gOp[(in >> opPOS) & opMASK];
+			st->insn.modifier = (int)((in >> moPOS) & moMASK);
+			st->insn.amode = e2gAddr[in & mMASK];
+			st->insn.a = (int)r->insn.a;
+			st->insn.bmode = e2gAddr[(in >> mbPOS) & mMASK];
+			st->insn.b = (int)r->insn.b;
+		}
+		trace->len = (int)mars->tracelen;
+	}
+
+	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
+	free(mars->tracerecs);
+	sim_free_bufs(mars);
+	return ret;
+}
+
+void trace_free(goexmars_trace_t* trace)
+{
+	free(trace->steps);
+	trace->steps = NULL;
+	trace->len = 0;
+}
+
+int goexmars_abi_version(void)
+{
+	return GOEXMARS_ABI_VERSION;
+}
+
+unsigned int goexmars_capabilities(void)
+{
+	return GOEXMARS_CAP_DIAGNOSTICS | GOEXMARS_CAP_FIGHT_INSNS | GOEXMARS_CAP_TRACE | GOEXMARS_CAP_FIXED_POSITION | GOEXMARS_CAP_EVAL_EXPR | GOEXMARS_CAP_DIAGNOSE;
 }
 
 static void append_text_buf(char* dst, int cap, int* ioLen, const char* src)
@@ -3390,7 +3708,7 @@ static void append_text_buf(char* dst, int cap, int* ioLen, const char* src)
 	*ioLen = len + n;
 }
 
-int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, int* outLen, char* diagBuf, int diagCap, int* diagLen)
+int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, int* outLen, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[1] = { w1 };
 	mars_t* mars;
@@ -3412,6 +3730,7 @@ int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, in
 	warriors = (warrior_struct**)malloc(sizeof(warrior_struct*));
 	if (warriors == NULL) {
 		mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+		mars_diag_records_copy_out(mars, diagList);
 		sim_free_bufs(mars);
 		return -1;
 	}
@@ -3423,6 +3742,7 @@ int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, in
 
 	if (assemble_warrior2(mars, w1, w)) {
 		mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+		mars_diag_records_copy_out(mars, diagList);
 		free_fight_warriors(mars, warriors);
 		sim_free_bufs(mars);
 		return -1;
@@ -3442,6 +3762,7 @@ int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, in
 		*outLen = textLen;
 
 	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
 	rc = 0;
 
 	free_fight_warriors(mars, warriors);
@@ -3449,38 +3770,98 @@ int assemble_1(char* w1, goexmars_fight_cfg_t* cfg, char* outBuf, int outCap, in
 	return rc;
 }
 
-void fight_1(char* w1, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+int eval_expr_1(char* expr, goexmars_fight_cfg_t* cfg, char* defineNames, long long* defineValues, int nDefines, long long* result, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
+{
+	mars_t* mars;
+	line_st aline;
+	char dest[MAXALLCHAR];
+	char* name;
+	long value = 0L;
+	int rc = BAD_EXPR;
+	int i;
+
+	mars = initN(NULL, 1, cfg->coresize, cfg->cycles, cfg->maxprocess, 1, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize);
+	if ((mars->errkeep = (err_st *) MALLOC(sizeof(err_st) * ERRMAX)) == NULL)
+		MEMORYERROR;
+
+	if (strlen(expr) >= MAXALLCHAR) {
+		errprn(mars, BUFERR, (line_st *) NULL, "");
+	} else {
+		/* substitute the predefined constants like an ;assert line */
+		addpredefs(mars);
+		/* defines are added last so that they shadow predefined names */
+		for (i = 0, name = defineNames; i < nDefines; i++, name += strlen(name) + 1) {
+			sprintf(mars->token, "%lld", defineValues[i]);
+			addtokendef(mars, name);
+		}
+		memset(&aline, 0, sizeof(aline));
+		aline.vline = expr;
+		aline.linesrc = addlinesrc(mars, expr, 1);
+		mars->aline = &aline;
+
+		*dest = '\0';
+		trav2(mars, expr, dest, SVAL);
+		if (mars->errnum == 0) {
+			rc = eval_expr(mars, dest, &value);
+			if (rc == DIV_ZERO)
+				errprn(mars, DIVERR, &aline, "");
+			else if (rc < OK_EXPR)
+				errprn(mars, EVLERR, &aline, "");
+			else if (rc == PMARS_OVERFLOW)
+				errprn(mars, OFLERR, &aline, "");
+		} else
+			rc = BAD_EXPR;
+
+		mars->aline = NULL;
+		cleanmem(mars);
+		while (mars->srctbl) {
+			src_st *tmp = mars->srctbl;
+			mars->srctbl = mars->srctbl->nextsrc;
+			FREE(tmp->src);
+			FREE(tmp);
+		}
+	}
+	if (result != NULL)
+		*result = (long long)value;
+
+	mars_diag_copy_out(mars, diagBuf, diagCap, diagLen);
+	mars_diag_records_copy_out(mars, diagList);
+	sim_free_bufs(mars);
+	return rc;
+}
+
+void fight_1(char* w1, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[1] = { w1 };
-	fight_n_warriors_detailed(ws, 1, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 1, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
 
-void fight_2(char* w1, char* w2, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+void fight_2(char* w1, char* w2, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[2] = { w1, w2 };
-	fight_n_warriors_detailed(ws, 2, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 2, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
 
-void fight_3(char* w1, char* w2, char* w3, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+void fight_3(char* w1, char* w2, char* w3, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[3] = { w1, w2, w3 };
-	fight_n_warriors_detailed(ws, 3, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 3, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
 
-void fight_4(char* w1, char* w2, char* w3, char* w4, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+void fight_4(char* w1, char* w2, char* w3, char* w4, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[4] = { w1, w2, w3, w4 };
-	fight_n_warriors_detailed(ws, 4, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 4, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
 
-void fight_5(char* w1, char* w2, char* w3, char* w4, char* w5, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+void fight_5(char* w1, char* w2, char* w3, char* w4, char* w5, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[5] = { w1, w2, w3, w4, w5 };
-	fight_n_warriors_detailed(ws, 5, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 5, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
 
-void fight_6(char* w1, char* w2, char* w3, char* w4, char* w5, char* w6, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen)
+void fight_6(char* w1, char* w2, char* w3, char* w4, char* w5, char* w6, goexmars_fight_cfg_t* cfg, int* wins, int winsLen, int* ties, char* diagBuf, int diagCap, int* diagLen, goexmars_diag_list_t* diagList)
 {
 	char* ws[6] = { w1, w2, w3, w4, w5, w6 };
-	fight_n_warriors_detailed(ws, 6, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen);
+	fight_n_warriors_detailed(ws, 6, cfg->coresize, cfg->cycles, cfg->maxprocess, cfg->rounds, cfg->maxwarriorlen, cfg->minsep, cfg->pspacesize, cfg->fixpos, wins, winsLen, ties, diagBuf, diagCap, diagLen, diagList);
 }
@@ -51,13 +51,64 @@ typedef struct goexmars_fight_cfg_st {
 	int pspacesize;
 	int fixpos;
 } goexmars_fight_cfg_t;
-void fight_1(char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-void fight_2(char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-void fight_3(char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-void fight_4(char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-void fight_5(char*, char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-void fight_6(char*, char*, char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*);
-int assemble_1(char*, goexmars_fight_cfg_t*, char*, int, int*, char*, int, int*);
+typedef struct goexmars_diag_st {
+	int severity;
+	int warrior;
+	int line;
+	int column;
+	int code;
+	char message[256];
+} goexmars_diag_t;
+
+typedef struct goexmars_diag_list_st {
+	goexmars_diag_t* items;
+	int cap;
+	int len;
+} goexmars_diag_list_t;
+
+typedef struct goexmars_insn_st {
+	int opcode;
+	int modifier;
+	int amode;
+	int a;
+	int bmode;
+	int b;
+} goexmars_insn_t;
+
+typedef struct goexmars_trace_step_st {
+	int warrior;
+	int address;
+	goexmars_insn_t insn;
+} goexmars_trace_step_t;
+
+typedef struct goexmars_trace_st {
+	goexmars_trace_step_t* steps;
+	int len;
+} goexmars_trace_t;
+#define GOEXMARS_ABI_VERSION 2
+#define GOEXMARS_CAP_DIAGNOSTICS    (1u << 0)
+#define GOEXMARS_CAP_FIGHT_INSNS    (1u << 1)
+#define GOEXMARS_CAP_TRACE          (1u << 2)
+#define GOEXMARS_CAP_FIXED_POSITION (1u << 3)
+#define GOEXMARS_CAP_RW_LIMITS      (1u << 4)
+#define GOEXMARS_CAP_EVAL_EXPR      (1u << 5)
+#define GOEXMARS_CAP_DIAGNOSE       (1u << 6)
+
+int goexmars_abi_version(void);
+unsigned int goexmars_capabilities(void);
+void fight_1(char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_2(char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_3(char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_4(char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_5(char*, char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_6(char*, char*, char*, char*, char*, char*, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+void fight_insns(goexmars_insn_t*, int*, int*, int, goexmars_fight_cfg_t*, int*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+int trace_insns(goexmars_insn_t*, int*, int*, int, goexmars_fight_cfg_t*, int, goexmars_trace_t*, char*, int, int*, goexmars_diag_list_t*);
+void trace_free(goexmars_trace_t*);
+int assemble_1(char*, goexmars_fight_cfg_t*, char*, int, int*, char*, int, int*, goexmars_diag_list_t*);
+int diagnose_warriors(char*, int, goexmars_fight_cfg_t*, char*, int, int*, goexmars_diag_list_t*);
+int diagnose_insns(goexmars_insn_t*, int*, int*, int, goexmars_fight_cfg_t*, char*, int, int*, goexmars_diag_list_t*);
+int eval_expr_1(char*, goexmars_fight_cfg_t*, char*, long long*, int, long long*, char*, int, int*, goexmars_diag_list_t*);
 
 /* ****************** required local prototypes ********************* */
 
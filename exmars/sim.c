@@ -96,6 +96,19 @@ sim_clear_core(mars_t* mars)
  *     All file scoped globals.
  */
 
+/* trace_grow doubles the trace buffer. If that fails the buffer is kept and
+   tracelen counts on past tracecap. */
+static void trace_grow(mars_t* mars)
+{
+	u32_t cap = mars->tracecap ? mars->tracecap * 2 : 1024;
+	tracerec_st* recs = (tracerec_st*)realloc(mars->tracerecs, sizeof(tracerec_st) * cap);
+
+	if (recs != NULL) {
+		mars->tracerecs = recs;
+		mars->tracecap = cap;
+	}
+}
+
 void sim_free_bufs(void* m)
 {
 	mars_t* mars = (mars_t*)m;
@@ -116,6 +129,7 @@ void sim_free_bufs(void* m)
 	}
 	free(mars->errkeep);
 	free(mars->diagbuf);
+	free(mars->diagrecs);
 	free(mars->coreMem);
 	free(mars->deaths);
 	free(mars->positions);
@@ -144,7 +158,7 @@ int sim_alloc_bufs(mars_t* mars) {
 
 	mars->positions = (field_t*)malloc(sizeof(field_t)*mars->nWarriors);
 	mars->startPositions = (field_t*)malloc(sizeof(field_t)*mars->nWarriors);
-	mars->deaths = (u32_t*)malloc(sizeof(unsigned int)*mars->nWarriors);
+	mars->deaths = (u32_t*)malloc(sizeof(u32_t)*mars->nWarriors);
 	mars->results = (u32_t*)malloc(sizeof(u32_t)*mars->nWarriors*(mars->nWarriors+1));
 
 	if (mars->pspaceSize <= 0) {
@@ -595,6 +609,7 @@ sim_proper(mars_t* mars, const field_t * const war_pos_tab, u32_t* death_tab )
 	insn_t **pofs = queue_end-1;
 	u32_t pspaceSize = mars->pspaceSize;
 	pspace_t** pspacesOrigin = mars->pspacesOrigin;
+	const int tracing = mars->tracing;
 
 
 #if DEBUG >= 1
@@ -644,6 +659,18 @@ sim_proper(mars_t* mars, const field_t * const war_pos_tab, u32_t* death_tab )
 		insn_t* ip = *(w->head);
 		if ( ++(w->head) == queue_end ) w->head = queue_start;
 		in = ip->in; /* note: flags must be unset! */
+
+		if (tracing) {
+			if (mars->tracelen == mars->tracecap)
+				trace_grow(mars);
+			if (mars->tracelen < mars->tracecap) {
+				tracerec_st* r = &(mars->tracerecs[mars->tracelen]);
+				r->warrior = w->id;
+				r->address = (u32_t)(ip - core);
+				r->insn = *ip;
+			}
+			mars->tracelen++;
+		}
 #if !SIM_STRIP_FLAGS
 		in = in & iMASK; /* strip flags. */
 #endif
@@ -0,0 +1,54 @@
+package goexmars
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+)
+
+// Fallback is a set of pure-Go entry points used in place of the shared
+// library when there is none: loading it failed with an error matching
+// fs.ErrNotExist. A library that exists but cannot be loaded, e.g. a stale
+// build with a different ABI version, is reported as an error instead.
+//
+// Subpackages register themselves from an init function, so a blank import
+// is enough to enable them:
+//
+//	import _ "github.com/BigJk/goexmars/sim"
+type Fallback struct {
+	// Name identifies the implementation in diagnostics.
+	Name        string
+	Fight       func(warriors []string, cfg FightConfig) (FightResult, error)
+	FightParsed func(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error)
+	TraceParsed func(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error)
+	Assemble    func(warrior string, cfg FightConfig) (string, error)
+	// EvalExpression may be nil if the implementation cannot evaluate
+	// expressions.
+	EvalExpression func(expr string, cfg FightConfig, defines map[string]int) (int, error)
+}
+
+var fallback atomic.Pointer[Fallback]
+
+// RegisterFallback installs f as the implementation used when the shared
+// library is not available. Passing nil removes a registered fallback.
+func RegisterFallback(f *Fallback) {
+	fallback.Store(f)
+}
+
+// RegisteredFallback returns the registered fallback or nil.
+func RegisteredFallback() *Fallback {
+	return fallback.Load()
+}
+
+// libraryOrFallback returns the default library, or the registered fallback
+// if the library does not exist. It returns the load error otherwise.
+func libraryOrFallback() (*Library, *Fallback, error) {
+	lib, err := DefaultLibrary()
+	if err == nil {
+		return lib, nil, nil
+	}
+	if f := fallback.Load(); f != nil && errors.Is(err, fs.ErrNotExist) {
+		return nil, f, nil
+	}
+	return nil, nil, err
+}
@@ -0,0 +1,80 @@
+package goexmars
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const fallbackChildEnv = "GOEXMARS_FALLBACK_TEST_CHILD"
+
+// staleLibrarySource is a library that passes the symbol lookup of the
+// handshake but reports another ABI version.
+const staleLibrarySource = `
+int goexmars_abi_version(void) { return 9999; }
+unsigned int goexmars_capabilities(void) { return 0; }
+`
+
+// TestFallbackOnlyForMissingLibrary runs Fight with a registered fallback in
+// a child process, as the default library is loaded once per process.
+func TestFallbackOnlyForMissingLibrary(t *testing.T) {
+	if mode := os.Getenv(fallbackChildEnv); mode != "" {
+		fallbackChild(t, mode)
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("building the stale library needs a Unix C compiler")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler found")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "stale.c")
+	if err := os.WriteFile(src, []byte(staleLibrarySource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, exmarsLibraryName())
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", stale, src).CombinedOutput(); err != nil {
+		t.Skipf("cannot build the stale library: %v\n%s", err, out)
+	}
+
+	for mode, path := range map[string]string{
+		"stale":   stale,
+		"missing": filepath.Join(dir, "missing", exmarsLibraryName()),
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFallbackOnlyForMissingLibrary$")
+		cmd.Env = append(os.Environ(), fallbackChildEnv+"="+mode, exmarsLibraryPathEnv+"="+path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s library: %v\n%s", mode, err, out)
+		}
+	}
+}
+
+func fallbackChild(t *testing.T, mode string) {
+	errFallback := errors.New("fallback used")
+	RegisterFallback(&Fallback{
+		Name: "test",
+		Fight: func(warriors []string, cfg FightConfig) (FightResult, error) {
+			return FightResult{}, errFallback
+		},
+	})
+	defer RegisterFallback(nil)
+
+	_, err := Fight([]string{";redcode-94\nMOV 0, 1\nEND\n"}, DefaultConfig.SetRounds(1))
+	switch mode {
+	case "stale":
+		if err == nil || errors.Is(err, errFallback) || !strings.Contains(err.Error(), "ABI version") {
+			t.Fatalf("expected the ABI error, got %v", err)
+		}
+	case "missing":
+		if !errors.Is(err, errFallback) {
+			t.Fatalf("expected the fallback to be used, got %v", err)
+		}
+	}
+}
@@ -3,11 +3,17 @@ package goexmars
 import (
 	"errors"
 	"fmt"
+	"runtime"
 	"sort"
+	"strings"
 	"unsafe"
 )
 
-const diagnosticsBufferSize = 16 * 1024
+const (
+	diagnosticsBufferSize = 16 * 1024
+	// assembleBufferSize is the initial listing buffer; Assemble grows it on demand.
+	assembleBufferSize = 16 * 1024
+)
 
 type cFightCfg struct {
 	CoreSize      int32
@@ -46,11 +52,13 @@ func diagnosticsString(buf []byte, diagLen int32) string {
 // FightResult contains the outcome of a fight.
 type FightResult struct {
 	// Wins contains the sole-win count for each warrior in the input order.
-	Wins []int
+	Wins []int `json:"wins"`
 	// Ties contains rounds without a sole winner.
-	Ties int
+	Ties int `json:"ties"`
 	// Diagnostics contains exmars warnings/errors captured during assembly/fight setup.
-	Diagnostics string
+	Diagnostics string `json:"diagnostics,omitempty"`
+	// DiagnosticList contains the same warnings/errors in structured form.
+	DiagnosticList []Diagnostic `json:"diagnostic_list,omitempty"`
 }
 
 // FightNamedResult is a FightResult with name-based lookup helpers.
@@ -85,17 +93,27 @@ func (r FightResult) Failed() bool {
 // Validate performs a quick validity check for a single warrior.
 //
 // It runs a single-round self-fight and returns a non-nil error when exmars
-// reports an assembly/setup failure. The returned error message is the captured
-// diagnostics string when available.
+// reports an assembly/setup failure. The returned error is an *AssembleError
+// whose message is the captured diagnostics string when available. Use
+// CheckWarrior to check an already parsed warrior without a fight.
 func Validate(warrior string, cfg FightConfig) error {
+	return validate(warrior, cfg, Fight)
+}
+
+// Validate is the package-level Validate using l.
+func (l *Library) Validate(warrior string, cfg FightConfig) error {
+	return validate(warrior, cfg, l.Fight)
+}
+
+func validate(warrior string, cfg FightConfig, fight func([]string, FightConfig) (FightResult, error)) error {
 	cfg.Rounds = 1
-	result, err := Fight([]string{warrior}, cfg)
+	result, err := fight([]string{warrior}, cfg)
 	if err != nil {
 		return err
 	}
 	if result.Failed() {
-		if result.Diagnostics != "" {
-			return errors.New(result.Diagnostics)
+		if result.Diagnostics != "" || len(result.DiagnosticList) > 0 {
+			return &AssembleError{Diagnostics: result.DiagnosticList, Text: result.Diagnostics}
 		}
 		return errors.New("warrior validation failed")
 	}
@@ -106,38 +124,53 @@ func Validate(warrior string, cfg FightConfig) error {
 //
 // The returned source is a disassembly of the assembled instructions (not the
 // original source with labels/macros/comments). If exmars reports an assembly
-// failure, Assemble returns an error containing diagnostics when available.
+// failure, Assemble returns an *AssembleError containing diagnostics when available.
 func Assemble(warrior string, cfg FightConfig) (string, error) {
-	requireLibrary()
+	lib, f, err := libraryOrFallback()
+	if err != nil {
+		return "", err
+	}
+	if f != nil {
+		return f.Assemble(warrior, cfg)
+	}
+	return lib.Assemble(warrior, cfg)
+}
 
+// Assemble is the package-level Assemble using l.
+func (l *Library) Assemble(warrior string, cfg FightConfig) (string, error) {
 	cfg.Rounds = 1
 	if err := cfg.Validate(); err != nil {
 		return "", err
 	}
 	cfgC := toCFightCfg(cfg)
-	outBuf := make([]byte, diagnosticsBufferSize)
-	diagBuf := make([]byte, diagnosticsBufferSize)
+	outBuf := make([]byte, assembleBufferSize)
+	diag := newDiagnosticsBuffers()
 	var outLen int32
-	var diagLen int32
-
-	rc := assemble1(
-		warrior,
-		unsafe.Pointer(&cfgC),
-		unsafe.Pointer(&outBuf[0]), int32(len(outBuf)), &outLen,
-		unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
-	)
-
-	diag := diagnosticsString(diagBuf, diagLen)
-	if rc != 0 {
-		if diag != "" {
-			return "", errors.New(diag)
+
+	for {
+		rc := l.assemble1(
+			warrior,
+			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(&outBuf[0]), int32(len(outBuf)), &outLen,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
+		)
+		runtime.KeepAlive(diag.recs)
+
+		// exmars reports the full output size; retry once the buffers are large enough.
+		outTruncated := rc == 0 && outLen > int32(len(outBuf)-1)
+		if !outTruncated && !diag.truncated() {
+			if rc != 0 {
+				return "", diag.err()
+			}
+			break
 		}
-		return "", errors.New("assembly failed")
+		if outTruncated {
+			outBuf = make([]byte, outLen+1)
+		}
+		diag.grow()
 	}
 
-	if outLen > int32(len(outBuf)-1) {
-		outLen = int32(len(outBuf) - 1)
-	}
 	if outLen < 0 {
 		outLen = 0
 	}
@@ -150,6 +183,20 @@ func Assemble(warrior string, cfg FightConfig) (string, error) {
 // names are sorted to make evaluation order deterministic. Use result.Get(name)
 // to map a warrior name back to its sole-win count and the shared tie count.
 func FightNamed(warriors map[string]string, cfg FightConfig) (FightNamedResult, error) {
+	return fightNamed(warriors, cfg, Fight)
+}
+
+// FightNamed is the package-level FightNamed using l.
+func (l *Library) FightNamed(warriors map[string]string, cfg FightConfig) (FightNamedResult, error) {
+	return fightNamed(warriors, cfg, l.Fight)
+}
+
+// FightNamedWithEngine is FightNamed running the fight on e.
+func FightNamedWithEngine(e Engine, warriors map[string]string, cfg FightConfig) (FightNamedResult, error) {
+	return fightNamed(warriors, cfg, e.Fight)
+}
+
+func fightNamed(warriors map[string]string, cfg FightConfig, fight func([]string, FightConfig) (FightResult, error)) (FightNamedResult, error) {
 	if len(warriors) < 1 || len(warriors) > 6 {
 		return FightNamedResult{}, fmt.Errorf("FightNamed supports 1 to 6 warriors, got %d", len(warriors))
 	}
@@ -167,17 +214,27 @@ func FightNamed(warriors map[string]string, cfg FightConfig) (FightNamedResult,
 		index[name] = i
 	}
 
-	result, err := Fight(sources, cfg)
+	result, err := fight(sources, cfg)
 	return FightNamedResult{FightResult: result, index: index}, err
 }
 
 // Fight runs a fight for 1 to 6 warriors and returns the fight result.
 //
 // On parser/setup failure, the returned FightResult contains sentinel values
-// (negative wins/ties) and error is set to the diagnostics string when available.
+// (negative wins/ties) and error is an *AssembleError carrying the diagnostics.
 func Fight(warriors []string, cfg FightConfig) (FightResult, error) {
-	requireLibrary()
+	lib, f, err := libraryOrFallback()
+	if err != nil {
+		return FightResult{}, err
+	}
+	if f != nil {
+		return f.Fight(warriors, cfg)
+	}
+	return lib.Fight(warriors, cfg)
+}
 
+// Fight is the package-level Fight using l.
+func (l *Library) Fight(warriors []string, cfg FightConfig) (FightResult, error) {
 	if len(warriors) < 1 || len(warriors) > 6 {
 		return FightResult{}, fmt.Errorf("Fight supports 1 to 6 warriors, got %d", len(warriors))
 	}
@@ -185,77 +242,117 @@ func Fight(warriors []string, cfg FightConfig) (FightResult, error) {
 		return FightResult{}, err
 	}
 
+	return l.fight(warriors, cfg, newDiagnosticsBuffers())
+}
+
+// fight runs the fight with diag as the initial diagnostics buffers.
+func (l *Library) fight(warriors []string, cfg FightConfig, diag *diagnosticsBuffers) (FightResult, error) {
 	cfgC := toCFightCfg(cfg)
 	wins32 := make([]int32, len(warriors))
 	var ties32 int32
-	var diagLen int32
-	diagBuf := make([]byte, diagnosticsBufferSize)
 
+	l.fightN(warriors, &cfgC, wins32, &ties32, diag)
+	// exmars reports all diagnostics while assembling, so when they exceed
+	// the buffers the warriors are only assembled again with larger ones,
+	// without rerunning the fight.
+	if diag.truncated() {
+		var packed []byte
+		for _, w := range warriors {
+			// exmars reads each source up to its first NUL.
+			if i := strings.IndexByte(w, 0); i >= 0 {
+				w = w[:i]
+			}
+			packed = append(packed, w...)
+			packed = append(packed, 0)
+		}
+		for diag.truncated() {
+			diag.grow()
+			l.diagnoseWarriors(
+				unsafe.Pointer(&packed[0]), int32(len(warriors)),
+				unsafe.Pointer(&cfgC),
+				unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+				unsafe.Pointer(&diag.list),
+			)
+			runtime.KeepAlive(packed)
+			runtime.KeepAlive(diag.recs)
+		}
+	}
+
+	result := FightResult{
+		Wins:           make([]int, len(wins32)),
+		Ties:           int(ties32),
+		Diagnostics:    diag.String(),
+		DiagnosticList: diag.Diagnostics(),
+	}
+	for i, v := range wins32 {
+		result.Wins[i] = int(v)
+	}
+
+	if result.Failed() {
+		if result.Diagnostics != "" || len(result.DiagnosticList) > 0 {
+			return result, diag.err()
+		}
+		return result, errors.New("fight failed")
+	}
+	return result, nil
+}
+
+func (l *Library) fightN(warriors []string, cfgC *cFightCfg, wins32 []int32, ties32 *int32, diag *diagnosticsBuffers) {
 	switch len(warriors) {
 	case 1:
-		fight1(
+		l.fight1(
 			warriors[0],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	case 2:
-		fight2(
+		l.fight2(
 			warriors[0], warriors[1],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	case 3:
-		fight3(
+		l.fight3(
 			warriors[0], warriors[1], warriors[2],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	case 4:
-		fight4(
+		l.fight4(
 			warriors[0], warriors[1], warriors[2], warriors[3],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	case 5:
-		fight5(
+		l.fight5(
 			warriors[0], warriors[1], warriors[2], warriors[3], warriors[4],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	case 6:
-		fight6(
+		l.fight6(
 			warriors[0], warriors[1], warriors[2], warriors[3], warriors[4], warriors[5],
-			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(cfgC),
 			unsafe.Pointer(&wins32[0]), int32(len(wins32)),
-			&ties32,
-			unsafe.Pointer(&diagBuf[0]), int32(len(diagBuf)), &diagLen,
+			ties32,
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
 		)
 	}
-
-	result := FightResult{
-		Wins:        make([]int, len(wins32)),
-		Ties:        int(ties32),
-		Diagnostics: diagnosticsString(diagBuf, diagLen),
-	}
-	for i, v := range wins32 {
-		result.Wins[i] = int(v)
-	}
-
-	if result.Failed() {
-		if result.Diagnostics != "" {
-			return result, errors.New(result.Diagnostics)
-		}
-		return result, errors.New("fight failed")
-	}
-	return result, nil
+	runtime.KeepAlive(diag.recs)
 }
@@ -0,0 +1,167 @@
+package goexmars
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// cInsn mirrors goexmars_insn_t.
+type cInsn struct {
+	OpCode   int32
+	Modifier int32
+	ModeA    int32
+	A        int32
+	ModeB    int32
+	B        int32
+}
+
+// FightParsed runs a fight for 1 to 6 parsed warriors without a Redcode
+// text round-trip.
+//
+// The warriors' Commands are loaded directly into the simulator with End as the
+// start offset, so warriors built or mutated as Command slices skip the
+// assembler entirely. Name and Author are ignored.
+func FightParsed(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error) {
+	lib, f, err := libraryOrFallback()
+	if err != nil {
+		return FightResult{}, err
+	}
+	if f != nil {
+		return f.FightParsed(warriors, cfg)
+	}
+	return lib.FightParsed(warriors, cfg)
+}
+
+// FightParsedWithEngine is FightParsed running the fight on e.
+func FightParsedWithEngine(e Engine, warriors []ParsedWarrior, cfg FightConfig) (FightResult, error) {
+	return e.FightParsed(warriors, cfg)
+}
+
+// FightParsed is the package-level FightParsed using l.
+func (l *Library) FightParsed(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error) {
+	if len(warriors) < 1 || len(warriors) > 6 {
+		return FightResult{}, fmt.Errorf("FightParsed supports 1 to 6 warriors, got %d", len(warriors))
+	}
+	if err := cfg.Validate(); err != nil {
+		return FightResult{}, err
+	}
+
+	insns, lens, starts, err := toCWarriors(warriors, cfg)
+	if err != nil {
+		return FightResult{}, err
+	}
+
+	cfgC := toCFightCfg(cfg)
+	wins32 := make([]int32, len(warriors))
+	var ties32 int32
+	diag := newDiagnosticsBuffers()
+
+	l.fightInsns(
+		unsafe.Pointer(&insns[0]), unsafe.Pointer(&lens[0]), unsafe.Pointer(&starts[0]), int32(len(warriors)),
+		unsafe.Pointer(&cfgC),
+		unsafe.Pointer(&wins32[0]), int32(len(wins32)),
+		&ties32,
+		unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+		unsafe.Pointer(&diag.list),
+	)
+	// As in Fight, the diagnostics come from loading the warriors, so only
+	// that is repeated when they exceed the buffers.
+	for diag.truncated() {
+		diag.grow()
+		l.diagnoseInsns(
+			unsafe.Pointer(&insns[0]), unsafe.Pointer(&lens[0]), unsafe.Pointer(&starts[0]), int32(len(warriors)),
+			unsafe.Pointer(&cfgC),
+			unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+			unsafe.Pointer(&diag.list),
+		)
+	}
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(lens)
+	runtime.KeepAlive(starts)
+	runtime.KeepAlive(diag.recs)
+
+	result := FightResult{
+		Wins:           make([]int, len(wins32)),
+		Ties:           int(ties32),
+		Diagnostics:    diag.String(),
+		DiagnosticList: diag.Diagnostics(),
+	}
+	for i, v := range wins32 {
+		result.Wins[i] = int(v)
+	}
+
+	if result.Failed() {
+		if result.Diagnostics != "" || len(result.DiagnosticList) > 0 {
+			return result, diag.err()
+		}
+		return result, errors.New("fight failed")
+	}
+	return result, nil
+}
+
+// toCWarriors checks and flattens warriors into the arrays fight_insns takes.
+func toCWarriors(warriors []ParsedWarrior, cfg FightConfig) ([]cInsn, []int32, []int32, error) {
+	total := 0
+	for i, w := range warriors {
+		if err := checkLoadable(w, cfg); err != nil {
+			return nil, nil, nil, fmt.Errorf("warrior %d: %w", i, err)
+		}
+		total += len(w.Commands)
+	}
+
+	insns := make([]cInsn, 0, total)
+	lens := make([]int32, len(warriors))
+	starts := make([]int32, len(warriors))
+	for i, w := range warriors {
+		for _, cmd := range w.Commands {
+			insns = append(insns, toCInsn(cmd, cfg.CoreSize))
+		}
+		lens[i] = int32(len(w.Commands))
+		starts[i] = int32(modCore(w.End, cfg.CoreSize))
+	}
+	return insns, lens, starts, nil
+}
+
+// checkLoadable reports whether w can be loaded into a core configured by cfg.
+func checkLoadable(w ParsedWarrior, cfg FightConfig) error {
+	if len(w.Commands) == 0 {
+		return errors.New("no commands")
+	}
+	if len(w.Commands) > cfg.MaxWarriorLen {
+		return fmt.Errorf("length %d exceeds MaxWarriorLen %d", len(w.Commands), cfg.MaxWarriorLen)
+	}
+	if len(w.Commands) > cfg.MinSep {
+		return fmt.Errorf("length %d exceeds MinSep %d", len(w.Commands), cfg.MinSep)
+	}
+	for i, cmd := range w.Commands {
+		if int(cmd.OpCode) >= OpCodeCount || int(cmd.Modifier) >= ModifierCount ||
+			int(cmd.AddressingModeA) >= AddressingModeCount || int(cmd.AddressingModeB) >= AddressingModeCount {
+			return fmt.Errorf("invalid command encoding at offset %d", i)
+		}
+	}
+	return nil
+}
+
+func toCInsn(cmd Command, coreSize int) cInsn {
+	return cInsn{
+		OpCode:   int32(cmd.OpCode),
+		Modifier: int32(cmd.Modifier),
+		ModeA:    int32(cmd.AddressingModeA),
+		A:        int32(cmd.A % coreSize),
+		ModeB:    int32(cmd.AddressingModeB),
+		B:        int32(cmd.B % coreSize),
+	}
+}
+
+func fromCInsn(in cInsn) Command {
+	return Command{
+		OpCode:          OpCode(in.OpCode),
+		Modifier:        Modifier(in.Modifier),
+		AddressingModeA: AddressingMode(in.ModeA),
+		A:               int(in.A),
+		AddressingModeB: AddressingMode(in.ModeB),
+		B:               int(in.B),
+	}
+}
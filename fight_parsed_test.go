@@ -0,0 +1,67 @@
+package goexmars
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestFightParsedMatchesFight(t *testing.T) {
+	testlib.Configure(t)
+
+	const dwarf = `
+;redcode-94
+;name Dwarf
+ADD #4, 3
+MOV 2, @2
+JMP -2, 0
+DAT #0, #0
+END
+`
+
+	const paper = `
+;redcode-94
+;name Paper
+start SPL 1
+      MOV -1, 0
+      SPL 1
+      MOV.I #0, 100
+      MOV.I }-1, >-1
+      JMP start+2
+END start
+`
+
+	cfg := DefaultConfig.SetRounds(20).SetFixPos(1234)
+
+	a, err := AssembleParsed(dwarf, cfg)
+	if err != nil {
+		t.Fatalf("AssembleParsed dwarf: %v", err)
+	}
+	b, err := AssembleParsed(paper, cfg)
+	if err != nil {
+		t.Fatalf("AssembleParsed paper: %v", err)
+	}
+
+	want, err := Fight([]string{dwarf, paper}, cfg)
+	if err != nil {
+		t.Fatalf("Fight returned error: %v", err)
+	}
+	got, err := FightParsed([]ParsedWarrior{a, b}, cfg)
+	if err != nil {
+		t.Fatalf("FightParsed returned error: %v", err)
+	}
+	if got.Wins[0] != want.Wins[0] || got.Wins[1] != want.Wins[1] || got.Ties != want.Ties {
+		t.Fatalf("FightParsed result differs: got wins=%v ties=%d want wins=%v ties=%d", got.Wins, got.Ties, want.Wins, want.Ties)
+	}
+}
+
+func TestFightParsedRejectsOversizedWarrior(t *testing.T) {
+	testlib.Configure(t)
+
+	w := ParsedWarrior{Commands: make([]Command, TinyConfig.MaxWarriorLen+1)}
+	_, err := FightParsed([]ParsedWarrior{w}, TinyConfig)
+	if err == nil || !strings.Contains(err.Error(), "MaxWarriorLen") {
+		t.Fatalf("expected MaxWarriorLen error, got %v", err)
+	}
+}
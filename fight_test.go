@@ -3,10 +3,12 @@ package goexmars
 import (
 	"strings"
 	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
 )
 
 func TestFightTwoWarriorsRoundsSum(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -49,7 +51,7 @@ END
 }
 
 func TestFightMalformedWarriorReturnsSentinelResult(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const malformed = `
 ;redcode-94
@@ -78,7 +80,7 @@ END
 }
 
 func TestFightReturnsDiagnosticsOnMalformedWarrior(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const malformed = `
 ;redcode-94
@@ -113,7 +115,7 @@ END
 }
 
 func TestValidateValidWarrior(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -128,7 +130,7 @@ END
 }
 
 func TestValidateMalformedWarrior(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const malformed = `
 ;redcode-94
@@ -147,7 +149,7 @@ END
 }
 
 func TestFightThreeWarriorsRoundsSum(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -184,7 +186,7 @@ END
 }
 
 func TestFightNamedGet(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94
@@ -224,7 +226,7 @@ END
 }
 
 func BenchmarkFightTwoImps(b *testing.B) {
-	configureTestLibraryPath(b)
+	testlib.Configure(b)
 
 	const imp = `
 ;redcode-94
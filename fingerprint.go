@@ -10,8 +10,42 @@ type FingerprintOptions struct {
 	IncludeName   bool
 	IncludeAuthor bool
 	IncludeEnd    bool
+
+	// Canonical hashes w.Canonicalize(CoreSize) instead of w, so the same
+	// warrior gets the same fingerprint whether its operands are written as
+	// 7999 or -1 and whichever equivalent modifiers it uses. It is the same
+	// as a Level of FingerprintCanonical.
+	Canonical bool
+	// Level selects how far equivalent warriors are merged before hashing.
+	Level FingerprintLevel
+	// CoreSize is the core size the warrior was assembled for. Zero means
+	// DefaultConfig.CoreSize. It is only used with Canonical or a Level
+	// above FingerprintExact.
+	CoreSize int
 }
 
+// FingerprintLevel is an equivalence level for FingerprintWithOptions.
+type FingerprintLevel byte
+
+// Supported fingerprint levels. Each level merges everything the previous
+// one does.
+const (
+	// FingerprintExact hashes the commands as Format renders them.
+	FingerprintExact FingerprintLevel = iota
+	// FingerprintCanonical hashes ParsedWarrior.Canonicalize(CoreSize).
+	FingerprintCanonical
+	// FingerprintSemantic also treats behaviourally identical code as
+	// equal: it drops an unreachable, unreferenced tail, blanks DATs nothing
+	// reads and merges modifiers that are equivalent for immediate operands.
+	// The analysis is static and approximate, so warriors that read their
+	// code through computed pointers can be merged with ones that differ in
+	// the blanked cells. The dropped tail and the blanked DATs are still
+	// loaded into core, where an opponent's scanner sees them, so warriors
+	// that differ only in decoys share a fingerprint but may fare
+	// differently against scanners.
+	FingerprintSemantic
+)
+
 // DefaultFingerprintOptions returns the default fingerprinting options.
 //
 // By default metadata is ignored so the fingerprint tracks normalized code and
@@ -31,6 +65,16 @@ func (w ParsedWarrior) Fingerprint() string {
 
 // FingerprintWithOptions returns a SHA-256 fingerprint of the parsed warrior using opts.
 func (w ParsedWarrior) FingerprintWithOptions(opts FingerprintOptions) string {
+	coreSize := opts.CoreSize
+	if coreSize == 0 {
+		coreSize = DefaultConfig.CoreSize
+	}
+	switch {
+	case opts.Level >= FingerprintSemantic:
+		w = w.semanticForm(coreSize)
+	case opts.Level == FingerprintCanonical || opts.Canonical:
+		w = w.Canonicalize(coreSize)
+	}
 	text := w.Format(RedcodeFormatOptions{
 		IncludeName:   opts.IncludeName,
 		IncludeAuthor: opts.IncludeAuthor,
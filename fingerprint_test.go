@@ -0,0 +1,188 @@
+package goexmars
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func mustParseCommands(t *testing.T, src string) []Command {
+	t.Helper()
+	cmds, err := ParseAssembledCommands(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmds
+}
+
+func fingerprintAt(w ParsedWarrior, level FingerprintLevel) string {
+	opts := DefaultFingerprintOptions()
+	opts.Level = level
+	return w.FingerprintWithOptions(opts)
+}
+
+func TestFingerprintLevels(t *testing.T) {
+	dwarf := `
+ADD.AB # 4, $ 3
+MOV.I $ 2, @ 2
+JMP.B $ -2, $ 0
+DAT.F # 0, # 0
+`
+	tests := []struct {
+		name  string
+		a, b  string
+		level FingerprintLevel // lowest level at which a and b match
+	}{
+		{
+			name:  "operand range",
+			a:     "JMP.B $ -1, $ 0\nDAT.F $ 0, $ 0",
+			b:     "JMP.B $ 7999, $ 0\nDAT.F $ 0, $ 0",
+			level: FingerprintCanonical,
+		},
+		{
+			name:  "cmp and seq",
+			a:     "CMP.I $ 1, $ 2\nJMP.B $ -1, $ 0",
+			b:     "SEQ.I $ 1, $ 2\nJMP.B $ -1, $ 0",
+			level: FingerprintCanonical,
+		},
+		{
+			name:  "unreachable tail",
+			a:     dwarf,
+			b:     dwarf + "MOV.I $ 5, $ 7\nDAT.F # 3, # 4\n",
+			level: FingerprintSemantic,
+		},
+		{
+			name:  "unread dat",
+			a:     "JMP.B $ 2, $ 0\nDAT.F # 1, # 2\nSPL.B $ -2, $ 0\nJMP.B $ -1, $ 0",
+			b:     "JMP.B $ 2, $ 0\nDAT.AB $ 9, @ 4\nSPL.B $ -2, $ 0\nJMP.B $ -1, $ 0",
+			level: FingerprintSemantic,
+		},
+		{
+			name:  "immediate compare",
+			a:     "SEQ.A # 1, # 2\nJMP.B $ -1, $ 0",
+			b:     "SEQ.I # 1, # 2\nJMP.B $ -1, $ 0",
+			level: FingerprintSemantic,
+		},
+		{
+			// .AB compares 1 with 2 and so skips into the DAT; .F compares
+			// each field with itself and never skips.
+			name:  "immediate compare across fields",
+			a:     "SEQ.F # 1, # 2\nDAT.F # 0, # 0\nJMP.B $ 0, $ 0",
+			b:     "SEQ.AB # 1, # 2\nDAT.F # 0, # 0\nJMP.B $ 0, $ 0",
+			level: FingerprintSemantic + 1,
+		},
+		{
+			name:  "immediate compare exchanged fields",
+			a:     "SEQ.F # 1, # 2\nDAT.F # 0, # 0\nJMP.B $ 0, $ 0",
+			b:     "SEQ.X # 1, # 2\nDAT.F # 0, # 0\nJMP.B $ 0, $ 0",
+			level: FingerprintSemantic + 1,
+		},
+		{
+			name:  "bomb contents",
+			a:     dwarf,
+			b:     dwarf[:len(dwarf)-len("DAT.F # 0, # 0\n")] + "DAT.F # 0, # 5\n",
+			level: FingerprintSemantic + 1,
+		},
+		{
+			name:  "dat side effects",
+			a:     "SPL.B $ 2, $ 0\nDAT.F $ 0, $ 0\nJMP.B $ 0, $ 0",
+			b:     "SPL.B $ 2, $ 0\nDAT.F $ 0, < 5\nJMP.B $ 0, $ 0",
+			level: FingerprintSemantic + 1,
+		},
+		{
+			// The post-increments walk p through the zeros into the
+			// payload, which is copied like any other cell.
+			name:  "copy loop payload",
+			a:     "MOV.I } 2, > 2\nJMP.B $ -1, $ 0\nDAT.F # 3, # 100\nDAT.F $ 0, $ 0\nDAT.F $ 0, $ 0\nDAT.F $ 0, $ 0\nDAT.F # 5, # 7",
+			b:     "MOV.I } 2, > 2\nJMP.B $ -1, $ 0\nDAT.F # 3, # 100\nDAT.F $ 0, $ 0\nDAT.F $ 0, $ 0\nDAT.F $ 0, $ 0\nSPL.B # 0, # 0",
+			level: FingerprintSemantic + 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := ParsedWarrior{Commands: mustParseCommands(t, tt.a)}
+			b := ParsedWarrior{Commands: mustParseCommands(t, tt.b)}
+			for level := FingerprintExact; level <= FingerprintSemantic; level++ {
+				same := fingerprintAt(a, level) == fingerprintAt(b, level)
+				if same != (level >= tt.level) {
+					t.Errorf("level %d: same=%v, want %v", level, same, !same)
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprintCanonicalFlag(t *testing.T) {
+	w := ParsedWarrior{Commands: mustParseCommands(t, "CMP.I $ 7999, $ 1")}
+	opts := DefaultFingerprintOptions()
+	opts.Canonical = true
+	if w.FingerprintWithOptions(opts) != fingerprintAt(w, FingerprintCanonical) {
+		t.Fatal("Canonical flag and FingerprintCanonical level differ")
+	}
+}
+
+func TestSemanticFormKeepsIndirectJumpTargets(t *testing.T) {
+	w := ParsedWarrior{Commands: mustParseCommands(t, `
+SPL.B @ 0, } 400
+MOV.I } -1, > -1
+JMP.B $ -2, $ 0
+MOV.I $ 5, $ 7
+`)}
+	got := w.semanticForm(DefaultConfig.CoreSize)
+	if len(got.Commands) != len(w.Commands) {
+		t.Fatalf("expected no trimming with an indirect jump, got %v", got.Commands)
+	}
+}
+
+func TestSemanticFormKeepsCodeWhenEndIsOutside(t *testing.T) {
+	a := ParsedWarrior{End: 5, Commands: mustParseCommands(t, "MOV.I $ 0, $ 1\nDAT.F $ 1, $ 2")}
+	b := ParsedWarrior{End: 5, Commands: mustParseCommands(t, "ADD.AB # 4, $ 3\nJMP.B $ -1, $ 0")}
+	empty := ParsedWarrior{End: 5}
+
+	got := a.semanticForm(DefaultConfig.CoreSize)
+	if len(got.Commands) != len(a.Commands) || got.Commands[1] != a.Commands[1] {
+		t.Fatalf("expected the code to be kept, got %v", got.Commands)
+	}
+	if fingerprintAt(a, FingerprintSemantic) == fingerprintAt(b, FingerprintSemantic) {
+		t.Fatal("different warriors with End outside the code share a semantic fingerprint")
+	}
+	if fingerprintAt(a, FingerprintSemantic) == fingerprintAt(empty, FingerprintSemantic) {
+		t.Fatal("warrior with End outside the code matches an empty warrior")
+	}
+}
+
+func TestSemanticFormFightsIdentically(t *testing.T) {
+	testlib.Configure(t)
+
+	w, err := AssembleParsed(`
+        add.ab #4, bomb
+        mov.i  bomb, @bomb
+        jmp    -2
+        dat    #7, #9
+bomb    dat    #0, #0
+        mov.i  1, 2
+        dat    5, 5
+`, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := w.semanticForm(DefaultConfig.CoreSize)
+	if len(s.Commands) != 5 || s.Commands[3] != (Command{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingDirect, AddressingModeB: AddressingDirect}) {
+		t.Fatalf("unexpected semantic form:\n%s", s)
+	}
+
+	imp := ParsedWarrior{Commands: mustParseCommands(t, "MOV.I $ 0, $ 1")}
+	cfg := DefaultConfig.SetRounds(20).SetFixPos(4000)
+	want, err := FightParsed([]ParsedWarrior{w, imp}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FightParsed([]ParsedWarrior{s, imp}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Wins, want.Wins) || got.Ties != want.Ties {
+		t.Fatalf("semantic form fights differently: %v/%d vs %v/%d", got.Wins, got.Ties, want.Wins, want.Ties)
+	}
+}
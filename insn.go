@@ -0,0 +1,114 @@
+package goexmars
+
+import "fmt"
+
+// Insn mirrors exhaust's insn_t core cell. In packs the opcode, modifier and
+// addressing modes as
+//
+//	bit   15 14 | 13 ... 9 | 8 7 6 | 5 4 3  | 2 1 0
+//	      flags | opcode   | mod   | b-mode | a-mode
+//
+// using exhaust's own opcode and mode numbering, which differs from the
+// OpCode and AddressingMode order. A and B are reduced modulo the core size.
+type Insn struct {
+	A, B uint32
+	In   uint32
+}
+
+const (
+	insnModeBits  = 3
+	insnModeMask  = 1<<insnModeBits - 1
+	insnOpModBits = 2 * insnModeBits
+	// insnFieldMask strips the flag bits from Insn.In.
+	insnFieldMask = 1<<14 - 1
+)
+
+// Opcodes and addressing modes in exhaust's encoding (insn.h).
+var (
+	insnOpCodes = [OpCodeCount]uint32{
+		OpCodeDAT: 0,
+		OpCodeSPL: 1,
+		OpCodeMOV: 2,
+		OpCodeDJN: 3,
+		OpCodeADD: 4,
+		OpCodeJMZ: 5,
+		OpCodeSUB: 6,
+		OpCodeCMP: 7,
+		OpCodeSEQ: 7,
+		OpCodeSNE: 8,
+		OpCodeSLT: 9,
+		OpCodeJMN: 10,
+		OpCodeJMP: 11,
+		OpCodeNOP: 12,
+		OpCodeMUL: 13,
+		OpCodeMOD: 14,
+		OpCodeDIV: 15,
+		OpCodeLDP: 16,
+		OpCodeSTP: 17,
+	}
+	insnAddressingModes = [AddressingModeCount]uint32{
+		AddressingDirect:        0,
+		AddressingImmediate:     1,
+		AddressingBIndirect:     2,
+		AddressingBIndirectPre:  3,
+		AddressingBIndirectPost: 4,
+		AddressingAIndirect:     5,
+		AddressingAIndirectPre:  6,
+		AddressingAIndirectPost: 7,
+	}
+)
+
+// Reverse maps of the tables above. SEQ wins over CMP.
+var (
+	insnGoOpCodes         [18]OpCode
+	insnGoAddressingModes [AddressingModeCount]AddressingMode
+)
+
+func init() {
+	for op, ex := range insnOpCodes {
+		if OpCode(op) != OpCodeCMP {
+			insnGoOpCodes[ex] = OpCode(op)
+		}
+	}
+	for mode, ex := range insnAddressingModes {
+		insnGoAddressingModes[ex] = AddressingMode(mode)
+	}
+}
+
+// Insn encodes c the way exhaust stores it in a core of the given size. CMP
+// is encoded as SEQ. It panics if c holds out-of-range enum values or
+// coreSize is not positive.
+func (c Command) Insn(coreSize int) Insn {
+	in := (insnOpCodes[c.OpCode]<<insnModeBits|uint32(c.Modifier))<<insnOpModBits |
+		insnAddressingModes[c.AddressingModeB]<<insnModeBits |
+		insnAddressingModes[c.AddressingModeA]
+	return Insn{A: modCore(c.A, coreSize), B: modCore(c.B, coreSize), In: in}
+}
+
+// CommandFromInsn decodes an exhaust core cell. Flag bits are ignored and
+// the opcode exhaust shares between CMP and SEQ decodes as SEQ.
+func CommandFromInsn(in Insn) (Command, error) {
+	v := in.In & insnFieldMask
+	op := v >> (insnOpModBits + insnModeBits)
+	mod := v >> insnOpModBits & insnModeMask
+	if op >= uint32(len(insnGoOpCodes)) || mod >= uint32(ModifierCount) {
+		return Command{}, fmt.Errorf("invalid instruction encoding 0x%04x", v)
+	}
+	return Command{
+		OpCode:          insnGoOpCodes[op],
+		Modifier:        Modifier(mod),
+		AddressingModeA: insnGoAddressingModes[v&insnModeMask],
+		A:               int(in.A),
+		AddressingModeB: insnGoAddressingModes[v>>insnModeBits&insnModeMask],
+		B:               int(in.B),
+	}, nil
+}
+
+// modCore reduces x into [0, m) like exhaust's MODS macro.
+func modCore(x, m int) uint32 {
+	x %= m
+	if x < 0 {
+		x += m
+	}
+	return uint32(x)
+}
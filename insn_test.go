@@ -0,0 +1,66 @@
+package goexmars
+
+import "testing"
+
+func TestCommandInsn(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		want Insn
+	}{
+		{
+			cmd:  Command{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingImmediate, AddressingModeB: AddressingImmediate},
+			want: Insn{In: 1<<3 | 1},
+		},
+		{
+			cmd:  Command{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: 1},
+			want: Insn{B: 1, In: (2<<3 | 6) << 6},
+		},
+		{
+			cmd:  Command{OpCode: OpCodeCMP, Modifier: ModifierX, AddressingModeA: AddressingAIndirectPost, A: -1, AddressingModeB: AddressingBIndirectPre, B: 8001},
+			want: Insn{A: 7999, B: 1, In: (7<<3|5)<<6 | 3<<3 | 7},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.Insn(8000); got != tt.want {
+			t.Errorf("%v: got %+v, want %+v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestCommandFromInsnRoundTrip(t *testing.T) {
+	for op := 0; op < OpCodeCount; op++ {
+		for mod := 0; mod < ModifierCount; mod++ {
+			for mode := 0; mode < AddressingModeCount; mode++ {
+				cmd := Command{
+					OpCode:          OpCode(op),
+					Modifier:        Modifier(mod),
+					AddressingModeA: AddressingMode(mode),
+					A:               mode,
+					AddressingModeB: AddressingMode(AddressingModeCount - 1 - mode),
+					B:               op,
+				}
+				in := cmd.Insn(8000)
+				// The start flag must not affect decoding.
+				in.In |= 1 << 14
+				got, err := CommandFromInsn(in)
+				if err != nil {
+					t.Fatalf("%v: %v", cmd, err)
+				}
+				if cmd.OpCode == OpCodeCMP {
+					cmd.OpCode = OpCodeSEQ
+				}
+				if got != cmd {
+					t.Fatalf("got %v, want %v", got, cmd)
+				}
+			}
+		}
+	}
+}
+
+func TestCommandFromInsnInvalid(t *testing.T) {
+	for _, in := range []uint32{18 << 9, 31 << 9, 7 << 6} {
+		if _, err := CommandFromInsn(Insn{In: in}); err == nil {
+			t.Errorf("expected error for 0x%04x", in)
+		}
+	}
+}
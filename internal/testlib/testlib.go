@@ -0,0 +1,41 @@
+// Package testlib points tests at the exmars shared library that
+// exmars/build.sh builds into lib/ at the repository root.
+package testlib
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Path returns the absolute path of the shared library in the repository's
+// lib/ directory, whether or not it has been built.
+func Path() string {
+	_, file, _, _ := runtime.Caller(0)
+	name := "libexmars.so"
+	switch runtime.GOOS {
+	case "darwin":
+		name = "libexmars.dylib"
+	case "windows":
+		name = "exmars.dll"
+	}
+	return filepath.Join(filepath.Dir(file), "..", "..", "lib", name)
+}
+
+// Configure sets GOEXMARS_LIB_PATH to Path for the duration of the test and
+// returns the path. The test is skipped if the library has not been built.
+func Configure(tb testing.TB) string {
+	tb.Helper()
+
+	src := Path()
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			tb.Skipf("shared library not found at %s", src)
+		}
+		tb.Fatalf("stat source library: %v", err)
+	}
+
+	tb.Setenv("GOEXMARS_LIB_PATH", src)
+	return src
+}
@@ -1,11 +1,14 @@
 package goexmars
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
@@ -13,53 +16,155 @@ import (
 
 const exmarsLibraryPathEnv = "GOEXMARS_LIB_PATH"
 
+// Library is a loaded exmars shared library.
+//
+// The package-level functions use a default library found via
+// GOEXMARS_LIB_PATH, a path provider or the lib/ directory next to the
+// executable. LoadLibrary opens further libraries, e.g. an old and a new
+// build side by side for A/B comparisons. Each is loaded with local symbol
+// binding, so they never resolve each other's functions. Libraries stay
+// loaded for the lifetime of the process and are safe for concurrent use.
+type Library struct {
+	path         string
+	abiVersion   int
+	capabilities Capabilities
+
+	fight1     func(string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	fight2     func(string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	fight3     func(string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	fight4     func(string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	fight5     func(string, string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	fight6     func(string, string, string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	assemble1  func(string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer) int32
+	fightInsns func(unsafe.Pointer, unsafe.Pointer, unsafe.Pointer, int32, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32, unsafe.Pointer)
+	traceInsns func(unsafe.Pointer, unsafe.Pointer, unsafe.Pointer, int32, unsafe.Pointer, int32, *cTrace, unsafe.Pointer, int32, *int32, unsafe.Pointer) int32
+	traceFree  func(*cTrace)
+	evalExpr1  func(string, unsafe.Pointer, unsafe.Pointer, unsafe.Pointer, int32, *int64, unsafe.Pointer, int32, *int32, unsafe.Pointer) int32
+
+	diagnoseWarriors func(unsafe.Pointer, int32, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer) int32
+	diagnoseInsns    func(unsafe.Pointer, unsafe.Pointer, unsafe.Pointer, int32, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer) int32
+}
+
+// LoadLibrary opens the exmars shared library at path. If path is a
+// directory, the platform library name is appended.
+func LoadLibrary(path string) (*Library, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, exmarsLibraryName())
+	}
+
+	handle, err := purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_LOCAL)
+	if err != nil {
+		// dlopen does not say why it failed; report a missing file as
+		// fs.ErrNotExist so the fallback can tell it from a broken library.
+		if _, statErr := os.Stat(path); errors.Is(statErr, fs.ErrNotExist) {
+			return nil, fmt.Errorf("open exmars library %q: %w", path, statErr)
+		}
+		return nil, fmt.Errorf("open exmars library %q: %w", path, err)
+	}
+
+	// Check the handshake before binding anything whose layout may differ.
+	// The handle is closed again on every error; only a loaded Library keeps
+	// it open.
+	var abiVersion func() int32
+	var capabilities func() uint32
+	for _, fn := range []struct {
+		fptr any
+		name string
+	}{
+		{&abiVersion, "goexmars_abi_version"},
+		{&capabilities, "goexmars_capabilities"},
+	} {
+		sym, err := purego.Dlsym(handle, fn.name)
+		if err != nil {
+			purego.Dlclose(handle)
+			return nil, fmt.Errorf("exmars library %q predates ABI versioning: missing symbol %s", path, fn.name)
+		}
+		purego.RegisterFunc(fn.fptr, sym)
+	}
+	l := &Library{path: path, abiVersion: int(abiVersion()), capabilities: Capabilities(capabilities())}
+	if err := checkABI(l.abiVersion, l.capabilities); err != nil {
+		purego.Dlclose(handle)
+		return nil, fmt.Errorf("exmars library %q: %w", path, err)
+	}
+
+	funcs := []struct {
+		fptr any
+		name string
+	}{
+		{&l.fight1, "fight_1"},
+		{&l.fight2, "fight_2"},
+		{&l.fight3, "fight_3"},
+		{&l.fight4, "fight_4"},
+		{&l.fight5, "fight_5"},
+		{&l.fight6, "fight_6"},
+		{&l.assemble1, "assemble_1"},
+		{&l.fightInsns, "fight_insns"},
+		{&l.traceInsns, "trace_insns"},
+		{&l.traceFree, "trace_free"},
+		{&l.evalExpr1, "eval_expr_1"},
+		{&l.diagnoseWarriors, "diagnose_warriors"},
+		{&l.diagnoseInsns, "diagnose_insns"},
+	}
+	for _, fn := range funcs {
+		sym, err := purego.Dlsym(handle, fn.name)
+		if err != nil {
+			purego.Dlclose(handle)
+			return nil, fmt.Errorf("exmars library %q: missing symbol %s: %w", path, fn.name, err)
+		}
+		purego.RegisterFunc(fn.fptr, sym)
+	}
+	return l, nil
+}
+
+// Path returns the file the library was loaded from.
+func (l *Library) Path() string {
+	return l.path
+}
+
 var (
-	loadOnce sync.Once
-	loadErr  error
-
-	fight1 func(string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	fight2 func(string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	fight3 func(string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	fight4 func(string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	fight5 func(string, string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	fight6 func(string, string, string, string, string, string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32)
-	assemble1 func(string, unsafe.Pointer, unsafe.Pointer, int32, *int32, unsafe.Pointer, int32, *int32) int32
+	loadOnce   sync.Once
+	defaultLib *Library
+	loadErr    error
 )
 
-func loadLibrary() error {
+// DefaultLibrary loads the library the package-level functions use, if it
+// is not loaded yet, and returns it. Unlike those functions it never uses
+// the registered fallback, so it tells whether results come from exmars.
+func DefaultLibrary() (*Library, error) {
 	loadOnce.Do(func() {
 		libPath, err := exmarsLibraryPath()
 		if err != nil {
 			loadErr = err
 			return
 		}
-
-		handle, err := purego.Dlopen(libPath, purego.RTLD_NOW|purego.RTLD_GLOBAL)
-		if err != nil {
-			loadErr = fmt.Errorf("open exmars library %q: %w", libPath, err)
-			return
-		}
-
-		purego.RegisterLibFunc(&fight1, handle, "fight_1")
-		purego.RegisterLibFunc(&fight2, handle, "fight_2")
-		purego.RegisterLibFunc(&fight3, handle, "fight_3")
-		purego.RegisterLibFunc(&fight4, handle, "fight_4")
-		purego.RegisterLibFunc(&fight5, handle, "fight_5")
-		purego.RegisterLibFunc(&fight6, handle, "fight_6")
-		purego.RegisterLibFunc(&assemble1, handle, "assemble_1")
+		defaultLib, loadErr = LoadLibrary(libPath)
 	})
+	return defaultLib, loadErr
+}
+
+var libPathProvider atomic.Pointer[func() (string, error)]
 
-	return loadErr
+// SetLibPathProvider installs fn to resolve the shared library path when the
+// library is first loaded, in place of the lib/ directory next to the
+// executable. GOEXMARS_LIB_PATH still takes precedence. Passing nil removes
+// the provider. It has no effect once the library is loaded.
+func SetLibPathProvider(fn func() (string, error)) {
+	if fn == nil {
+		libPathProvider.Store(nil)
+		return
+	}
+	libPathProvider.Store(&fn)
 }
 
 func exmarsLibraryPath() (string, error) {
 	if p := os.Getenv(exmarsLibraryPathEnv); p != "" {
-		if info, err := os.Stat(p); err == nil && info.IsDir() {
-			return filepath.Join(p, exmarsLibraryName()), nil
-		}
 		return p, nil
 	}
 
+	if fn := libPathProvider.Load(); fn != nil {
+		return (*fn)()
+	}
+
 	exePath, err := os.Executable()
 	if err != nil {
 		return "", fmt.Errorf("resolve executable path: %w", err)
@@ -81,9 +186,3 @@ func exmarsLibraryName() string {
 		return "libexmars.so"
 	}
 }
-
-func requireLibrary() {
-	if err := loadLibrary(); err != nil {
-		panic(err)
-	}
-}
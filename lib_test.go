@@ -0,0 +1,87 @@
+package goexmars
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func copyTestLibrary(t *testing.T, dir string) string {
+	t.Helper()
+
+	src := testlib.Path()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.Skipf("shared library not found at %s", src)
+		}
+		t.Fatalf("read library: %v", err)
+	}
+	dst := filepath.Join(dir, exmarsLibraryName())
+	if err := os.WriteFile(dst, data, 0o755); err != nil {
+		t.Fatalf("copy library: %v", err)
+	}
+	return dst
+}
+
+func TestLoadLibrarySideBySide(t *testing.T) {
+	pathA := copyTestLibrary(t, t.TempDir())
+	pathB := copyTestLibrary(t, t.TempDir())
+
+	a, err := LoadLibrary(pathA)
+	if err != nil {
+		t.Fatalf("LoadLibrary(%s) returned error: %v", pathA, err)
+	}
+	// Directories resolve to the platform library inside.
+	b, err := LoadLibrary(filepath.Dir(pathB))
+	if err != nil {
+		t.Fatalf("LoadLibrary(%s) returned error: %v", filepath.Dir(pathB), err)
+	}
+	if a.Path() != pathA || b.Path() != pathB {
+		t.Fatalf("unexpected paths %q and %q", a.Path(), b.Path())
+	}
+
+	warriors := []string{
+		";redcode-94\nADD #4, 3\nMOV 2, @2\nJMP -2, 0\nDAT #0, #0\nEND\n",
+		";redcode-94\nMOV 0, 1\nEND\n",
+	}
+	cfg := DefaultConfig.SetRounds(10).SetFixPos(2000)
+
+	resA, err := a.Fight(warriors, cfg)
+	if err != nil {
+		t.Fatalf("a.Fight returned error: %v", err)
+	}
+	resB, err := b.Fight(warriors, cfg)
+	if err != nil {
+		t.Fatalf("b.Fight returned error: %v", err)
+	}
+	if !reflect.DeepEqual(resA, resB) {
+		t.Fatalf("libraries disagree: %+v vs %+v", resA, resB)
+	}
+
+	if _, err := b.Assemble("MOV.Z 0, 1\n", cfg); err == nil {
+		t.Fatalf("expected assembly error from b")
+	}
+	if err := a.Validate(warriors[0], cfg); err != nil {
+		t.Fatalf("a.Validate returned error: %v", err)
+	}
+}
+
+func TestLoadLibraryMissingFile(t *testing.T) {
+	if _, err := LoadLibrary(filepath.Join(t.TempDir(), "missing.so")); err == nil {
+		t.Fatalf("expected error for missing library")
+	}
+}
+
+func TestLoadLibraryRejectsNonLibrary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), exmarsLibraryName())
+	if err := os.WriteFile(path, []byte("not a library"), 0o755); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := LoadLibrary(path); err == nil {
+		t.Fatalf("expected error for invalid library")
+	}
+}
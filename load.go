@@ -0,0 +1,387 @@
+package goexmars
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadedWarrior is a warrior read by a Loader.
+type LoadedWarrior struct {
+	// Path is the slash-separated path of the file the warrior came from.
+	// Entries of archives are written as "archive.zip/entry.red".
+	Path string
+	// Index is the position of the warrior within its file, see
+	// SplitWarriors.
+	Index int
+	// Source is the warrior's Redcode.
+	Source string
+	// Warrior is the assembled and parsed warrior.
+	Warrior ParsedWarrior
+}
+
+// LoadError is a file or warrior a Loader could not load.
+type LoadError struct {
+	// Path is the slash-separated path of the file, as in LoadedWarrior.
+	Path string
+	// Index is the position of the failing warrior within the file, or -1
+	// if the file itself could not be read.
+	Index int
+	Err   error
+}
+
+func (e *LoadError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s#%d: %v", e.Path, e.Index, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadResult holds the warriors and errors of a load.
+type LoadResult struct {
+	Warriors []LoadedWarrior
+	Errors   []*LoadError
+}
+
+// Err joins Errors into one error, or returns nil if there are none.
+func (r LoadResult) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// ParsedWarriors returns the parsed warriors in load order.
+func (r LoadResult) ParsedWarriors() []ParsedWarrior {
+	out := make([]ParsedWarrior, len(r.Warriors))
+	for i, w := range r.Warriors {
+		out[i] = w.Warrior
+	}
+	return out
+}
+
+// DefaultWarriorExtensions are the file extensions a Loader reads by default.
+var DefaultWarriorExtensions = []string{".red"}
+
+// Default limits of a Loader, far above any real warrior collection.
+const (
+	// DefaultMaxLoadFileSize is the default Loader.MaxFileSize.
+	DefaultMaxLoadFileSize = 64 << 20
+	// DefaultMaxArchiveDepth is the default Loader.MaxArchiveDepth.
+	DefaultMaxArchiveDepth = 4
+)
+
+// Loader reads warriors from directories, fs.FS values (including
+// embed.FS) and zip or tar.gz archives.
+//
+// Directories are read recursively in lexical order. Archives found while
+// reading, at any depth, are read as well. Every warrior file may hold
+// several warriors, see SplitWarriors.
+//
+// Files that cannot be read and warriors that do not assemble are recorded
+// in LoadResult.Errors and the load continues, as are files larger than
+// MaxFileSize and archives nested deeper than MaxArchiveDepth. The returned
+// error is only set when the root itself cannot be opened.
+type Loader struct {
+	// Config is used for assembling. The zero value means DefaultConfig.
+	Config FightConfig
+	// Engine assembles the warriors. Nil means DefaultEngine.
+	Engine Engine
+	// Extensions lists the warrior file extensions, matched
+	// case-insensitively. Nil means DefaultWarriorExtensions.
+	Extensions []string
+	// MaxFileSize caps the size of every file read into memory: a warrior
+	// file given as root and every file of a directory or archive, after
+	// decompression. An archive given as root is read in place, so only its
+	// entries are capped. Zero means DefaultMaxLoadFileSize.
+	MaxFileSize int64
+	// MaxArchiveDepth caps how many archives may enclose each other; an
+	// archive given as root counts as the first. Zero means
+	// DefaultMaxArchiveDepth.
+	MaxArchiveDepth int
+}
+
+// LoadWarriors reads the warriors at path, which may be a directory, a
+// warrior file or a zip or tar.gz archive. See Loader.
+func LoadWarriors(path string, cfg FightConfig) (LoadResult, error) {
+	return Loader{Config: cfg}.Load(path)
+}
+
+// LoadWarriorsFS reads all warriors in fsys. See Loader.
+func LoadWarriorsFS(fsys fs.FS, cfg FightConfig) (LoadResult, error) {
+	return Loader{Config: cfg}.LoadFS(fsys)
+}
+
+// Load reads the warriors at path, which may be a directory, a warrior file
+// or a zip or tar.gz archive.
+func (l Loader) Load(name string) (LoadResult, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if info.IsDir() {
+		return l.LoadFS(os.DirFS(name))
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	defer f.Close()
+
+	var res LoadResult
+	slashed := filepath.ToSlash(name)
+	switch {
+	case isZip(name):
+		err = l.loadZip(&res, slashed+"/", f, info.Size(), 1)
+	case isTarGz(name):
+		err = l.loadTarGz(&res, slashed+"/", f, 1)
+	default:
+		data, readErr := readLimited(f, l.maxFileSize(), "file")
+		if readErr != nil {
+			res.Errors = append(res.Errors, &LoadError{Path: slashed, Index: -1, Err: readErr})
+			break
+		}
+		l.loadFile(&res, slashed, data)
+	}
+	return res, err
+}
+
+// LoadFS reads all warriors in fsys.
+func (l Loader) LoadFS(fsys fs.FS) (LoadResult, error) {
+	var res LoadResult
+	if _, err := fs.Stat(fsys, "."); err != nil {
+		return res, err
+	}
+	l.loadFS(&res, "", fsys, 0)
+	return res, nil
+}
+
+// LoadZip reads all warriors in a zip archive.
+func (l Loader) LoadZip(r io.ReaderAt, size int64) (LoadResult, error) {
+	var res LoadResult
+	err := l.loadZip(&res, "", r, size, 1)
+	return res, err
+}
+
+// LoadTarGz reads all warriors in a gzip-compressed tar archive.
+func (l Loader) LoadTarGz(r io.Reader) (LoadResult, error) {
+	var res LoadResult
+	err := l.loadTarGz(&res, "", r, 1)
+	return res, err
+}
+
+// loadFS reads the files of fsys, which is enclosed by depth archives.
+func (l Loader) loadFS(res *LoadResult, prefix string, fsys fs.FS, depth int) {
+	_ = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			res.Errors = append(res.Errors, &LoadError{Path: prefix + name, Index: -1, Err: err})
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !l.wants(name) {
+			return nil
+		}
+		data, err := l.readFile(fsys, name)
+		if err != nil {
+			res.Errors = append(res.Errors, &LoadError{Path: prefix + name, Index: -1, Err: err})
+			return nil
+		}
+		l.loadEntry(res, prefix+name, data, depth)
+		return nil
+	})
+}
+
+// readFile reads name from fsys, failing if it exceeds MaxFileSize.
+func (l Loader) readFile(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, l.maxFileSize(), "file")
+}
+
+// loadZip reads a zip archive that is the depth-th of nested archives.
+func (l Loader) loadZip(res *LoadResult, prefix string, r io.ReaderAt, size int64, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	l.loadFS(res, prefix, zr, depth)
+	return nil
+}
+
+// loadTarGz reads a tar.gz archive that is the depth-th of nested archives.
+func (l Loader) loadTarGz(res *LoadResult, prefix string, r io.Reader, depth int) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// A corrupt stream cannot be resynchronised.
+			res.Errors = append(res.Errors, &LoadError{Path: strings.TrimSuffix(prefix, "/"), Index: -1, Err: err})
+			return nil
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !l.wants(name) {
+			continue
+		}
+		data, err := readLimited(tr, l.maxFileSize(), "file")
+		if err != nil {
+			res.Errors = append(res.Errors, &LoadError{Path: prefix + name, Index: -1, Err: err})
+			continue
+		}
+		l.loadEntry(res, prefix+name, data, depth)
+	}
+}
+
+// loadEntry loads a file found in a directory or archive enclosed by depth
+// archives.
+func (l Loader) loadEntry(res *LoadResult, name string, data []byte, depth int) {
+	var err error
+	switch {
+	case (isZip(name) || isTarGz(name)) && depth >= l.maxArchiveDepth():
+		err = fmt.Errorf("archive nested more than %d deep", l.maxArchiveDepth())
+	case isZip(name):
+		err = l.loadZip(res, name+"/", bytes.NewReader(data), int64(len(data)), depth+1)
+	case isTarGz(name):
+		err = l.loadTarGz(res, name+"/", bytes.NewReader(data), depth+1)
+	default:
+		l.loadFile(res, name, data)
+	}
+	if err != nil {
+		res.Errors = append(res.Errors, &LoadError{Path: name, Index: -1, Err: err})
+	}
+}
+
+// loadFile assembles the warriors in a warrior file.
+func (l Loader) loadFile(res *LoadResult, name string, data []byte) {
+	cfg := l.Config
+	if cfg == (FightConfig{}) {
+		cfg = DefaultConfig
+	}
+	e := l.Engine
+	if e == nil {
+		e = DefaultEngine
+	}
+
+	for i, src := range SplitWarriors(string(data)) {
+		w, err := AssembleParsedWithEngine(e, src, cfg)
+		if err != nil {
+			res.Errors = append(res.Errors, &LoadError{Path: name, Index: i, Err: err})
+			continue
+		}
+		res.Warriors = append(res.Warriors, LoadedWarrior{Path: name, Index: i, Source: src, Warrior: w})
+	}
+}
+
+func (l Loader) maxFileSize() int64 {
+	if l.MaxFileSize == 0 {
+		return DefaultMaxLoadFileSize
+	}
+	return l.MaxFileSize
+}
+
+func (l Loader) maxArchiveDepth() int {
+	if l.MaxArchiveDepth == 0 {
+		return DefaultMaxArchiveDepth
+	}
+	return l.MaxArchiveDepth
+}
+
+// wants reports whether a file is a warrior file or an archive.
+func (l Loader) wants(name string) bool {
+	if isZip(name) || isTarGz(name) {
+		return true
+	}
+	exts := l.Extensions
+	if exts == nil {
+		exts = DefaultWarriorExtensions
+	}
+	ext := path.Ext(name)
+	for _, e := range exts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+func isTarGz(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// SplitWarriors splits a file holding several warriors at its ;redcode
+// header lines.
+//
+// pMARS stops reading a warrior at its second ;redcode line, so each such
+// line starts a new warrior. A source with at most one ;redcode line is
+// returned whole. Otherwise every part runs from one ;redcode line to the
+// next, and text before the first one is dropped, as pMARS ignores it.
+func SplitWarriors(src string) []string {
+	var starts []int
+	offset := 0
+	for _, line := range strings.SplitAfter(src, "\n") {
+		if isRedcodeLine(line) {
+			starts = append(starts, offset)
+		}
+		offset += len(line)
+	}
+	if len(starts) <= 1 {
+		return []string{src}
+	}
+
+	parts := make([]string, len(starts))
+	for i, start := range starts {
+		end := len(src)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		parts[i] = src[start:end]
+	}
+	return parts
+}
+
+// isRedcodeLine reports whether line is a ;redcode header the way pMARS
+// recognises it: "redcode" directly after the ';', as a whole word.
+func isRedcodeLine(line string) bool {
+	line = strings.TrimLeft(line, " \t")
+	if len(line) < len(";redcode") || line[0] != ';' || !strings.EqualFold(line[1:len(";redcode")], "redcode") {
+		return false
+	}
+	rest := line[len(";redcode"):]
+	if rest == "" {
+		return true
+	}
+	c := rest[0]
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_')
+}
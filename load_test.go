@@ -0,0 +1,341 @@
+package goexmars
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+var errBroken = errors.New("broken warrior")
+
+// loadTestEngine assembles every source to an imp unless it contains BROKEN.
+type loadTestEngine struct{}
+
+func (loadTestEngine) Fight(warriors []string, cfg FightConfig) (FightResult, error) {
+	return FightResult{}, errors.New("not implemented")
+}
+
+func (loadTestEngine) Assemble(warrior string, cfg FightConfig) (string, error) {
+	if strings.Contains(warrior, "BROKEN") {
+		return "", errBroken
+	}
+	return "MOV.I $     0, $     1\nEND 0\n", nil
+}
+
+func (loadTestEngine) Validate(warrior string, cfg FightConfig) error {
+	_, err := loadTestEngine{}.Assemble(warrior, cfg)
+	return err
+}
+
+func (loadTestEngine) FightParsed(warriors []ParsedWarrior, cfg FightConfig) (FightResult, error) {
+	return FightResult{}, errors.New("not implemented")
+}
+
+func (loadTestEngine) TraceParsed(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error) {
+	return nil, errors.New("not implemented")
+}
+
+func warriorSource(name string) string {
+	return ";redcode-94\n;name " + name + "\nMOV 0, 1\nEND\n"
+}
+
+func loadedNames(res LoadResult) []string {
+	var names []string
+	for _, w := range res.Warriors {
+		names = append(names, w.Path+":"+w.Warrior.Name)
+	}
+	return names
+}
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedKeys(files) {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSplitWarriors(t *testing.T) {
+	single := "; header\n" + warriorSource("a")
+	if got := SplitWarriors(single); !reflect.DeepEqual(got, []string{single}) {
+		t.Fatalf("single warrior split into %q", got)
+	}
+
+	multi := "notes before the first warrior\n" + warriorSource("a") + "  ;REDCODE\n;name b\nJMP 0\n"
+	got := SplitWarriors(multi)
+	want := []string{warriorSource("a"), "  ;REDCODE\n;name b\nJMP 0\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitWarriors = %q, want %q", got, want)
+	}
+
+	// ;redcodes is not a header, but ;redcode-94 is.
+	notHeader := ";redcode\nMOV 0, 1\n;redcodes\n"
+	if got := SplitWarriors(notHeader); len(got) != 1 {
+		t.Fatalf("expected ;redcodes not to split, got %q", got)
+	}
+}
+
+func TestLoaderLoadFSRecursesAndRecordsErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.red":        {Data: []byte(warriorSource("a"))},
+		"broken.red":   {Data: []byte(warriorSource("BROKEN"))},
+		"readme.txt":   {Data: []byte("not a warrior")},
+		"sub/b.RED":    {Data: []byte(warriorSource("b"))},
+		"sub/deep/c.x": {Data: []byte(warriorSource("c"))},
+		"multi.red":    {Data: []byte(warriorSource("m0") + warriorSource("BROKEN") + warriorSource("m2"))},
+	}
+
+	res, err := Loader{Engine: loadTestEngine{}}.LoadFS(fsys)
+	if err != nil {
+		t.Fatalf("LoadFS returned error: %v", err)
+	}
+
+	want := []string{"a.red:a", "multi.red:m0", "multi.red:m2", "sub/b.RED:b"}
+	if got := loadedNames(res); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %v, want %v", got, want)
+	}
+	if res.Warriors[2].Index != 2 || !strings.Contains(res.Warriors[2].Source, ";name m2") {
+		t.Fatalf("unexpected multi-warrior entry: %+v", res.Warriors[2])
+	}
+
+	if len(res.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", res.Errors)
+	}
+	if res.Errors[0].Path != "broken.red" || res.Errors[0].Index != 0 {
+		t.Fatalf("unexpected first error: %+v", res.Errors[0])
+	}
+	if res.Errors[1].Path != "multi.red" || res.Errors[1].Index != 1 {
+		t.Fatalf("unexpected second error: %+v", res.Errors[1])
+	}
+	if !errors.Is(res.Err(), errBroken) {
+		t.Fatalf("expected joined error to wrap errBroken, got %v", res.Err())
+	}
+	if got := res.Errors[1].Error(); got != "multi.red#1: broken warrior" {
+		t.Fatalf("unexpected error text %q", got)
+	}
+
+	res, err = Loader{Engine: loadTestEngine{}, Extensions: []string{".x"}}.LoadFS(fsys)
+	if err != nil {
+		t.Fatalf("LoadFS returned error: %v", err)
+	}
+	if got := loadedNames(res); !reflect.DeepEqual(got, []string{"sub/deep/c.x:c"}) {
+		t.Fatalf("custom extensions loaded %v", got)
+	}
+}
+
+func TestLoaderArchives(t *testing.T) {
+	inner := tarGzBytes(t, map[string]string{
+		"inner/c.red": warriorSource("c"),
+	})
+	data := zipBytes(t, map[string]string{
+		"a.red":         warriorSource("a"),
+		"dir/b.red":     warriorSource("b"),
+		"dir/bad.red":   warriorSource("BROKEN"),
+		"nested.tar.gz": string(inner),
+		"corrupt.zip":   "not a zip",
+	})
+
+	l := Loader{Engine: loadTestEngine{}}
+	res, err := l.LoadZip(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("LoadZip returned error: %v", err)
+	}
+	want := []string{"a.red:a", "dir/b.red:b", "nested.tar.gz/inner/c.red:c"}
+	if got := loadedNames(res); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %v, want %v", got, want)
+	}
+	if len(res.Errors) != 2 || res.Errors[0].Path != "corrupt.zip" || res.Errors[0].Index != -1 ||
+		res.Errors[1].Path != "dir/bad.red" {
+		t.Fatalf("unexpected errors: %v", res.Errors)
+	}
+
+	res, err = l.LoadTarGz(bytes.NewReader(inner))
+	if err != nil {
+		t.Fatalf("LoadTarGz returned error: %v", err)
+	}
+	if got := loadedNames(res); !reflect.DeepEqual(got, []string{"inner/c.red:c"}) {
+		t.Fatalf("LoadTarGz loaded %v", got)
+	}
+
+	if _, err := l.LoadTarGz(strings.NewReader("not gzip")); err == nil {
+		t.Fatalf("expected LoadTarGz to fail on invalid data")
+	}
+}
+
+func TestLoaderLimits(t *testing.T) {
+	// Each level wraps the previous one, alternating zip and tar.gz.
+	level1 := zipBytes(t, map[string]string{"w1.red": warriorSource("w1")})
+	level2 := tarGzBytes(t, map[string]string{"w2.red": warriorSource("w2"), "l1.zip": string(level1)})
+	level3 := zipBytes(t, map[string]string{"w3.red": warriorSource("w3"), "l2.tar.gz": string(level2)})
+
+	l := Loader{Engine: loadTestEngine{}, MaxArchiveDepth: 2}
+	res, err := l.LoadZip(bytes.NewReader(level3), int64(len(level3)))
+	if err != nil {
+		t.Fatalf("LoadZip returned error: %v", err)
+	}
+	want := []string{"l2.tar.gz/w2.red:w2", "w3.red:w3"}
+	if got := loadedNames(res); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %v, want %v", got, want)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Path != "l2.tar.gz/l1.zip" || !strings.Contains(res.Errors[0].Error(), "nested") {
+		t.Fatalf("expected a nesting error, got %v", res.Errors)
+	}
+
+	// A highly compressible entry is cut off at MaxFileSize.
+	l = Loader{Engine: loadTestEngine{}, MaxFileSize: 1024}
+	files := map[string]string{
+		"big.red": ";redcode-94\n" + strings.Repeat(";", 4096) + "\n",
+		"ok.red":  warriorSource("ok"),
+	}
+	zipped, tarred := zipBytes(t, files), tarGzBytes(t, files)
+	for name, load := range map[string]func() (LoadResult, error){
+		"zip":    func() (LoadResult, error) { return l.LoadZip(bytes.NewReader(zipped), int64(len(zipped))) },
+		"tar.gz": func() (LoadResult, error) { return l.LoadTarGz(bytes.NewReader(tarred)) },
+	} {
+		res, err := load()
+		if err != nil {
+			t.Fatalf("%s: load returned error: %v", name, err)
+		}
+		if got := loadedNames(res); !reflect.DeepEqual(got, []string{"ok.red:ok"}) {
+			t.Fatalf("%s: loaded %v", name, got)
+		}
+		if len(res.Errors) != 1 || res.Errors[0].Path != "big.red" || !strings.Contains(res.Errors[0].Error(), "exceeds 1024 bytes") {
+			t.Fatalf("%s: expected a size error, got %v", name, res.Errors)
+		}
+	}
+}
+
+func TestLoaderLoadPath(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("top.red", []byte(warriorSource("top")))
+	write("nested/one.red", []byte(warriorSource("one")))
+	write("nested/pack.zip", zipBytes(t, map[string]string{"two.red": warriorSource("two")}))
+
+	l := Loader{Engine: loadTestEngine{}}
+	res, err := l.Load(dir)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	want := []string{"nested/one.red:one", "nested/pack.zip/two.red:two", "top.red:top"}
+	if got := loadedNames(res); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %v, want %v", got, want)
+	}
+
+	zipPath := filepath.Join(dir, "nested", "pack.zip")
+	res, err = l.Load(zipPath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(res.Warriors) != 1 || res.Warriors[0].Path != filepath.ToSlash(zipPath)+"/two.red" {
+		t.Fatalf("unexpected archive load: %v", loadedNames(res))
+	}
+
+	// A root warrior file is capped like any other.
+	small := Loader{Engine: loadTestEngine{}, MaxFileSize: 8}
+	topPath := filepath.Join(dir, "top.red")
+	res, err = small.Load(topPath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(res.Warriors) != 0 || len(res.Errors) != 1 || res.Errors[0].Path != filepath.ToSlash(topPath) ||
+		!strings.Contains(res.Errors[0].Error(), "exceeds 8 bytes") {
+		t.Fatalf("expected a size error for the root file, got %v %v", loadedNames(res), res.Errors)
+	}
+
+	tarPath := filepath.Join(dir, "pack.tar.gz")
+	write("pack.tar.gz", tarGzBytes(t, map[string]string{"three.red": warriorSource("three")}))
+	res, err = l.Load(tarPath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := loadedNames(res); !reflect.DeepEqual(got, []string{filepath.ToSlash(tarPath) + "/three.red:three"}) {
+		t.Fatalf("unexpected tar.gz load: %v", got)
+	}
+
+	if _, err := l.Load(filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("expected an error for a missing path")
+	}
+}
+
+func TestLoadWarriorsWithLibrary(t *testing.T) {
+	testlib.Configure(t)
+
+	fsys := fstest.MapFS{
+		"pair.red": {Data: []byte(";redcode-94\n;name imp\nMOV 0, 1\nEND\n;redcode-94\n;name dwarf\nADD #4, 3\nMOV 2, @2\nJMP -2\nDAT #0, #0\nEND\n")},
+	}
+	res, err := LoadWarriorsFS(fsys, DefaultConfig)
+	if err != nil {
+		t.Fatalf("LoadWarriorsFS returned error: %v", err)
+	}
+	if res.Err() != nil {
+		t.Fatalf("unexpected load errors: %v", res.Err())
+	}
+	warriors := res.ParsedWarriors()
+	if len(warriors) != 2 || warriors[0].Name != "imp" || warriors[1].Name != "dwarf" || len(warriors[1].Commands) != 4 {
+		t.Fatalf("unexpected warriors: %+v", warriors)
+	}
+}
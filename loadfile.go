@@ -0,0 +1,186 @@
+package goexmars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LoadFile renders w in the pMARS/ICWS'94 load file format: the metadata
+// comments, an ORG line with the start offset, every instruction with its
+// modifier and both operands spelled out, and a closing END.
+//
+// The instructions are laid out like the pMARS listing, so the output can be
+// compared with it line by line. A warrior without a Redcode tag gets a
+// plain ";redcode" line, which pMARS needs to tell it apart from surrounding
+// text.
+func (w ParsedWarrior) LoadFile() string {
+	if w.Redcode == "" {
+		w.Redcode = "redcode"
+	}
+
+	var b strings.Builder
+	w.writeMetadata(&b, RedcodeFormatOptions{IncludeName: true, IncludeAuthor: true, IncludeMetadata: true})
+	fmt.Fprintf(&b, "       ORG      %d\n", w.End)
+	for _, cmd := range w.Commands {
+		fmt.Fprintf(&b, "       %3s%-4s%s%6d, %s%6d\n",
+			cmd.OpCode, "."+cmd.Modifier.String(), cmd.AddressingModeA, cmd.A, cmd.AddressingModeB, cmd.B)
+	}
+	b.WriteString("       END\n")
+	return b.String()
+}
+
+// ParseLoadFile parses a warrior in load file format without assembling it.
+//
+// Every instruction must have a modifier and two operands with explicit
+// addressing modes; whitespace between a mode and its number is optional.
+// Instructions may carry a label as in the pMARS listing, and ORG and END
+// may refer to such a label or give a numeric offset. If both are present,
+// ORG wins like in pMARS. Parsing stops at END, and comments after ';' are
+// ignored apart from the metadata, which is read with ParseMetadata. The
+// "Program ... by ..." header of the pMARS listing fills Name and Author
+// when the source has no ;name or ;author line.
+//
+// The normalized output of Assemble is valid load file input.
+func ParseLoadFile(src string) (ParsedWarrior, error) {
+	w := ParseMetadata(src)
+	labels := map[string]int{}
+	var org, end string
+
+	for i, raw := range strings.Split(src, "\n") {
+		line := raw
+		if j := strings.IndexByte(line, ';'); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "Program" && len(fields) > 1 && strings.HasPrefix(fields[1], "\"") {
+			name, author := parseListingHeader(line)
+			if w.Name == "" {
+				w.Name = name
+			}
+			if w.Author == "" {
+				w.Author = author
+			}
+			continue
+		}
+
+		if !isLoadFileKeyword(fields[0]) {
+			labels[strings.TrimSuffix(fields[0], ":")] = len(w.Commands)
+			fields = fields[1:]
+			if len(fields) == 0 {
+				continue
+			}
+		}
+
+		keyword := strings.ToUpper(fields[0])
+		if keyword == "ORG" || keyword == "END" {
+			if len(fields) > 2 || keyword == "ORG" && len(fields) != 2 {
+				return ParsedWarrior{}, fmt.Errorf("parse load file line %d: %s takes one operand", i+1, keyword)
+			}
+			if len(fields) == 2 {
+				if keyword == "ORG" {
+					org = fields[1]
+				} else {
+					end = fields[1]
+				}
+			}
+			if keyword == "END" {
+				break
+			}
+			continue
+		}
+
+		cmd, err := parseLoadFileInstruction(fields[0], strings.Join(fields[1:], " "))
+		if err != nil {
+			return ParsedWarrior{}, fmt.Errorf("parse load file line %d: %w", i+1, err)
+		}
+		w.Commands = append(w.Commands, cmd)
+	}
+
+	start := org
+	if start == "" {
+		start = end
+	}
+	if start != "" {
+		if n, ok := labels[start]; ok {
+			w.End = n
+		} else if n, err := strconv.Atoi(start); err == nil {
+			w.End = n
+		} else {
+			return ParsedWarrior{}, fmt.Errorf("parse load file: unknown start %q", start)
+		}
+	}
+	return w, nil
+}
+
+// parseLoadFileInstruction parses "OP.MOD" and the operand text "mA, mB".
+func parseLoadFileInstruction(opmod, operands string) (Command, error) {
+	op, mod, ok := strings.Cut(opmod, ".")
+	if !ok {
+		return Command{}, fmt.Errorf("missing opcode modifier: %q", opmod)
+	}
+	var cmd Command
+	if cmd.OpCode, ok = parseOpCode(op); !ok {
+		return Command{}, fmt.Errorf("unknown opcode: %q", op)
+	}
+	if cmd.Modifier, ok = parseModifier(mod); !ok {
+		return Command{}, fmt.Errorf("unknown modifier: %q", mod)
+	}
+
+	a, b, ok := strings.Cut(operands, ",")
+	if !ok {
+		return Command{}, fmt.Errorf("expected two operands: %q", operands)
+	}
+	var err error
+	if cmd.AddressingModeA, cmd.A, err = parseLoadFileOperand(a); err != nil {
+		return Command{}, fmt.Errorf("invalid A operand: %w", err)
+	}
+	if cmd.AddressingModeB, cmd.B, err = parseLoadFileOperand(b); err != nil {
+		return Command{}, fmt.Errorf("invalid B operand: %w", err)
+	}
+	return cmd, nil
+}
+
+func parseLoadFileOperand(s string) (AddressingMode, int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, fmt.Errorf("empty operand")
+	}
+	mode, ok := parseAddressingMode(s[:1])
+	if !ok {
+		return 0, 0, fmt.Errorf("missing addressing mode: %q", s)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(s[1:]))
+	if err != nil {
+		return 0, 0, err
+	}
+	return mode, v, nil
+}
+
+// isLoadFileKeyword reports whether s starts an instruction, ORG or END
+// rather than being a label.
+func isLoadFileKeyword(s string) bool {
+	op, _, _ := strings.Cut(s, ".")
+	switch strings.ToUpper(op) {
+	case "ORG", "END":
+		return true
+	}
+	_, ok := parseOpCode(op)
+	return ok
+}
+
+// parseListingHeader reads the name and author from a pMARS listing header:
+// Program "name" (length n) by "author".
+func parseListingHeader(line string) (name, author string) {
+	parts := strings.Split(line, "\"")
+	if len(parts) >= 2 {
+		name = parts[1]
+	}
+	if len(parts) >= 4 {
+		author = parts[3]
+	}
+	return name, author
+}
@@ -0,0 +1,165 @@
+package goexmars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestLoadFileRoundTrip(t *testing.T) {
+	w := ParsedWarrior{
+		Name:     "Dwarf",
+		Author:   "A. K. Dewdney",
+		Strategy: "Bomb every fourth cell",
+		End:      1,
+		Commands: []Command{
+			{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingImmediate, AddressingModeB: AddressingImmediate},
+			{OpCode: OpCodeADD, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, A: 4, AddressingModeB: AddressingDirect, B: -1},
+			{OpCode: OpCodeMOV, Modifier: ModifierAB, AddressingModeA: AddressingImmediate, AddressingModeB: AddressingBIndirect, B: -2},
+			{OpCode: OpCodeJMP, Modifier: ModifierA, AddressingModeA: AddressingDirect, A: -2, AddressingModeB: AddressingImmediate},
+		},
+	}
+
+	out := w.LoadFile()
+	want := `;redcode
+;name Dwarf
+;author A. K. Dewdney
+;strategy Bomb every fourth cell
+       ORG      1
+       DAT.F  #     0, #     0
+       ADD.AB #     4, $    -1
+       MOV.AB #     0, @    -2
+       JMP.A  $    -2, #     0
+       END
+`
+	if out != want {
+		t.Fatalf("unexpected load file:\n%s\nwant:\n%s", out, want)
+	}
+
+	parsed, err := ParseLoadFile(out)
+	if err != nil {
+		t.Fatalf("ParseLoadFile returned error: %v", err)
+	}
+	w.Redcode = "redcode"
+	if !reflect.DeepEqual(parsed, w) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", parsed, w)
+	}
+}
+
+func TestParseLoadFileICWSExample(t *testing.T) {
+	// The load file example of the ICWS'94 draft.
+	const src = `;redcode
+;name          Dwarf
+;author        A. K. Dewdney
+ORG     1          ; the first instruction executed is at offset 1
+DAT.F   #0, #0     ; Pointer to target instruction.
+ADD.AB  #4, $-1    ; Increments pointer by step.
+MOV.AB  #0, @-2    ; Bombs target instruction.
+JMP.A   $-2, #0    ; Same as JMP.A -2.  Loops back to ADD instruction.
+`
+	w, err := ParseLoadFile(src)
+	if err != nil {
+		t.Fatalf("ParseLoadFile returned error: %v", err)
+	}
+	if w.Name != "Dwarf" || w.Author != "A. K. Dewdney" || w.End != 1 || len(w.Commands) != 4 {
+		t.Fatalf("unexpected warrior: %+v", w)
+	}
+	if got := w.Commands[2].String(); got != "MOV.AB #0, @-2" {
+		t.Fatalf("unexpected third command %q", got)
+	}
+}
+
+func TestParseLoadFileListing(t *testing.T) {
+	const src = `Program "Dwarf" (length 4) by "A. K. Dewdney"
+
+       ORG      START
+       DAT.F  #     0, #     0
+START  ADD.AB #     4, $    -1
+       MOV.AB #     0, @    -2
+       JMP.A  $    -2, #     0
+       END
+       DAT.F  #     0, #     0
+`
+	w, err := ParseLoadFile(src)
+	if err != nil {
+		t.Fatalf("ParseLoadFile returned error: %v", err)
+	}
+	if w.Name != "Dwarf" || w.Author != "A. K. Dewdney" {
+		t.Fatalf("unexpected header fields: %q by %q", w.Name, w.Author)
+	}
+	if w.End != 1 {
+		t.Fatalf("expected ORG START to resolve to 1, got %d", w.End)
+	}
+	if len(w.Commands) != 4 {
+		t.Fatalf("expected parsing to stop at END, got %d commands", len(w.Commands))
+	}
+}
+
+func TestParseLoadFileEndOperandAndErrors(t *testing.T) {
+	w, err := ParseLoadFile("MOV.I $0, $1\nJMP.B $-1, $0\nEND 1\n")
+	if err != nil {
+		t.Fatalf("ParseLoadFile returned error: %v", err)
+	}
+	if w.End != 1 {
+		t.Fatalf("expected END operand to set the start, got %d", w.End)
+	}
+
+	cases := map[string]string{
+		"MOV 0, 1\n":          "missing opcode modifier",
+		"MOV.I $0\n":          "expected two operands",
+		"MOV.I 0, $1\n":       "missing addressing mode",
+		"MOV.Q $0, $1\n":      "unknown modifier",
+		"ORG\nMOV.I $0, $1\n": "ORG takes one operand",
+		"ORG nowhere\n":       "unknown start",
+	}
+	for src, want := range cases {
+		if _, err := ParseLoadFile(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseLoadFile(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestParseLoadFileMatchesAssemble(t *testing.T) {
+	testlib.Configure(t)
+
+	const src = `;redcode-94
+;name Dwarf
+;author A. K. Dewdney
+step DAT #0, #0
+start ADD #4, step
+MOV step, @step
+JMP start
+END start
+`
+	parsed, err := AssembleParsed(src, DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed returned error: %v", err)
+	}
+
+	fromListing, err := ParseLoadFile(parsed.Assembled)
+	if err != nil {
+		t.Fatalf("ParseLoadFile(Assembled) returned error: %v", err)
+	}
+	if fromListing.End != parsed.End || !reflect.DeepEqual(fromListing.Commands, parsed.Commands) {
+		t.Fatalf("assembled listing parsed as %+v, want %+v", fromListing, parsed)
+	}
+
+	loaded, err := ParseLoadFile(parsed.LoadFile())
+	if err != nil {
+		t.Fatalf("ParseLoadFile(LoadFile) returned error: %v", err)
+	}
+	if loaded.Name != parsed.Name || loaded.End != parsed.End || !reflect.DeepEqual(loaded.Commands, parsed.Commands) {
+		t.Fatalf("load file parsed as %+v, want %+v", loaded, parsed)
+	}
+
+	// The load file is valid Redcode and assembles to the same warrior.
+	reassembled, err := AssembleParsed(parsed.LoadFile(), DefaultConfig)
+	if err != nil {
+		t.Fatalf("AssembleParsed(LoadFile) returned error: %v", err)
+	}
+	if reassembled.End != parsed.End || !reflect.DeepEqual(reassembled.Commands, parsed.Commands) {
+		t.Fatalf("reassembled load file as %+v, want %+v", reassembled.Commands, parsed.Commands)
+	}
+}
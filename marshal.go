@@ -0,0 +1,150 @@
+package goexmars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The enum types marshal to the mnemonics returned by their String methods,
+// so they appear as strings in JSON and other text formats. Unmarshalling
+// opcodes and modifiers is case-insensitive. Values outside the defined range
+// fail to marshal instead of silently turning into the String fallback.
+//
+// Together with the json tags on the struct types this gives the following
+// stable JSON schema:
+//
+//	ParsedWarrior  {"name": string, "author": string, "end": int,
+//	                "commands": [Command], "assembled"?: string,
+//	                "strategy"?: string, "version"?: string,
+//	                "date"?: string, "url"?: string, "redcode"?: string,
+//	                "asserts"?: [string], "extra"?: [MetadataField]}
+//	MetadataField  {"key": string, "value": string}
+//	Command        {"opcode": "MOV", "modifier": "I",
+//	                "a_mode": "$", "a": int, "b_mode": "$", "b": int}
+//	FightResult    {"wins": [int], "ties": int,
+//	                "diagnostics"?: string, "diagnostic_list"?: [Diagnostic]}
+//	Diagnostic     {"severity": "warning"|"error", "warrior": int,
+//	                "line": int, "column": int, "code": DiagnosticCode,
+//	                "message": string}
+//	EditOp         {"kind": "keep"|"substitute"|"insert"|"delete",
+//	                "a_index": int, "b_index": int,
+//	                "from": Command, "to": Command, "cost": number}
+//	TraceStep      {"warrior": int, "address": int, "command": Command}
+//	Issue          {"severity": "warning"|"error", "code": IssueCode,
+//	                "index": int, "message": string}
+//
+// Fields marked with ? are omitted when empty. Opcodes are the upper-case
+// mnemonics (CMP and SEQ are distinct), modifiers are F, A, B, AB, BA, X and
+// I, addressing modes are the single-character prefixes # $ * @ { < } > and
+// diagnostic and issue codes are the kebab-case names returned by String.
+
+// MarshalText implements encoding.TextMarshaler.
+func (o OpCode) MarshalText() ([]byte, error) {
+	if int(o) >= OpCodeCount {
+		return nil, fmt.Errorf("invalid opcode %d", o)
+	}
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (o *OpCode) UnmarshalText(text []byte) error {
+	v, ok := parseOpCode(string(text))
+	if !ok {
+		return fmt.Errorf("unknown opcode: %q", text)
+	}
+	*o = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Modifier) MarshalText() ([]byte, error) {
+	if int(m) >= ModifierCount {
+		return nil, fmt.Errorf("invalid modifier %d", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Modifier) UnmarshalText(text []byte) error {
+	v, ok := parseModifier(string(text))
+	if !ok {
+		return fmt.Errorf("unknown modifier: %q", text)
+	}
+	*m = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m AddressingMode) MarshalText() ([]byte, error) {
+	if int(m) >= AddressingModeCount {
+		return nil, fmt.Errorf("invalid addressing mode %d", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *AddressingMode) UnmarshalText(text []byte) error {
+	v, ok := parseAddressingMode(string(text))
+	if !ok {
+		return fmt.Errorf("unknown addressing mode: %q", text)
+	}
+	*m = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k EditKind) MarshalText() ([]byte, error) {
+	if k > EditDelete {
+		return nil, fmt.Errorf("invalid edit kind %d", k)
+	}
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *EditKind) UnmarshalText(text []byte) error {
+	for v := EditKeep; v <= EditDelete; v++ {
+		if strings.EqualFold(string(text), v.String()) {
+			*k = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown edit kind: %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s > SeverityError {
+		return nil, fmt.Errorf("invalid severity %d", s)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for v := SeverityWarning; v <= SeverityError; v++ {
+		if strings.EqualFold(string(text), v.String()) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity: %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c DiagnosticCode) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(diagnosticCodeNames) {
+		return nil, fmt.Errorf("invalid diagnostic code %d", c)
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *DiagnosticCode) UnmarshalText(text []byte) error {
+	for v, name := range diagnosticCodeNames {
+		if string(text) == name {
+			*c = DiagnosticCode(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown diagnostic code: %q", text)
+}
@@ -0,0 +1,145 @@
+package goexmars
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEnumTextRoundTrip(t *testing.T) {
+	for op := 0; op < OpCodeCount; op++ {
+		text, err := OpCode(op).MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got OpCode
+		if err := got.UnmarshalText(text); err != nil || got != OpCode(op) {
+			t.Fatalf("opcode %s: got %v, %v", text, got, err)
+		}
+	}
+	for mod := 0; mod < ModifierCount; mod++ {
+		text, err := Modifier(mod).MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Modifier
+		if err := got.UnmarshalText(text); err != nil || got != Modifier(mod) {
+			t.Fatalf("modifier %s: got %v, %v", text, got, err)
+		}
+	}
+	for mode := 0; mode < AddressingModeCount; mode++ {
+		text, err := AddressingMode(mode).MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got AddressingMode
+		if err := got.UnmarshalText(text); err != nil || got != AddressingMode(mode) {
+			t.Fatalf("addressing mode %s: got %v, %v", text, got, err)
+		}
+	}
+	for code := DiagBufferOverflow; code <= DiagMisc; code++ {
+		text, err := code.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got DiagnosticCode
+		if err := got.UnmarshalText(text); err != nil || got != code {
+			t.Fatalf("diagnostic code %s: got %v, %v", text, got, err)
+		}
+	}
+}
+
+func TestEnumTextInvalid(t *testing.T) {
+	if _, err := OpCode(OpCodeCount).MarshalText(); err == nil {
+		t.Error("expected error for invalid opcode")
+	}
+	if _, err := Modifier(ModifierCount).MarshalText(); err == nil {
+		t.Error("expected error for invalid modifier")
+	}
+	if _, err := AddressingMode(AddressingModeCount).MarshalText(); err == nil {
+		t.Error("expected error for invalid addressing mode")
+	}
+	if _, err := EditKind(99).MarshalText(); err == nil {
+		t.Error("expected error for invalid edit kind")
+	}
+
+	var op OpCode
+	if err := op.UnmarshalText([]byte("XYZ")); err == nil {
+		t.Error("expected error for unknown opcode")
+	}
+	if err := op.UnmarshalText([]byte("mov")); err != nil || op != OpCodeMOV {
+		t.Errorf("expected lower-case opcode to parse, got %v, %v", op, err)
+	}
+}
+
+func TestParsedWarriorJSON(t *testing.T) {
+	w := ParsedWarrior{
+		Name:   "Imp",
+		Author: "A. K. Dewdney",
+		End:    0,
+		Commands: []Command{
+			{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: 1},
+		},
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"name":"Imp","author":"A. K. Dewdney","end":0,"commands":[{"opcode":"MOV","modifier":"I","a_mode":"$","a":0,"b_mode":"$","b":1}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	var got ParsedWarrior
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, w) {
+		t.Fatalf("round trip: got %+v, want %+v", got, w)
+	}
+
+	if err := json.Unmarshal([]byte(`{"commands":[{"opcode":"FOO"}]}`), &got); err == nil {
+		t.Fatal("expected error for unknown opcode")
+	}
+}
+
+func TestFightResultJSON(t *testing.T) {
+	res := FightResult{
+		Wins: []int{3, 1},
+		Ties: 2,
+		DiagnosticList: []Diagnostic{
+			{Severity: SeverityWarning, Warrior: 1, Line: 4, Column: 2, Code: DiagDiscardedLabels, Message: "labels discarded"},
+		},
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"wins":[3,1],"ties":2,"diagnostic_list":[{"severity":"warning","warrior":1,"line":4,"column":2,"code":"discarded-labels","message":"labels discarded"}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	var got FightResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, res) {
+		t.Fatalf("round trip: got %+v, want %+v", got, res)
+	}
+}
+
+func TestEditOpJSON(t *testing.T) {
+	op := EditOp{Kind: EditSubstitute, AIndex: 1, BIndex: 2, Cost: 0.5}
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got EditOp
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != op {
+		t.Fatalf("round trip: got %+v, want %+v", got, op)
+	}
+}
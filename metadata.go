@@ -0,0 +1,142 @@
+package goexmars
+
+import "strings"
+
+// MetadataField is a ";key value" comment line without a dedicated
+// ParsedWarrior field.
+type MetadataField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ParseMetadata reads the comment metadata of a Redcode source and returns
+// a ParsedWarrior with only the metadata fields set.
+//
+// Known keys (name, author, strategy, version, date, url, redcode and
+// assert) are matched case-insensitively anywhere in the source. For name,
+// author, version, date and url the last line wins; strategy and assert lines
+// accumulate. Only the first ;redcode line counts, like in pMARS. Other
+// ";key value" lines are kept in Extra if they appear before the first
+// instruction and the key directly follows the ';', so ordinary comments
+// ("; bomb step") and commented-out code further down are not picked up.
+func ParseMetadata(src string) ParsedWarrior {
+	var w ParsedWarrior
+	var strategy []string
+	header := true
+	for _, raw := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if line[0] != ';' {
+			header = false
+			continue
+		}
+
+		if isRedcodeLine(line) {
+			if w.Redcode == "" {
+				w.Redcode = strings.TrimSpace(line[1:])
+			}
+			continue
+		}
+
+		key, value := splitMetadataLine(line[1:])
+		switch strings.ToLower(key) {
+		case "name":
+			w.Name = value
+		case "author":
+			w.Author = value
+		case "strategy":
+			strategy = append(strategy, value)
+		case "version":
+			w.Version = value
+		case "date":
+			w.Date = value
+		case "url":
+			w.URL = value
+		case "assert":
+			if i := strings.IndexByte(value, ';'); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			w.Asserts = append(w.Asserts, value)
+		default:
+			if header && key != "" {
+				w.Extra = append(w.Extra, MetadataField{Key: key, Value: value})
+			}
+		}
+	}
+	w.Strategy = strings.Join(strategy, "\n")
+	return w
+}
+
+// splitMetadataLine splits the text after a ';' into a key and a value. The
+// key is empty unless the text starts with a letter.
+func splitMetadataLine(s string) (key, value string) {
+	end := 0
+	for end < len(s) && isMetadataKeyChar(s[end], end == 0) {
+		end++
+	}
+	if end == 0 || (end < len(s) && s[end] != ' ' && s[end] != '\t') {
+		return "", strings.TrimSpace(s)
+	}
+	return s[:end], strings.TrimSpace(s[end:])
+}
+
+func isMetadataKeyChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case first:
+		return false
+	default:
+		return c >= '0' && c <= '9' || c == '-' || c == '_'
+	}
+}
+
+// writeMetadata writes the metadata of w selected by opts as comment lines.
+func (w ParsedWarrior) writeMetadata(b *strings.Builder, opts RedcodeFormatOptions) {
+	line := func(key, value string) {
+		b.WriteByte(';')
+		b.WriteString(key)
+		if value != "" {
+			b.WriteByte(' ')
+			b.WriteString(value)
+		}
+		b.WriteByte('\n')
+	}
+
+	if opts.IncludeMetadata && w.Redcode != "" {
+		b.WriteByte(';')
+		b.WriteString(w.Redcode)
+		b.WriteByte('\n')
+	}
+	if opts.IncludeName && w.Name != "" {
+		line("name", w.Name)
+	}
+	if opts.IncludeAuthor && w.Author != "" {
+		line("author", w.Author)
+	}
+	if !opts.IncludeMetadata {
+		return
+	}
+	if w.Version != "" {
+		line("version", w.Version)
+	}
+	if w.Date != "" {
+		line("date", w.Date)
+	}
+	if w.URL != "" {
+		line("url", w.URL)
+	}
+	if w.Strategy != "" {
+		for _, s := range strings.Split(w.Strategy, "\n") {
+			line("strategy", s)
+		}
+	}
+	for _, a := range w.Asserts {
+		line("assert", a)
+	}
+	for _, f := range w.Extra {
+		line(f.Key, f.Value)
+	}
+}
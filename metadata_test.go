@@ -0,0 +1,114 @@
+package goexmars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+const metadataSource = `;redcode-94nop verbose
+;name Four Winds
+;author John Metcalf
+;version 2
+;date 2026-01-02
+;url https://example.com/four-winds
+;strategy oneshot
+;strategy with a clear
+;assert CORESIZE == 8000 ; hill size
+;hill 94nop
+;planar
+; this is a comment
+;NAME Four Winds II
+
+step equ 694
+;assert MAXLENGTH >= 10
+;kill Four Winds
+     mov.i 0, step
+     end
+`
+
+func TestParseMetadata(t *testing.T) {
+	got := ParseMetadata(metadataSource)
+	want := ParsedWarrior{
+		Name:     "Four Winds II",
+		Author:   "John Metcalf",
+		Version:  "2",
+		Date:     "2026-01-02",
+		URL:      "https://example.com/four-winds",
+		Strategy: "oneshot\nwith a clear",
+		Redcode:  "redcode-94nop verbose",
+		Asserts:  []string{"CORESIZE == 8000", "MAXLENGTH >= 10"},
+		Extra: []MetadataField{
+			{Key: "hill", Value: "94nop"},
+			{Key: "planar"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFormatWritesMetadata(t *testing.T) {
+	w := ParseMetadata(metadataSource)
+	w.Commands = []Command{{OpCode: OpCodeMOV, Modifier: ModifierI, AddressingModeA: AddressingDirect, A: 0, AddressingModeB: AddressingDirect, B: 694}}
+
+	opts := DefaultRedcodeFormatOptions()
+	opts.IncludeMetadata = true
+	out := w.Format(opts)
+	want := strings.Join([]string{
+		";redcode-94nop verbose",
+		";name Four Winds II",
+		";author John Metcalf",
+		";version 2",
+		";date 2026-01-02",
+		";url https://example.com/four-winds",
+		";strategy oneshot",
+		";strategy with a clear",
+		";assert CORESIZE == 8000",
+		";assert MAXLENGTH >= 10",
+		";hill 94nop",
+		";planar",
+		"MOV.I $0, $694",
+		"END 0",
+	}, "\n")
+	if out != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	back := ParseMetadata(out)
+	back.Commands = w.Commands
+	if !reflect.DeepEqual(back, w) {
+		t.Fatalf("metadata did not survive Format:\n got %+v\nwant %+v", back, w)
+	}
+
+	// String keeps its output from before metadata support: only the name,
+	// author, instructions and END.
+	if got, want := w.String(), ";name Four Winds II\n;author John Metcalf\nMOV.I $0, $694\nEND 0"; got != want {
+		t.Fatalf("unexpected String output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAssembleParsedMetadata(t *testing.T) {
+	testlib.Configure(t)
+
+	w, err := AssembleParsed(metadataSource, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Strategy != "oneshot\nwith a clear" || w.Redcode != "redcode-94nop verbose" || len(w.Asserts) != 2 {
+		t.Fatalf("metadata not parsed: %+v", w)
+	}
+
+	opts := DefaultRedcodeFormatOptions()
+	opts.IncludeMetadata = true
+	again, err := AssembleParsed(w.Format(opts), DefaultConfig)
+	if err != nil {
+		t.Fatalf("reassemble:\n%s\n%v", w.Format(opts), err)
+	}
+	again.Assembled = w.Assembled
+	if !reflect.DeepEqual(again, w) {
+		t.Fatalf("round trip through Format differs:\n got %+v\nwant %+v", again, w)
+	}
+}
@@ -72,12 +72,12 @@ const AddressingModeCount = int(AddressingBIndirectPost) + 1
 
 // Command is a single normalized Redcode instruction.
 type Command struct {
-	OpCode          OpCode
-	Modifier        Modifier
-	AddressingModeA AddressingMode
-	A               int
-	AddressingModeB AddressingMode
-	B               int
+	OpCode          OpCode         `json:"opcode"`
+	Modifier        Modifier       `json:"modifier"`
+	AddressingModeA AddressingMode `json:"a_mode"`
+	A               int            `json:"a"`
+	AddressingModeB AddressingMode `json:"b_mode"`
+	B               int            `json:"b"`
 }
 
 // String renders the command as normalized Redcode.
@@ -95,11 +95,24 @@ func (c Command) String() string {
 
 // ParsedWarrior is a structured representation of an assembled warrior.
 type ParsedWarrior struct {
-	Name      string
-	Author    string
-	End       int
-	Commands  []Command
-	Assembled string
+	Name      string    `json:"name"`
+	Author    string    `json:"author"`
+	End       int       `json:"end"`
+	Commands  []Command `json:"commands"`
+	Assembled string    `json:"assembled,omitempty"`
+
+	// Strategy holds the ;strategy lines joined by newlines.
+	Strategy string `json:"strategy,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Date     string `json:"date,omitempty"`
+	URL      string `json:"url,omitempty"`
+	// Redcode is the hill tag line without the leading ';', e.g.
+	// "redcode-94" or "redcode-94nop".
+	Redcode string `json:"redcode,omitempty"`
+	// Asserts holds the ;assert expressions in source order.
+	Asserts []string `json:"asserts,omitempty"`
+	// Extra holds other ";key value" header lines in source order.
+	Extra []MetadataField `json:"extra,omitempty"`
 }
 
 // RedcodeFormatOptions controls how a ParsedWarrior is rendered back to Redcode text.
@@ -107,6 +120,26 @@ type RedcodeFormatOptions struct {
 	IncludeName   bool
 	IncludeAuthor bool
 	IncludeEnd    bool
+	// IncludeMetadata writes the remaining metadata fields (redcode tag,
+	// version, date, url, strategy, asserts and extra fields). It is off by
+	// default because assembling the output again re-evaluates the ;assert
+	// lines, which may not hold under a different config.
+	IncludeMetadata bool
+
+	// Decompile renders readable source instead of the normalized listing:
+	// operands that refer to another instruction of the warrior become
+	// generated labels (l<index>), the start is given by an ORG pointing at
+	// its label, and constants reaching beyond the code become EQUs (c1, c2,
+	// ...). The output assembles back to the same commands.
+	Decompile bool
+	// CoreSize wraps operand targets modulo the core size when looking for
+	// references, as needed for commands taken from a core or an Insn. Zero
+	// disables wrapping.
+	CoreSize int
+	// ShortOffsets prints operands that are not labels as the shorter of
+	// their positive and negative offset modulo CoreSize. It requires
+	// CoreSize.
+	ShortOffsets bool
 }
 
 // DefaultRedcodeFormatOptions returns the default Redcode rendering options.
@@ -125,17 +158,11 @@ func (w ParsedWarrior) String() string {
 
 // Format renders the parsed warrior to Redcode text using the provided options.
 func (w ParsedWarrior) Format(opts RedcodeFormatOptions) string {
-	var b strings.Builder
-	if opts.IncludeName && w.Name != "" {
-		b.WriteString(";name ")
-		b.WriteString(w.Name)
-		b.WriteByte('\n')
-	}
-	if opts.IncludeAuthor && w.Author != "" {
-		b.WriteString(";author ")
-		b.WriteString(w.Author)
-		b.WriteByte('\n')
+	if opts.Decompile {
+		return w.decompile(opts)
 	}
+	var b strings.Builder
+	w.writeMetadata(&b, opts)
 	for _, cmd := range w.Commands {
 		b.WriteString(cmd.String())
 		b.WriteByte('\n')
@@ -149,13 +176,34 @@ func (w ParsedWarrior) Format(opts RedcodeFormatOptions) string {
 
 // AssembleParsed assembles warrior and parses the normalized result into a Go struct.
 //
-// Name and Author are parsed from the original source if present.
+// Name, Author and the other metadata fields are parsed from the original
+// source with ParseMetadata.
 // End and Commands are parsed from the normalized assembled Redcode returned by Assemble.
 func AssembleParsed(warrior string, cfg FightConfig) (ParsedWarrior, error) {
 	assembled, err := Assemble(warrior, cfg)
 	if err != nil {
 		return ParsedWarrior{}, err
 	}
+	return parseAssembled(warrior, assembled)
+}
+
+// AssembleParsed is the package-level AssembleParsed using l.
+func (l *Library) AssembleParsed(warrior string, cfg FightConfig) (ParsedWarrior, error) {
+	return AssembleParsedWithEngine(l, warrior, cfg)
+}
+
+// AssembleParsedWithEngine is AssembleParsed assembling with e.
+func AssembleParsedWithEngine(e Engine, warrior string, cfg FightConfig) (ParsedWarrior, error) {
+	assembled, err := e.Assemble(warrior, cfg)
+	if err != nil {
+		return ParsedWarrior{}, err
+	}
+	return parseAssembled(warrior, assembled)
+}
+
+// parseAssembled builds a ParsedWarrior from the original source and its
+// normalized listing.
+func parseAssembled(warrior, assembled string) (ParsedWarrior, error) {
 	cmds, err := ParseAssembledCommands(assembled)
 	if err != nil {
 		return ParsedWarrior{}, err
@@ -164,14 +212,11 @@ func AssembleParsed(warrior string, cfg FightConfig) (ParsedWarrior, error) {
 	if err != nil {
 		return ParsedWarrior{}, err
 	}
-	name, author := parseWarriorMetadata(warrior)
-	return ParsedWarrior{
-		Name:      name,
-		Author:    author,
-		End:       end,
-		Commands:  cmds,
-		Assembled: assembled,
-	}, nil
+	w := ParseMetadata(warrior)
+	w.End = end
+	w.Commands = cmds
+	w.Assembled = assembled
+	return w, nil
 }
 
 // ParseAssembledCommands parses normalized assembled Redcode instructions into commands.
@@ -444,25 +489,3 @@ func parseAssembledEnd(assembled string) (int, error) {
 	}
 	return 0, fmt.Errorf("assembled END line not found")
 }
-
-func parseWarriorMetadata(src string) (name string, author string) {
-	for _, raw := range strings.Split(src, "\n") {
-		line := strings.TrimSpace(raw)
-		if line == "" {
-			continue
-		}
-		lower := strings.ToLower(line)
-		if strings.HasPrefix(lower, ";name") {
-			name = strings.TrimSpace(line[len(";name"):])
-			continue
-		}
-		if strings.HasPrefix(lower, ";author") {
-			author = strings.TrimSpace(line[len(";author"):])
-			continue
-		}
-		if strings.HasPrefix(strings.ToUpper(line), "END") {
-			continue
-		}
-	}
-	return name, author
-}
@@ -1,12 +1,15 @@
 package goexmars
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
 )
 
 func TestAssembleValidWarrior(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const imp = `
 ;redcode-94x
@@ -46,7 +49,7 @@ clr: spl   #-101,          16
 }
 
 func TestAssembleMalformedWarrior(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const malformed = `
 ;redcode-94
@@ -68,7 +71,7 @@ END
 }
 
 func TestAssembleParsedIncludesMetadataAndCommands(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const warrior = `
 ;redcode-94
@@ -189,7 +192,7 @@ func TestParsedWarriorFormatOptions(t *testing.T) {
 }
 
 func TestAssembleNormalizedOptions(t *testing.T) {
-	configureTestLibraryPath(t)
+	testlib.Configure(t)
 
 	const warrior = `
 ;redcode-94
@@ -232,3 +235,36 @@ func TestParsedWarriorFingerprint(t *testing.T) {
 		t.Fatalf("expected metadata-inclusive fingerprint to differ")
 	}
 }
+
+func TestAssembleParsedLargeWarriorRoundTrip(t *testing.T) {
+	testlib.Configure(t)
+
+	cfg := DefaultConfig
+	cfg.MaxWarriorLen = 1000
+	cfg.MinSep = 1000
+
+	var src strings.Builder
+	src.WriteString(";redcode-94\n;name Big\n")
+	for i := 0; i < cfg.MaxWarriorLen; i++ {
+		fmt.Fprintf(&src, "SPL.B #%d, <-%d\n", 1000+i, 2000+i)
+	}
+	src.WriteString("END 7\n")
+
+	parsed, err := AssembleParsed(src.String(), cfg)
+	if err != nil {
+		t.Fatalf("AssembleParsed failed: %v", err)
+	}
+	if len(parsed.Assembled) <= assembleBufferSize {
+		t.Fatalf("expected listing larger than the initial buffer, got %d bytes", len(parsed.Assembled))
+	}
+	if got := len(parsed.Commands); got != cfg.MaxWarriorLen {
+		t.Fatalf("expected %d commands, got %d", cfg.MaxWarriorLen, got)
+	}
+	last := parsed.Commands[len(parsed.Commands)-1]
+	if last.A != 1000+cfg.MaxWarriorLen-1 || last.B != -(2000+cfg.MaxWarriorLen-1) {
+		t.Fatalf("unexpected last command: %s", last)
+	}
+	if parsed.End != 7 {
+		t.Fatalf("expected END 7, got %d", parsed.End)
+	}
+}
@@ -0,0 +1,182 @@
+package goexmars
+
+// semanticForm returns the canonical form of w with rewrites that do not
+// change how w behaves in a fight:
+//
+//   - the tail after the last instruction execution can reach or a
+//     reachable instruction's operand refers to is dropped;
+//   - DATs no operand refers to become DAT.F $0, $0 unless their operands
+//     have side effects, since nothing reads them and executing any DAT only
+//     kills the process;
+//   - SEQ, SNE and SLT with two immediate operands and a modifier of .A,
+//     .B, .F or .I compare fields of the instruction with themselves, so
+//     the modifier becomes .F; .AB, .BA and .X compare the A field with the
+//     B field and are kept. MOV with two immediate operands and a modifier
+//     other than .X or .AB/.BA copies the instruction onto itself and
+//     becomes MOV.I.
+//
+// Reachability and references are approximated from direct operands and one
+// level of indirection. When an indirect jump makes the control flow
+// unknown, or End lies outside the code, every instruction counts as
+// reachable. Increment and decrement
+// modes move pointers by more than that approximation follows, so when any
+// operand uses one the tail and the DAT contents are kept.
+func (w ParsedWarrior) semanticForm(coreSize int) ParsedWarrior {
+	w = w.Canonicalize(coreSize)
+	n := len(w.Commands)
+	if n == 0 {
+		return w
+	}
+
+	reachable := reachableCommands(w)
+	referenced := referencedCommands(w, reachable)
+	walks := usesIncDec(w)
+
+	if !walks {
+		last := -1
+		for i := n - 1; i >= 0; i-- {
+			if reachable[i] || referenced[i] {
+				last = i
+				break
+			}
+		}
+		w.Commands = w.Commands[:last+1]
+	}
+
+	for i, cmd := range w.Commands {
+		switch cmd.OpCode {
+		case OpCodeDAT:
+			if !walks && !referenced[i] && !hasSideEffect(cmd.AddressingModeA) && !hasSideEffect(cmd.AddressingModeB) {
+				w.Commands[i] = Command{OpCode: OpCodeDAT, Modifier: ModifierF, AddressingModeA: AddressingDirect, AddressingModeB: AddressingDirect}
+			}
+		case OpCodeSEQ, OpCodeSNE, OpCodeSLT:
+			if cmd.AddressingModeA == AddressingImmediate && cmd.AddressingModeB == AddressingImmediate {
+				switch cmd.Modifier {
+				case ModifierA, ModifierB, ModifierI:
+					w.Commands[i].Modifier = ModifierF
+				}
+			}
+		case OpCodeMOV:
+			if cmd.AddressingModeA == AddressingImmediate && cmd.AddressingModeB == AddressingImmediate {
+				switch cmd.Modifier {
+				case ModifierA, ModifierB, ModifierF:
+					w.Commands[i].Modifier = ModifierI
+				}
+			}
+		}
+	}
+	return w
+}
+
+// reachableCommands marks the instructions execution can reach from End.
+// If End is outside the code, execution starts in core the analysis does not
+// see, so every instruction counts as reachable.
+func reachableCommands(w ParsedWarrior) []bool {
+	n := len(w.Commands)
+	reachable := make([]bool, n)
+	all := func() []bool {
+		for i := range reachable {
+			reachable[i] = true
+		}
+		return reachable
+	}
+	if w.End < 0 || w.End >= n {
+		return all()
+	}
+
+	stack := []int{w.End}
+	push := func(i int) {
+		if i >= 0 && i < n && !reachable[i] {
+			stack = append(stack, i)
+		}
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i < 0 || i >= n || reachable[i] {
+			continue
+		}
+		reachable[i] = true
+
+		cmd := w.Commands[i]
+		jumps := isJump(cmd.OpCode) || cmd.OpCode == OpCodeSPL
+		if jumps {
+			switch cmd.AddressingModeA {
+			case AddressingDirect:
+				push(i + cmd.A)
+			case AddressingImmediate:
+				push(i)
+			default:
+				return all()
+			}
+		}
+		switch cmd.OpCode {
+		case OpCodeDAT, OpCodeJMP:
+		case OpCodeSEQ, OpCodeSNE, OpCodeSLT:
+			push(i + 1)
+			push(i + 2)
+		default:
+			push(i + 1)
+		}
+	}
+	return reachable
+}
+
+// referencedCommands marks the instructions an operand of a reachable
+// instruction refers to, directly or through one level of indirection.
+func referencedCommands(w ParsedWarrior, reachable []bool) []bool {
+	n := len(w.Commands)
+	referenced := make([]bool, n)
+	// An instruction referring to itself only matters if it executes, which
+	// reachability covers.
+	mark := func(from, i int) {
+		if i != from && i >= 0 && i < n {
+			referenced[i] = true
+		}
+	}
+	operand := func(i int, mode AddressingMode, v int) {
+		if mode == AddressingImmediate {
+			return
+		}
+		t := i + v
+		mark(i, t)
+		if mode == AddressingDirect || t < 0 || t >= n {
+			return
+		}
+		switch mode {
+		case AddressingAIndirect, AddressingAIndirectPre, AddressingAIndirectPost:
+			mark(i, t+w.Commands[t].A)
+		default:
+			mark(i, t+w.Commands[t].B)
+		}
+	}
+	for i, cmd := range w.Commands {
+		if !reachable[i] {
+			continue
+		}
+		// A DAT's operands are only evaluated for their side effects.
+		if cmd.OpCode == OpCodeDAT && !hasSideEffect(cmd.AddressingModeA) && !hasSideEffect(cmd.AddressingModeB) {
+			continue
+		}
+		operand(i, cmd.AddressingModeA, cmd.A)
+		operand(i, cmd.AddressingModeB, cmd.B)
+	}
+	return referenced
+}
+
+// usesIncDec reports whether any operand of w uses an increment or
+// decrement mode.
+func usesIncDec(w ParsedWarrior) bool {
+	for _, cmd := range w.Commands {
+		if isIncDec(cmd.AddressingModeA) || isIncDec(cmd.AddressingModeB) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSideEffect reports whether evaluating an operand with mode m changes
+// the core.
+func hasSideEffect(m AddressingMode) bool {
+	return isIncDec(m)
+}
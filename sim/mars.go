@@ -0,0 +1,367 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BigJk/goexmars"
+)
+
+// Opcodes in exhaust's encoding (insn.h). DAT and SPL must be 0 and 1.
+const (
+	exDAT = iota
+	exSPL
+	exMOV
+	exDJN
+	exADD
+	exJMZ
+	exSUB
+	exSEQ
+	exSNE
+	exSLT
+	exJMN
+	exJMP
+	exNOP
+	exMUL
+	exMOD
+	exDIV
+	exLDP
+	exSTP
+)
+
+// Modifiers in exhaust's encoding, which matches goexmars.Modifier.
+const (
+	mF = iota
+	mA
+	mB
+	mAB
+	mBA
+	mX
+	mI
+)
+
+// Addressing modes in exhaust's encoding.
+const (
+	exDirect = iota
+	exImmediate
+	exBIndirect
+	exBPreDec
+	exBPostInc
+	exAIndirect
+	exAPreDec
+	exAPostInc
+)
+
+const (
+	modeBits = 3
+	modeMask = 1<<modeBits - 1
+	// opModShift is the position of the opcode and modifier in insn.in.
+	opModShift = 2 * modeBits
+)
+
+// insn is a core cell. in packs opcode, modifier, b-mode and a-mode like
+// exhaust does, so MOV.I and the .I comparisons can copy and compare it whole.
+type insn struct {
+	a, b uint32
+	in   uint32
+}
+
+func encode(cmd goexmars.Command, coreSize int) insn {
+	in := cmd.Insn(coreSize)
+	return insn{a: in.A, b: in.B, in: in.In}
+}
+
+// decode converts a core cell back into a Command. Cells only ever hold
+// encoded commands, so decoding cannot fail.
+func decode(in insn) goexmars.Command {
+	cmd, _ := goexmars.CommandFromInsn(goexmars.Insn{A: in.a, B: in.b, In: in.in})
+	return cmd
+}
+
+// mods reduces x into [0, m) like the MODS macro.
+func mods(x, m int) uint32 {
+	x %= m
+	if x < 0 {
+		x += m
+	}
+	return uint32(x)
+}
+
+// pspace is a warrior's p-space. Location 0 holds the last round's result.
+type pspace struct {
+	mem        []uint32
+	lastResult uint32
+}
+
+func (p *pspace) get(addr uint32) uint32 {
+	if addr == 0 {
+		return p.lastResult
+	}
+	return p.mem[addr]
+}
+
+func (p *pspace) set(addr, v uint32) {
+	if addr == 0 {
+		p.lastResult = v
+	} else {
+		p.mem[addr] = v
+	}
+}
+
+type warrior struct {
+	code  []insn
+	start uint32
+}
+
+// proc is the process queue state of a warrior during a round (w_t).
+type proc struct {
+	head, tail int
+	nprocs     uint64
+	succ, pred *proc
+	id         int
+}
+
+// mars holds the buffers for one fight. It mirrors the parts of mars_t that
+// the simulator and the round loop in pmars.c use.
+type mars struct {
+	coreSize   uint32
+	cycles     uint64
+	processes  uint64
+	rounds     int
+	minSep     uint64
+	pspaceSize uint32
+	fixPos     int
+
+	warriors []warrior
+	core     []insn
+	queue    []uint32
+	procs    []proc
+	deaths   []int
+
+	positions      []uint64
+	startPositions []uint32
+	pspaces        []*pspace // by warrior
+	slotPSpaces    []*pspace // by execution slot for the current round
+	results        [][]int
+
+	// traceRound is the round recorded into trace, or -1.
+	traceRound int
+	tracing    bool
+	trace      []step
+}
+
+// step is an instruction executed while tracing.
+type step struct {
+	slot    int
+	address uint32
+	insn    insn
+}
+
+func newMars(cfg goexmars.FightConfig, warriors []warrior) *mars {
+	n := len(warriors)
+	m := &mars{
+		coreSize:       uint32(cfg.CoreSize),
+		cycles:         uint64(cfg.Cycles),
+		processes:      uint64(cfg.MaxProcess),
+		rounds:         cfg.Rounds,
+		minSep:         uint64(cfg.MinSep),
+		pspaceSize:     uint32(cfg.PSpaceSize),
+		fixPos:         cfg.FixPos,
+		warriors:       warriors,
+		core:           make([]insn, cfg.CoreSize),
+		queue:          make([]uint32, n*cfg.MaxProcess+1),
+		procs:          make([]proc, n),
+		deaths:         make([]int, 0, n),
+		positions:      make([]uint64, n),
+		startPositions: make([]uint32, n),
+		pspaces:        make([]*pspace, n),
+		slotPSpaces:    make([]*pspace, n),
+		results:        make([][]int, n),
+		traceRound:     -1,
+	}
+	if m.pspaceSize == 0 {
+		m.pspaceSize = m.coreSize / 16
+		if m.pspaceSize == 0 {
+			m.pspaceSize = 1
+		}
+	}
+	for i := range m.pspaces {
+		m.pspaces[i] = &pspace{mem: make([]uint32, m.pspaceSize), lastResult: m.coreSize - 1}
+		m.results[i] = make([]int, n+1)
+	}
+	return m
+}
+
+// checkSanity reports the setups that make exmars abort in check_sanity.
+func (m *mars) checkSanity() error {
+	for i, w := range m.warriors {
+		if uint64(len(w.code)) > m.minSep {
+			return fmt.Errorf("warrior %d: length %d exceeds MinSep %d", i, len(w.code), m.minSep)
+		}
+	}
+	if uint64(len(m.warriors))*m.minSep > uint64(m.coreSize) {
+		return errors.New("warriors too large to fit into core")
+	}
+	return nil
+}
+
+// fight runs all rounds and returns the sole-win counts and ties like
+// run_fight_rounds. seed is used unless FixPos is set.
+func (m *mars) fight(seed int64) ([]int, int, error) {
+	if err := m.checkSanity(); err != nil {
+		return nil, 0, err
+	}
+
+	n := len(m.warriors)
+	if m.fixPos != 0 {
+		seed = int64(uint64(m.fixPos) - m.minSep)
+	} else {
+		seed = rng(seed)
+	}
+
+	// exmars never sets have_pin, so PINs do not share p-space.
+	for round := 0; round < m.rounds; round++ {
+		clear(m.core)
+
+		seed = m.computePositions(seed)
+		for i, w := range m.warriors {
+			// sim_load_warrior takes the position as an unsigned int.
+			pos := uint32(m.positions[i])
+			for j, in := range w.code {
+				m.core[(pos+uint32(j))%m.coreSize] = in
+			}
+		}
+		for i := 0; i < n; i++ {
+			j := (i + round) % n
+			m.startPositions[i] = uint32((m.positions[j] + uint64(m.warriors[j].start)) % uint64(m.coreSize))
+			m.slotPSpaces[i] = m.pspaces[j]
+		}
+
+		m.tracing = round == m.traceRound
+		alive := m.simulate(m.startPositions)
+		for _, p := range m.slotPSpaces {
+			p.set(0, uint32(alive))
+		}
+		for _, id := range m.deaths {
+			m.slotPSpaces[id].set(0, 0)
+		}
+
+		for i, p := range m.pspaces {
+			m.results[i][p.lastResult]++
+		}
+	}
+
+	wins := make([]int, n)
+	total := 0
+	for i := range wins {
+		wins[i] = m.results[i][1]
+		total += wins[i]
+	}
+	return wins, m.rounds - total, nil
+}
+
+// rng is the pMARS random number generator.
+func rng(seed int64) int64 {
+	t := 16807*(seed%127773) - 2836*(seed/127773)
+	if t < 0 {
+		t += 2147483647
+	}
+	return t
+}
+
+// computePositions places the warriors like compute_positions in pmars.c and
+// returns the next seed. Warrior 0 is always loaded at 0. The arithmetic
+// follows the unsigned C types.
+func (m *mars) computePositions(seed int64) int64 {
+	n := uint64(len(m.warriors))
+	m.positions[0] = 0
+
+	switch n {
+	case 1:
+		return seed
+	case 2:
+		avail := uint64(m.coreSize) + 1 - n*m.minSep
+		m.positions[1] = m.minSep + uint64(seed)%avail
+		return rng(seed)
+	}
+
+	if m.posit(&seed) {
+		m.npos(&seed)
+	}
+	return seed
+}
+
+const (
+	positRetries1 = 20 // attempts to generate one position
+	positRetries2 = 4  // attempts to start backtracking
+)
+
+// posit picks positions at random and retries on overlap. It reports true
+// when it gave up.
+func (m *mars) posit(seed *int64) bool {
+	n := len(m.warriors)
+	span := uint64(m.coreSize) - 2*m.minSep + 1
+	retries1, retries2 := positRetries1, positRetries2
+
+	for pos := 1; pos < n; {
+		*seed = rng(*seed)
+		m.positions[pos] = uint64(*seed)%span + m.minSep
+
+		i := 1
+		for ; i < pos; i++ {
+			diff := int32(m.positions[pos] - m.positions[i])
+			if diff < 0 {
+				diff = -diff
+			}
+			if uint64(uint32(diff)) < m.minSep {
+				break
+			}
+		}
+
+		switch {
+		case i == pos:
+			pos++
+		case retries2 == 0:
+			return true
+		case retries1 == 0:
+			pos = i
+			retries2--
+			retries1 = positRetries1
+		default:
+			retries1--
+		}
+	}
+	return false
+}
+
+// npos chooses sorted offsets from the free room, spreads them by MinSep and
+// shuffles them. It always succeeds.
+func (m *mars) npos(seed *int64) {
+	n := len(m.warriors)
+	room := uint32(uint64(m.coreSize) - m.minSep*uint64(n) + 1)
+
+	for i := 1; i < n; i++ {
+		*seed = rng(*seed)
+		temp := uint64(uint32(*seed % int64(room)))
+		j := i - 1
+		for ; j > 0; j-- {
+			if temp > m.positions[j] {
+				break
+			}
+			m.positions[j+1] = m.positions[j]
+		}
+		m.positions[j+1] = temp
+	}
+
+	sep := m.minSep
+	for i := 1; i < n; i++ {
+		m.positions[i] += sep
+		sep += m.minSep
+	}
+
+	for i := 1; i < n; i++ {
+		*seed = rng(*seed)
+		j := int(uint64(*seed)%uint64(n-i)) + i
+		m.positions[i], m.positions[j] = m.positions[j], m.positions[i]
+	}
+}
@@ -0,0 +1,199 @@
+// Package sim is a pure-Go port of the exmars ICWS'94 simulator.
+//
+// Fight and FightParsed have the same signatures and results as their
+// goexmars counterparts: warriors are assembled by the asm package, placed
+// with pMARS-compatible positioning (posit/npos) and run by a transcription of
+// exhaust's simulator, honouring every FightConfig field. With the same FixPos
+// a fight produces the same wins and ties as exmars.
+//
+// Call the functions of this package to select the Go engine explicitly.
+// Importing it also registers it as the goexmars fallback, so goexmars uses it
+// automatically when the shared library cannot be loaded:
+//
+//	import _ "github.com/BigJk/goexmars/sim"
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/asm"
+)
+
+func init() {
+	goexmars.RegisterFallback(&goexmars.Fallback{
+		Name:           "sim",
+		Fight:          Fight,
+		FightParsed:    FightParsed,
+		TraceParsed:    TraceParsed,
+		Assemble:       asm.Assemble,
+		EvalExpression: asm.EvalExpression,
+	})
+}
+
+// Fight runs a fight for 1 to 6 warriors like goexmars.Fight.
+//
+// On assembly failure, the returned FightResult contains sentinel values
+// (negative wins/ties) and error is a *goexmars.AssembleError carrying the
+// diagnostics of all warriors up to the one that failed.
+func Fight(warriors []string, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+	if len(warriors) < 1 || len(warriors) > 6 {
+		return goexmars.FightResult{}, fmt.Errorf("Fight supports 1 to 6 warriors, got %d", len(warriors))
+	}
+	if err := cfg.Validate(); err != nil {
+		return goexmars.FightResult{}, err
+	}
+
+	var (
+		text  strings.Builder
+		diags []goexmars.Diagnostic
+	)
+	loaded := make([]warrior, len(warriors))
+	for i, src := range warriors {
+		p, err := asm.AssembleForFight(src, len(warriors), cfg)
+		if err != nil {
+			var asmErr *goexmars.AssembleError
+			if !errors.As(err, &asmErr) {
+				return goexmars.FightResult{}, err
+			}
+			text.WriteString(asmErr.Text)
+			diags = appendDiagnostics(diags, asmErr.Diagnostics, i)
+			result := failedResult(len(warriors), text.String(), diags)
+			return result, &goexmars.AssembleError{Diagnostics: diags, Text: text.String()}
+		}
+		text.WriteString(p.Text)
+		diags = appendDiagnostics(diags, p.Diagnostics, i)
+		loaded[i] = load(p.Warrior, cfg)
+	}
+
+	return run(loaded, cfg, text.String(), diags)
+}
+
+// FightParsed runs a fight for 1 to 6 parsed warriors like goexmars.FightParsed.
+//
+// The warriors' Commands are loaded directly with End as the start offset.
+// Name and Author are ignored.
+func FightParsed(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig) (goexmars.FightResult, error) {
+	if len(warriors) < 1 || len(warriors) > 6 {
+		return goexmars.FightResult{}, fmt.Errorf("FightParsed supports 1 to 6 warriors, got %d", len(warriors))
+	}
+	if err := cfg.Validate(); err != nil {
+		return goexmars.FightResult{}, err
+	}
+
+	loaded := make([]warrior, len(warriors))
+	for i, w := range warriors {
+		if err := checkLoadable(w, cfg); err != nil {
+			return goexmars.FightResult{}, fmt.Errorf("warrior %d: %w", i, err)
+		}
+		loaded[i] = load(w, cfg)
+	}
+	return run(loaded, cfg, "", nil)
+}
+
+// TraceParsed returns the instructions executed in the given 0-based round
+// like goexmars.TraceParsed.
+func TraceParsed(warriors []goexmars.ParsedWarrior, cfg goexmars.FightConfig, round int) ([]goexmars.TraceStep, error) {
+	if len(warriors) < 1 || len(warriors) > 6 {
+		return nil, fmt.Errorf("TraceParsed supports 1 to 6 warriors, got %d", len(warriors))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if round < 0 || round >= cfg.Rounds {
+		return nil, fmt.Errorf("round %d out of range [0, %d)", round, cfg.Rounds)
+	}
+
+	loaded := make([]warrior, len(warriors))
+	for i, w := range warriors {
+		if err := checkLoadable(w, cfg); err != nil {
+			return nil, fmt.Errorf("warrior %d: %w", i, err)
+		}
+		loaded[i] = load(w, cfg)
+	}
+
+	m := newMars(cfg, loaded)
+	m.rounds = round + 1
+	m.traceRound = round
+	if _, _, err := m.fight(rng(time.Now().Unix() * 0x1d872b41)); err != nil {
+		return nil, err
+	}
+
+	trace := make([]goexmars.TraceStep, len(m.trace))
+	for i, st := range m.trace {
+		// Execution slot s holds warrior (s+round) % n, see fight.
+		trace[i] = goexmars.TraceStep{
+			Warrior: (st.slot + round) % len(warriors),
+			Address: int(st.address),
+			Command: decode(st.insn),
+		}
+	}
+	return trace, nil
+}
+
+func run(warriors []warrior, cfg goexmars.FightConfig, text string, diags []goexmars.Diagnostic) (goexmars.FightResult, error) {
+	m := newMars(cfg, warriors)
+	// exmars seeds its generator from the clock in the same way.
+	wins, ties, err := m.fight(rng(time.Now().Unix() * 0x1d872b41))
+	if err != nil {
+		return goexmars.FightResult{}, err
+	}
+	return goexmars.FightResult{
+		Wins:           wins,
+		Ties:           ties,
+		Diagnostics:    text,
+		DiagnosticList: diags,
+	}, nil
+}
+
+func load(w goexmars.ParsedWarrior, cfg goexmars.FightConfig) warrior {
+	code := make([]insn, len(w.Commands))
+	for i, cmd := range w.Commands {
+		code[i] = encode(cmd, cfg.CoreSize)
+	}
+	return warrior{code: code, start: mods(w.End, cfg.CoreSize)}
+}
+
+func appendDiagnostics(dst, src []goexmars.Diagnostic, warrior int) []goexmars.Diagnostic {
+	for _, d := range src {
+		d.Warrior = warrior
+		dst = append(dst, d)
+	}
+	return dst
+}
+
+func failedResult(n int, text string, diags []goexmars.Diagnostic) goexmars.FightResult {
+	result := goexmars.FightResult{
+		Wins:           make([]int, n),
+		Ties:           -1,
+		Diagnostics:    text,
+		DiagnosticList: diags,
+	}
+	for i := range result.Wins {
+		result.Wins[i] = -1
+	}
+	return result
+}
+
+// checkLoadable mirrors the checks goexmars.FightParsed runs before loading.
+func checkLoadable(w goexmars.ParsedWarrior, cfg goexmars.FightConfig) error {
+	if len(w.Commands) == 0 {
+		return errors.New("no commands")
+	}
+	if len(w.Commands) > cfg.MaxWarriorLen {
+		return fmt.Errorf("length %d exceeds MaxWarriorLen %d", len(w.Commands), cfg.MaxWarriorLen)
+	}
+	if len(w.Commands) > cfg.MinSep {
+		return fmt.Errorf("length %d exceeds MinSep %d", len(w.Commands), cfg.MinSep)
+	}
+	for i, cmd := range w.Commands {
+		if int(cmd.OpCode) >= goexmars.OpCodeCount || int(cmd.Modifier) >= goexmars.ModifierCount ||
+			int(cmd.AddressingModeA) >= goexmars.AddressingModeCount || int(cmd.AddressingModeB) >= goexmars.AddressingModeCount {
+			return fmt.Errorf("invalid command encoding at offset %d", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package sim
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/BigJk/goexmars"
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+var testWarriors = map[string]string{
+	"imp": `
+;redcode-94
+;name Imp
+;assert 1
+MOV 0, 1
+END
+`,
+	"dwarf": `
+;redcode-94
+;name Dwarf
+;assert CORESIZE % 4 == 0
+        ORG start
+start   ADD.AB #4, bomb
+        MOV.I  bomb, @bomb
+        JMP    start
+bomb    DAT    #0, #0
+`,
+	"paper": `
+;redcode-94
+;name Paper
+;assert 1
+start SPL 1
+      MOV -1, 0
+      SPL 1
+      MOV.I #0, 100
+      MOV.I }-1, >-1
+      JMP start+2
+END start
+`,
+	"scanner": `
+;redcode-94
+;name Scanner
+;assert 1
+scan    ADD.F  step, ptr
+ptr     SNE.I  100, 104
+        JMP    scan
+        SLT.AB #10, ptr
+        DJN.B  scan, #500
+        MOV.AB ptr, bomb
+bomb    MOV.I  *bomb, <bomb
+        JMN    bomb, ptr
+        JMZ.F  scan, >ptr
+step    DAT    #8, #8
+`,
+	"pspace": `
+;redcode-94
+;name PSpace
+;assert 1
+        LDP.AB #0, res
+        LDP.A  #3, res
+        STP.BA res, #4
+res     MOV.X  #0, 1
+        SEQ.AB #0, res
+        JMP    alt
+        SPL    0, }0
+        MOV.I  {-1, <-2
+alt     MUL.F  #3, @1
+        DIV.X  #2, {-1
+        MOD.BA #7, }-1
+        SUB.I  #1, *-1
+        JMP    alt
+`,
+	"divider": `
+;redcode-94
+;name Divider
+;assert 1
+        SPL    1
+        DIV.A  #0, 5
+        MOD.F  @2, 7
+        DAT    }1, >1
+`,
+}
+
+func TestFightMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	configs := map[string]goexmars.FightConfig{
+		"default":   goexmars.DefaultConfig.SetRounds(20).SetFixPos(1234),
+		"limited":   goexmars.LimitedProcessConfig.SetRounds(20).SetFixPos(3001),
+		"small":     goexmars.DefaultConfig.SetCoreSize(800).SetCycles(8000).SetMaxWarriorLen(20).SetMinSep(20).SetPSpaceSize(0).SetRounds(30).SetFixPos(150),
+		"one-cycle": goexmars.DefaultConfig.SetCycles(1).SetRounds(3).SetFixPos(500),
+	}
+	groups := [][]string{
+		{"imp"},
+		{"dwarf", "paper"},
+		{"scanner", "imp"},
+		{"pspace", "dwarf"},
+		{"divider", "scanner"},
+		{"dwarf", "paper", "scanner"},
+		{"imp", "pspace", "divider", "dwarf"},
+		{"imp", "dwarf", "paper", "scanner", "pspace", "divider"},
+	}
+
+	for cfgName, cfg := range configs {
+		for _, group := range groups {
+			srcs := make([]string, len(group))
+			for i, name := range group {
+				srcs[i] = testWarriors[name]
+			}
+
+			want, err := goexmars.Fight(srcs, cfg)
+			if err != nil {
+				t.Fatalf("%s %v: exmars: %v", cfgName, group, err)
+			}
+			got, err := Fight(srcs, cfg)
+			if err != nil {
+				t.Fatalf("%s %v: sim: %v", cfgName, group, err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("%s %v: result mismatch:\nexmars: %+v\nsim:    %+v", cfgName, group, want, got)
+			}
+		}
+	}
+}
+
+func TestFightParsedMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	cfg := goexmars.DefaultConfig.SetRounds(20).SetFixPos(4321)
+	var parsed []goexmars.ParsedWarrior
+	for _, name := range []string{"scanner", "paper", "pspace"} {
+		w, err := goexmars.AssembleParsed(testWarriors[name], cfg)
+		if err != nil {
+			t.Fatalf("assemble %s: %v", name, err)
+		}
+		parsed = append(parsed, w)
+	}
+
+	want, err := goexmars.FightParsed(parsed, cfg)
+	if err != nil {
+		t.Fatalf("exmars: %v", err)
+	}
+	got, err := FightParsed(parsed, cfg)
+	if err != nil {
+		t.Fatalf("sim: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("result mismatch:\nexmars: %+v\nsim:    %+v", want, got)
+	}
+}
+
+func TestFightReportsAssemblyErrors(t *testing.T) {
+	testlib.Configure(t)
+
+	srcs := []string{testWarriors["imp"], ";redcode-94\n;assert 1\nJMP nowhere\nMOV.Z 0, 1\nEND\n"}
+	want, wantErr := goexmars.Fight(srcs, goexmars.DefaultConfig)
+	got, gotErr := Fight(srcs, goexmars.DefaultConfig)
+
+	var wantAsm, gotAsm *goexmars.AssembleError
+	if !errors.As(wantErr, &wantAsm) || !errors.As(gotErr, &gotAsm) {
+		t.Fatalf("expected *AssembleError, got %v and %v", wantErr, gotErr)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("result mismatch:\nexmars: %+v\nsim:    %+v", want, got)
+	}
+	if !reflect.DeepEqual(wantAsm, gotAsm) {
+		t.Fatalf("error mismatch:\nexmars: %+v\nsim:    %+v", wantAsm, gotAsm)
+	}
+}
+
+func TestFallbackRegistered(t *testing.T) {
+	f := goexmars.RegisteredFallback()
+	if f == nil || f.Name != "sim" {
+		t.Fatalf("expected sim to be registered as fallback, got %+v", f)
+	}
+}
+
+func TestTraceParsedMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+
+	cfg := goexmars.DefaultConfig.SetCycles(5000).SetRounds(4).SetFixPos(2500)
+	var parsed []goexmars.ParsedWarrior
+	for _, name := range []string{"dwarf", "pspace", "paper"} {
+		w, err := goexmars.AssembleParsed(testWarriors[name], cfg)
+		if err != nil {
+			t.Fatalf("assemble %s: %v", name, err)
+		}
+		parsed = append(parsed, w)
+	}
+
+	for round := 0; round < cfg.Rounds; round++ {
+		want, err := goexmars.TraceParsed(parsed, cfg, round)
+		if err != nil {
+			t.Fatalf("round %d: exmars: %v", round, err)
+		}
+		got, err := TraceParsed(parsed, cfg, round)
+		if err != nil {
+			t.Fatalf("round %d: sim: %v", round, err)
+		}
+		if len(want) == 0 {
+			t.Fatalf("round %d: empty trace", round)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("round %d: traces differ (exmars %d steps, sim %d steps)", round, len(want), len(got))
+		}
+	}
+}
+
+// TestTraceParsedWideEndMatchesExmars checks that an End beyond 32 bits is
+// reduced modulo CoreSize by both engines rather than truncated.
+func TestTraceParsedWideEndMatchesExmars(t *testing.T) {
+	testlib.Configure(t)
+	if strconv.IntSize < 64 {
+		t.Skip("int is 32 bits")
+	}
+
+	cfg := goexmars.DefaultConfig.SetCycles(200).SetRounds(1).SetFixPos(2500)
+	var parsed []goexmars.ParsedWarrior
+	for _, name := range []string{"dwarf", "imp"} {
+		w, err := goexmars.AssembleParsed(testWarriors[name], cfg)
+		if err != nil {
+			t.Fatalf("assemble %s: %v", name, err)
+		}
+		parsed = append(parsed, w)
+	}
+	shift := 32
+	parsed[0].End = 1<<shift + 1
+
+	want, err := goexmars.TraceParsed(parsed, cfg, 0)
+	if err != nil {
+		t.Fatalf("exmars: %v", err)
+	}
+	got, err := TraceParsed(parsed, cfg, 0)
+	if err != nil {
+		t.Fatalf("sim: %v", err)
+	}
+	if len(want) == 0 || !reflect.DeepEqual(want, got) {
+		t.Fatalf("traces differ: exmars starts at %+v, sim at %+v", want[:min(1, len(want))], got[:min(1, len(got))])
+	}
+}
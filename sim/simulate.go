@@ -0,0 +1,596 @@
+package sim
+
+// simulate runs one round with the warriors' first processes at start, in
+// execution order, and returns the number of warriors still alive. The slots
+// of warriors that died are left in m.deaths in the order of death.
+//
+// It is a transcription of sim_proper from exhaust's sim.c, including its
+// in-register operand evaluation and the early exit once a tie is certain.
+// The process queues share one cyclic buffer of n*MaxProcess+1 entries in
+// which each warrior owns a slice that slides along as it executes.
+func (m *mars) simulate(start []uint32) int {
+	core := m.core
+	queue := m.queue
+	procs := m.procs[:len(start)]
+	cs := m.coreSize
+	cs1 := cs - 1
+	processes := m.processes
+	pspaceSize := m.pspaceSize
+	slotPSpaces := m.slotPSpaces
+	tracing := m.tracing
+
+	nwar := len(start)
+	cycles := uint64(nwar) * m.cycles
+	alive := nwar
+	maxAliveProc := uint64(nwar) * processes
+	m.deaths = m.deaths[:0]
+
+	add := func(x, y uint32) uint32 {
+		z := x + y
+		if z >= cs {
+			z -= cs
+		}
+		return z
+	}
+	inc := func(x *uint32) {
+		if *x++; *x == cs {
+			*x = 0
+		}
+	}
+	dec := func(x *uint32) {
+		if *x == 0 {
+			*x = cs1
+		} else {
+			*x--
+		}
+	}
+	sub := func(x, y uint32) uint32 {
+		z := x - y
+		if z >= cs {
+			z += cs
+		}
+		return z
+	}
+
+	procs[0].succ = &procs[nwar-1]
+	procs[nwar-1].pred = &procs[0]
+	pofs := len(queue) - 1
+	for f := 0; f < nwar; f++ {
+		t := nwar - 1 - f
+		if t > 0 {
+			procs[t].succ = &procs[t-1]
+		}
+		if t < nwar-1 {
+			procs[t].pred = &procs[t+1]
+		}
+		pofs -= int(processes)
+		queue[pofs] = start[f]
+		procs[t].head = pofs
+		procs[t].tail = pofs + 1
+		procs[t].nprocs = 1
+		procs[t].id = f
+	}
+
+	w := &procs[nwar-1]
+	push := func(x uint32) {
+		queue[w.tail] = x
+		if w.tail++; w.tail == len(queue) {
+			w.tail = 0
+		}
+	}
+
+	var (
+		ip, pta, ptb           uint32
+		in, mode               uint32
+		raA, raB, rbA, rbB, fp uint32
+	)
+
+	for {
+		ip = queue[w.head]
+		if w.head++; w.head == len(queue) {
+			w.head = 0
+		}
+		if tracing {
+			m.trace = append(m.trace, step{slot: w.id, address: ip, insn: core[ip]})
+		}
+		in = core[ip].in
+		raA = core[ip].a
+		rbA = raA
+		rbB = core[ip].b
+
+		// a-mode
+		switch in & modeMask {
+		case exImmediate:
+			raB = rbB
+			pta = ip
+		case exDirect:
+			pta = add(ip, raA)
+			raA, raB = core[pta].a, core[pta].b
+		case exBIndirect:
+			pta = add(ip, raA)
+			pta = add(pta, core[pta].b)
+			raA, raB = core[pta].a, core[pta].b
+		case exAPostInc:
+			fp = add(ip, raA)
+			pta = add(fp, core[fp].a)
+			raA, raB = core[pta].a, core[pta].b
+			inc(&core[fp].a)
+		case exBPostInc:
+			fp = add(ip, raA)
+			pta = add(fp, core[fp].b)
+			raA, raB = core[pta].a, core[pta].b
+			inc(&core[fp].b)
+		case exAPreDec:
+			pta = add(ip, raA)
+			dec(&core[pta].a)
+			pta = add(pta, core[pta].a)
+			raA, raB = core[pta].a, core[pta].b
+		case exBPreDec:
+			pta = add(ip, raA)
+			dec(&core[pta].b)
+			pta = add(pta, core[pta].b)
+			raA, raB = core[pta].a, core[pta].b
+		default: // exAIndirect
+			pta = add(ip, raA)
+			pta = add(pta, core[pta].a)
+			raA, raB = core[pta].a, core[pta].b
+		}
+
+		mode = in >> modeBits & modeMask
+
+		// MOV.I is the most common instruction and gets its own path.
+		if in>>opModShift == exMOV<<modeBits|mI {
+			switch mode {
+			case exDirect:
+				ptb = add(ip, rbB)
+			case exBPostInc:
+				fp = add(ip, rbB)
+				ptb = add(fp, core[fp].b)
+				inc(&core[fp].b)
+			case exAIndirect:
+				ptb = add(ip, rbB)
+				ptb = add(ptb, core[ptb].a)
+			case exAPostInc:
+				fp = add(ip, rbB)
+				ptb = add(fp, core[fp].a)
+				inc(&core[fp].a)
+			case exAPreDec:
+				ptb = add(ip, rbB)
+				dec(&core[ptb].a)
+				ptb = add(ptb, core[ptb].a)
+			case exBPreDec:
+				ptb = add(ip, rbB)
+				dec(&core[ptb].b)
+				ptb = add(ptb, core[ptb].b)
+			case exBIndirect:
+				ptb = add(ip, rbB)
+				ptb = add(ptb, core[ptb].b)
+			default: // exImmediate
+				ptb = ip
+			}
+			core[ptb].a = raA
+			core[ptb].b = raB
+			core[ptb].in = core[pta].in
+			inc(&ip)
+			push(ip)
+			goto next
+		}
+
+		// DAT and SPL only apply the b-operand's side effects.
+		if in>>opModShift>>modeBits <= exSPL {
+			switch mode {
+			case exBPostInc:
+				inc(&core[add(ip, rbB)].b)
+			case exBPreDec:
+				dec(&core[add(ip, rbB)].b)
+			case exAPreDec:
+				dec(&core[add(ip, rbB)].a)
+			case exAPostInc:
+				inc(&core[add(ip, rbB)].a)
+			}
+
+			if in>>opModShift>>modeBits == exDAT {
+				goto die
+			}
+
+			inc(&ip)
+			push(ip)
+			if w.nprocs < processes {
+				w.nprocs++
+				push(pta)
+			}
+			// In the endgame, stop once no warrior can lose all its processes.
+			if cycles < maxAliveProc {
+				it := w.succ
+				for it.nprocs*uint64(alive) > cycles && it != w {
+					it = it.succ
+				}
+				if it.nprocs*uint64(alive) > cycles {
+					goto out
+				}
+			}
+			goto next
+		}
+
+		// b-mode
+		switch mode {
+		case exAPreDec:
+			ptb = add(ip, rbB)
+			dec(&core[ptb].a)
+			ptb = add(ptb, core[ptb].a)
+			rbA, rbB = core[ptb].a, core[ptb].b
+		case exDirect:
+			ptb = add(ip, rbB)
+			rbA, rbB = core[ptb].a, core[ptb].b
+		case exAPostInc:
+			fp = add(ip, rbB)
+			ptb = add(fp, core[fp].a)
+			rbA, rbB = core[ptb].a, core[ptb].b
+			inc(&core[fp].a)
+		case exBPreDec:
+			ptb = add(ip, rbB)
+			dec(&core[ptb].b)
+			ptb = add(ptb, core[ptb].b)
+			rbA, rbB = core[ptb].a, core[ptb].b
+		case exImmediate:
+			ptb = ip
+		case exBPostInc:
+			fp = add(ip, rbB)
+			ptb = add(fp, core[fp].b)
+			rbA, rbB = core[ptb].a, core[ptb].b
+			inc(&core[fp].b)
+		case exBIndirect:
+			ptb = add(ip, rbB)
+			ptb = add(ptb, core[ptb].b)
+			rbA, rbB = core[ptb].a, core[ptb].b
+		default: // exAIndirect
+			ptb = add(ip, rbB)
+			ptb = add(ptb, core[ptb].a)
+			rbA, rbB = core[ptb].a, core[ptb].b
+		}
+
+		switch in >> opModShift {
+		case exMOV<<modeBits | mA:
+			core[ptb].a = raA
+		case exMOV<<modeBits | mF:
+			core[ptb].a = raA
+			fallthrough
+		case exMOV<<modeBits | mB:
+			core[ptb].b = raB
+		case exMOV<<modeBits | mAB:
+			core[ptb].b = raA
+		case exMOV<<modeBits | mX:
+			core[ptb].b = raA
+			fallthrough
+		case exMOV<<modeBits | mBA:
+			core[ptb].a = raB
+
+		case exDJN<<modeBits | mBA, exDJN<<modeBits | mA:
+			dec(&core[ptb].a)
+			if rbA == 1 {
+				break
+			}
+			push(pta)
+			goto next
+		case exDJN<<modeBits | mAB, exDJN<<modeBits | mB:
+			dec(&core[ptb].b)
+			if rbB == 1 {
+				break
+			}
+			push(pta)
+			goto next
+		case exDJN<<modeBits | mX, exDJN<<modeBits | mI, exDJN<<modeBits | mF:
+			dec(&core[ptb].a)
+			dec(&core[ptb].b)
+			if rbA == 1 && rbB == 1 {
+				break
+			}
+			push(pta)
+			goto next
+
+		case exADD<<modeBits | mI, exADD<<modeBits | mF:
+			core[ptb].b = add(raB, rbB)
+			fallthrough
+		case exADD<<modeBits | mA:
+			core[ptb].a = add(raA, rbA)
+		case exADD<<modeBits | mB:
+			core[ptb].b = add(raB, rbB)
+		case exADD<<modeBits | mX:
+			core[ptb].a = add(raB, rbA)
+			fallthrough
+		case exADD<<modeBits | mAB:
+			core[ptb].b = add(raA, rbB)
+		case exADD<<modeBits | mBA:
+			core[ptb].a = add(raB, rbA)
+
+		case exJMZ<<modeBits | mBA, exJMZ<<modeBits | mA:
+			if rbA != 0 {
+				break
+			}
+			push(pta)
+			goto next
+		case exJMZ<<modeBits | mAB, exJMZ<<modeBits | mB:
+			if rbB != 0 {
+				break
+			}
+			push(pta)
+			goto next
+		case exJMZ<<modeBits | mX, exJMZ<<modeBits | mF, exJMZ<<modeBits | mI:
+			if rbA != 0 || rbB != 0 {
+				break
+			}
+			push(pta)
+			goto next
+
+		case exSUB<<modeBits | mI, exSUB<<modeBits | mF:
+			core[ptb].b = sub(rbB, raB)
+			fallthrough
+		case exSUB<<modeBits | mA:
+			core[ptb].a = sub(rbA, raA)
+		case exSUB<<modeBits | mB:
+			core[ptb].b = sub(rbB, raB)
+		case exSUB<<modeBits | mX:
+			core[ptb].a = sub(rbA, raB)
+			fallthrough
+		case exSUB<<modeBits | mAB:
+			core[ptb].b = sub(rbB, raA)
+		case exSUB<<modeBits | mBA:
+			core[ptb].a = sub(rbA, raB)
+
+		case exSEQ<<modeBits | mA:
+			if raA == rbA {
+				inc(&ip)
+			}
+		case exSEQ<<modeBits | mB:
+			if raB == rbB {
+				inc(&ip)
+			}
+		case exSEQ<<modeBits | mAB:
+			if raA == rbB {
+				inc(&ip)
+			}
+		case exSEQ<<modeBits | mBA:
+			if raB == rbA {
+				inc(&ip)
+			}
+		case exSEQ<<modeBits | mI:
+			if core[pta].in != core[ptb].in {
+				break
+			}
+			fallthrough
+		case exSEQ<<modeBits | mF:
+			if raA == rbA && raB == rbB {
+				inc(&ip)
+			}
+		case exSEQ<<modeBits | mX:
+			if raA == rbB && raB == rbA {
+				inc(&ip)
+			}
+
+		case exSNE<<modeBits | mA:
+			if raA != rbA {
+				inc(&ip)
+			}
+		case exSNE<<modeBits | mB:
+			if raB != rbB {
+				inc(&ip)
+			}
+		case exSNE<<modeBits | mAB:
+			if raA != rbB {
+				inc(&ip)
+			}
+		case exSNE<<modeBits | mBA:
+			if raB != rbA {
+				inc(&ip)
+			}
+		case exSNE<<modeBits | mI:
+			if core[pta].in != core[ptb].in {
+				inc(&ip)
+				break
+			}
+			fallthrough
+		case exSNE<<modeBits | mF:
+			if raA != rbA || raB != rbB {
+				inc(&ip)
+			}
+		case exSNE<<modeBits | mX:
+			if raA != rbB || raB != rbA {
+				inc(&ip)
+			}
+
+		case exJMN<<modeBits | mBA, exJMN<<modeBits | mA:
+			if rbA == 0 {
+				break
+			}
+			push(pta)
+			goto next
+		case exJMN<<modeBits | mAB, exJMN<<modeBits | mB:
+			if rbB == 0 {
+				break
+			}
+			push(pta)
+			goto next
+		case exJMN<<modeBits | mX, exJMN<<modeBits | mF, exJMN<<modeBits | mI:
+			if rbA != 0 || rbB != 0 {
+				push(pta)
+				goto next
+			}
+
+		case exJMP<<modeBits | mA, exJMP<<modeBits | mB, exJMP<<modeBits | mAB, exJMP<<modeBits | mBA,
+			exJMP<<modeBits | mX, exJMP<<modeBits | mF, exJMP<<modeBits | mI:
+			push(pta)
+			goto next
+
+		case exSLT<<modeBits | mA:
+			if raA < rbA {
+				inc(&ip)
+			}
+		case exSLT<<modeBits | mAB:
+			if raA < rbB {
+				inc(&ip)
+			}
+		case exSLT<<modeBits | mB:
+			if raB < rbB {
+				inc(&ip)
+			}
+		case exSLT<<modeBits | mBA:
+			if raB < rbA {
+				inc(&ip)
+			}
+		case exSLT<<modeBits | mI, exSLT<<modeBits | mF:
+			if raA < rbA && raB < rbB {
+				inc(&ip)
+			}
+		case exSLT<<modeBits | mX:
+			if raA < rbB && raB < rbA {
+				inc(&ip)
+			}
+
+		case exMOD<<modeBits | mI, exMOD<<modeBits | mF:
+			if raA != 0 {
+				core[ptb].a = rbA % raA
+			}
+			if raB != 0 {
+				core[ptb].b = rbB % raB
+			}
+			if raA == 0 || raB == 0 {
+				goto die
+			}
+		case exMOD<<modeBits | mX:
+			if raB != 0 {
+				core[ptb].a = rbA % raB
+			}
+			if raA != 0 {
+				core[ptb].b = rbB % raA
+			}
+			if raB == 0 || raA == 0 {
+				goto die
+			}
+		case exMOD<<modeBits | mA:
+			if raA == 0 {
+				goto die
+			}
+			core[ptb].a = rbA % raA
+		case exMOD<<modeBits | mB:
+			if raB == 0 {
+				goto die
+			}
+			core[ptb].b = rbB % raB
+		case exMOD<<modeBits | mAB:
+			if raA == 0 {
+				goto die
+			}
+			core[ptb].b = rbB % raA
+		case exMOD<<modeBits | mBA:
+			if raB == 0 {
+				goto die
+			}
+			core[ptb].a = rbA % raB
+
+		case exMUL<<modeBits | mI, exMUL<<modeBits | mF:
+			core[ptb].b = mul(rbB, raB, cs)
+			fallthrough
+		case exMUL<<modeBits | mA:
+			core[ptb].a = mul(rbA, raA, cs)
+		case exMUL<<modeBits | mB:
+			core[ptb].b = mul(rbB, raB, cs)
+		case exMUL<<modeBits | mX:
+			core[ptb].a = mul(rbA, raB, cs)
+			fallthrough
+		case exMUL<<modeBits | mAB:
+			core[ptb].b = mul(rbB, raA, cs)
+		case exMUL<<modeBits | mBA:
+			core[ptb].a = mul(rbA, raB, cs)
+
+		case exDIV<<modeBits | mI, exDIV<<modeBits | mF:
+			if raA != 0 {
+				core[ptb].a = rbA / raA
+			}
+			if raB != 0 {
+				core[ptb].b = rbB / raB
+			}
+			if raA == 0 || raB == 0 {
+				goto die
+			}
+		case exDIV<<modeBits | mX:
+			if raB != 0 {
+				core[ptb].a = rbA / raB
+			}
+			if raA != 0 {
+				core[ptb].b = rbB / raA
+			}
+			if raB == 0 || raA == 0 {
+				goto die
+			}
+		case exDIV<<modeBits | mA:
+			if raA == 0 {
+				goto die
+			}
+			core[ptb].a = rbA / raA
+		case exDIV<<modeBits | mB:
+			if raB == 0 {
+				goto die
+			}
+			core[ptb].b = rbB / raB
+		case exDIV<<modeBits | mAB:
+			if raA == 0 {
+				goto die
+			}
+			core[ptb].b = rbB / raA
+		case exDIV<<modeBits | mBA:
+			if raB == 0 {
+				goto die
+			}
+			core[ptb].a = rbA / raB
+
+		case exLDP<<modeBits | mA:
+			core[ptb].a = slotPSpaces[w.id].get(raA % pspaceSize)
+		case exLDP<<modeBits | mAB:
+			core[ptb].b = slotPSpaces[w.id].get(raA % pspaceSize)
+		case exLDP<<modeBits | mBA:
+			core[ptb].a = slotPSpaces[w.id].get(raB % pspaceSize)
+		case exLDP<<modeBits | mF, exLDP<<modeBits | mX, exLDP<<modeBits | mI, exLDP<<modeBits | mB:
+			core[ptb].b = slotPSpaces[w.id].get(raB % pspaceSize)
+
+		case exSTP<<modeBits | mA:
+			slotPSpaces[w.id].set(rbA%pspaceSize, raA)
+		case exSTP<<modeBits | mAB:
+			slotPSpaces[w.id].set(rbB%pspaceSize, raA)
+		case exSTP<<modeBits | mBA:
+			slotPSpaces[w.id].set(rbA%pspaceSize, raB)
+		case exSTP<<modeBits | mF, exSTP<<modeBits | mX, exSTP<<modeBits | mI, exSTP<<modeBits | mB:
+			slotPSpaces[w.id].set(rbB%pspaceSize, raB)
+		}
+
+		inc(&ip)
+		push(ip)
+		goto next
+
+	die:
+		if w.nprocs--; w.nprocs != 0 {
+			goto next
+		}
+		w.pred.succ = w.succ
+		w.succ.pred = w.pred
+		m.deaths = append(m.deaths, w.id)
+		cycles -= cycles / uint64(alive)
+		maxAliveProc = uint64(alive) * processes
+		if alive--; alive <= 1 {
+			goto out
+		}
+
+	next:
+		w = w.succ
+		if cycles--; cycles == 0 {
+			break
+		}
+	}
+
+out:
+	return alive
+}
+
+// mul multiplies two field values mod the core size without overflowing.
+func mul(x, y, cs uint32) uint32 {
+	return uint32(uint64(x) * uint64(y) % uint64(cs))
+}
@@ -31,12 +31,12 @@ func (k EditKind) String() string {
 
 // EditOp describes a single weighted edit operation between command sequences.
 type EditOp struct {
-	Kind   EditKind
-	AIndex int
-	BIndex int
-	From   Command
-	To     Command
-	Cost   float64
+	Kind   EditKind `json:"kind"`
+	AIndex int      `json:"a_index"`
+	BIndex int      `json:"b_index"`
+	From   Command  `json:"from"`
+	To     Command  `json:"to"`
+	Cost   float64  `json:"cost"`
 }
 
 // EditScript computes a weighted edit script between two parsed warriors.
@@ -0,0 +1,124 @@
+package goexmars
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// cTraceStep mirrors goexmars_trace_step_t.
+type cTraceStep struct {
+	Warrior int32
+	Address int32
+	Insn    cInsn
+}
+
+// cTrace mirrors goexmars_trace_t. Steps points to memory owned by the
+// library, released with trace_free.
+type cTrace struct {
+	Steps unsafe.Pointer
+	Len   int32
+}
+
+// TraceStep is one instruction executed by the simulator.
+type TraceStep struct {
+	// Warrior is the index of the executing warrior in the fight's input.
+	Warrior int `json:"warrior"`
+	// Address is the absolute core address of the instruction.
+	Address int `json:"address"`
+	// Command is the instruction as it was before it executed. Its fields are
+	// reduced modulo the core size and CMP is reported as SEQ.
+	Command Command `json:"command"`
+}
+
+// TraceParsed replays the fight FightParsed would run and returns every
+// instruction executed in the given 0-based round, in execution order.
+//
+// Positions and p-space follow the earlier rounds exactly, so with a fixed
+// FixPos the trace is reproducible. Index i of the result is the instruction
+// executed after i others in that round, counting every warrior's turn.
+func TraceParsed(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error) {
+	lib, f, err := libraryOrFallback()
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		if f.TraceParsed == nil {
+			return nil, fmt.Errorf("fallback %s does not support tracing", f.Name)
+		}
+		return f.TraceParsed(warriors, cfg, round)
+	}
+	return lib.TraceParsed(warriors, cfg, round)
+}
+
+// TraceParsedWithEngine is TraceParsed tracing the fight on e.
+func TraceParsedWithEngine(e Engine, warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error) {
+	return e.TraceParsed(warriors, cfg, round)
+}
+
+// TraceParsed is the package-level TraceParsed using l.
+func (l *Library) TraceParsed(warriors []ParsedWarrior, cfg FightConfig, round int) ([]TraceStep, error) {
+	if len(warriors) < 1 || len(warriors) > 6 {
+		return nil, fmt.Errorf("TraceParsed supports 1 to 6 warriors, got %d", len(warriors))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if round < 0 || round >= cfg.Rounds {
+		return nil, fmt.Errorf("round %d out of range [0, %d)", round, cfg.Rounds)
+	}
+
+	insns, lens, starts, err := toCWarriors(warriors, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The library sizes the trace to the executed instructions and owns it
+	// until trace_free.
+	var ct cTrace
+	cfgC := toCFightCfg(cfg)
+	diag := newDiagnosticsBuffers()
+
+	ret := l.traceInsns(
+		unsafe.Pointer(&insns[0]), unsafe.Pointer(&lens[0]), unsafe.Pointer(&starts[0]), int32(len(warriors)),
+		unsafe.Pointer(&cfgC), int32(round),
+		&ct,
+		unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+		unsafe.Pointer(&diag.list),
+	)
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(lens)
+	runtime.KeepAlive(starts)
+	runtime.KeepAlive(diag.recs)
+	defer l.traceFree(&ct)
+
+	if ret != 0 {
+		for diag.truncated() {
+			diag.grow()
+			l.diagnoseInsns(
+				unsafe.Pointer(&insns[0]), unsafe.Pointer(&lens[0]), unsafe.Pointer(&starts[0]), int32(len(warriors)),
+				unsafe.Pointer(&cfgC),
+				unsafe.Pointer(&diag.text[0]), int32(len(diag.text)), &diag.textLen,
+				unsafe.Pointer(&diag.list),
+			)
+			runtime.KeepAlive(diag.recs)
+		}
+		if diag.textLen > 0 || diag.list.Len > 0 {
+			return nil, diag.err()
+		}
+		return nil, errors.New("trace failed")
+	}
+
+	trace := make([]TraceStep, ct.Len)
+	if ct.Len > 0 {
+		for i, st := range unsafe.Slice((*cTraceStep)(ct.Steps), ct.Len) {
+			trace[i] = TraceStep{
+				Warrior: int(st.Warrior),
+				Address: int(st.Address),
+				Command: fromCInsn(st.Insn),
+			}
+		}
+	}
+	return trace, nil
+}
@@ -0,0 +1,43 @@
+package goexmars
+
+import (
+	"testing"
+
+	"github.com/BigJk/goexmars/internal/testlib"
+)
+
+func TestTraceParsedImp(t *testing.T) {
+	testlib.Configure(t)
+
+	imp := ParsedWarrior{Commands: []Command{{
+		OpCode:          OpCodeMOV,
+		Modifier:        ModifierI,
+		AddressingModeA: AddressingDirect,
+		A:               0,
+		AddressingModeB: AddressingDirect,
+		B:               1,
+	}}}
+	cfg := DefaultConfig.SetCycles(50).SetRounds(2)
+
+	trace, err := TraceParsed([]ParsedWarrior{imp}, cfg, 1)
+	if err != nil {
+		t.Fatalf("TraceParsed returned error: %v", err)
+	}
+	if len(trace) != 50 {
+		t.Fatalf("expected 50 steps, got %d", len(trace))
+	}
+	for i, st := range trace {
+		if st.Warrior != 0 || st.Address != i || st.Command != imp.Commands[0] {
+			t.Fatalf("unexpected step %d: %+v", i, st)
+		}
+	}
+}
+
+func TestTraceParsedRejectsRoundOutOfRange(t *testing.T) {
+	testlib.Configure(t)
+
+	w := ParsedWarrior{Commands: []Command{{OpCode: OpCodeDAT}}}
+	if _, err := TraceParsed([]ParsedWarrior{w}, DefaultConfig.SetRounds(3), 3); err == nil {
+		t.Fatalf("expected error for round 3 of 3")
+	}
+}